@@ -5,13 +5,17 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"reflect"
 	"sync"
 	"syscall"
+	"time"
 
 	clients "github.com/c0dered273/go-adv-metrics/internal/agent"
 	"github.com/c0dered273/go-adv-metrics/internal/config"
 	"github.com/c0dered273/go-adv-metrics/internal/log/agent"
 	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/model"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
@@ -33,31 +37,291 @@ func main() {
 	fmt.Printf("Build commit: %s\n", buildCommit)
 
 	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
 	ctx, cancel := context.WithCancel(context.Background())
 
 	logger := agent.NewAgentLogger()
-	cfg, err := config.NewAgentConfig(logger)
+	localCfg, err := config.NewAgentConfig(logger)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("agent: configuration error")
 	}
 
+	var remote *model.AgentRemoteConfig
+	var remoteConfig *clients.RemoteConfig
+	var remoteUpdates <-chan *model.AgentRemoteConfig
+	if localCfg.RemoteConfigInterval > 0 {
+		remoteConfig, err = clients.NewRemoteConfig(localCfg)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("agent: init failed")
+		}
+		remote, err = remoteConfig.Fetch(ctx)
+		if err != nil {
+			logger.Error().Err(err).Msg("agent: failed to fetch remote configuration, using local configuration")
+		}
+		remoteUpdates = remoteConfig.Watch(ctx, remote)
+	}
+
+	cfg := clients.ApplyRemoteConfig(localCfg, remote)
+	if err := cfg.Validate(); err != nil {
+		logger.Error().Err(err).Msg("agent: invalid remote configuration, using local configuration")
+		cfg = localCfg
+	}
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
-	metricClient, err := clients.NewMetricAgent(ctx, &wg, cfg)
+	telemetry := metric.NewTelemetry()
+	metricClient, err := clients.NewMetricAgent(ctx, &wg, cfg, telemetry)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("agent: init failed")
 	}
 
-	metricClient.SendAllMetricsContinuously(
-		metric.ConcatSources(
-			metric.NewMemStats(),
-			metric.NewPsUtilStats(),
-		))
+	collectors, err := newCollectors(cfg, telemetry, nil)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("agent: init failed")
+	}
+	metricClient.SendAllMetricsContinuously(nil, collectorsOf(collectors)...)
+
+	var reloaders []clientReloader
+	if cfg.HeartbeatInterval > 0 {
+		heartbeatClient, err := clients.NewClient(ctx, cfg)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("agent: init failed")
+		}
+		heartbeat := clients.NewHeartbeat(ctx, &wg, cfg, clients.Instrument(heartbeatClient, telemetry, cfg), clients.BuildInfo{
+			Version: buildVersion,
+			Commit:  buildCommit,
+		})
+		wg.Add(1)
+		heartbeat.Run()
+		reloaders = append(reloaders, heartbeat)
+	}
+
+	if cfg.StatsDAddress != "" {
+		statsDClient, err := clients.NewClient(ctx, cfg)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("agent: init failed")
+		}
+		statsD, err := clients.NewStatsDListener(ctx, &wg, cfg, clients.Instrument(statsDClient, telemetry, cfg))
+		if err != nil {
+			logger.Fatal().Err(err).Msg("agent: failed to start statsd listener")
+		}
+		wg.Add(1)
+		statsD.Listen()
+		reloaders = append(reloaders, statsD)
+		logger.Info().Msgf("StatsD listener started at %v", statsD.Addr())
+	}
+
+	for {
+		select {
+		case <-reload:
+			newLocalCfg, err := config.NewAgentConfig(logger)
+			if err != nil {
+				logger.Error().Err(err).Msg("agent: configuration reload failed, keeping current configuration")
+				continue
+			}
+			newCfg := clients.ApplyRemoteConfig(newLocalCfg, remote)
+			newCollectors, err := applyAgentConfig(ctx, metricClient, telemetry, collectors, cfg, newCfg, logger, reloaders...)
+			if err != nil {
+				logger.Error().Err(err).Msg("agent: configuration reload failed, keeping current configuration")
+				continue
+			}
+			localCfg, cfg, collectors = newLocalCfg, newCfg, newCollectors
+			if remoteConfig != nil {
+				if err := remoteConfig.Reload(newLocalCfg); err != nil {
+					logger.Error().Err(err).Msg("agent: failed to reconnect for remote configuration, keeping current connection")
+				}
+			}
+			logger.Info().Msg("agent: configuration reloaded")
+		case newRemote, ok := <-remoteUpdates:
+			if !ok {
+				remoteUpdates = nil
+				continue
+			}
+			newCfg := clients.ApplyRemoteConfig(localCfg, newRemote)
+			newCollectors, err := applyAgentConfig(ctx, metricClient, telemetry, collectors, cfg, newCfg, logger, reloaders...)
+			if err != nil {
+				logger.Error().Err(err).Msg("agent: failed to apply remote configuration, keeping current configuration")
+				continue
+			}
+			remote, cfg, collectors = newRemote, newCfg, newCollectors
+			logger.Info().
+				Str("group", newRemote.GetGroup()).
+				Str("version", newRemote.GetVersion()).
+				Msg("agent: remote configuration applied")
+		case <-shutdown:
+			cancel()
+			wg.Wait()
+			log.Info().Msg("Metrics agent shutdown")
+			return
+		}
+	}
+}
+
+// collectorEntry сборщик метрик агента вместе с настройками, по которым он создан
+type collectorEntry struct {
+	name      string
+	settings  any
+	collector metric.Collector
+}
+
+// collectorsOf возвращает сборщики в порядке их опроса
+func collectorsOf(entries []collectorEntry) []metric.Collector {
+	collectors := make([]metric.Collector, len(entries))
+	for i := range entries {
+		collectors[i] = entries[i].collector
+	}
+	return collectors
+}
+
+type execSettings struct {
+	dir     string
+	timeout time.Duration
+}
+
+type prometheusSettings struct {
+	targets []string
+	timeout time.Duration
+}
+
+// newCollectors создает сборщики метрик в соответствии с конфигурацией.
+// Сборщики из prev, настройки которых не изменились, переиспользуются. Так при перезагрузке конфигурации
+// сохраняется их состояние: база для расчета загрузки CPU процессов и счетчики ошибок скриптов и опросов
+func newCollectors(cfg *config.AgentConfig, telemetry *metric.Telemetry, prev []collectorEntry) ([]collectorEntry, error) {
+	diskFilter := metric.DiskFilter{
+		MountPointsInclude: cfg.DiskMountPointsInclude,
+		MountPointsExclude: cfg.DiskMountPointsExclude,
+		FSTypesInclude:     cfg.DiskFSTypesInclude,
+		FSTypesExclude:     cfg.DiskFSTypesExclude,
+	}
+	netFilter := metric.NetFilter{
+		InterfacesInclude: cfg.NetInterfacesInclude,
+		InterfacesExclude: cfg.NetInterfacesExclude,
+	}
+
+	// create возвращает nil, если сборщик не настроен
+	specs := []struct {
+		name     string
+		settings any
+		create   func() (metric.Collector, error)
+	}{
+		{config.CollectorMem, nil, func() (metric.Collector, error) {
+			return metric.NewSourceCollector(config.CollectorMem, metric.NewMemStats()), nil
+		}},
+		{config.CollectorPsUtil, nil, func() (metric.Collector, error) {
+			return metric.NewSourceCollector(config.CollectorPsUtil, metric.NewPsUtilStats()), nil
+		}},
+		{config.CollectorDisk, diskFilter, func() (metric.Collector, error) {
+			return metric.NewSourceCollector(config.CollectorDisk, metric.NewDiskStats(diskFilter)), nil
+		}},
+		{config.CollectorNet, netFilter, func() (metric.Collector, error) {
+			return metric.NewSourceCollector(config.CollectorNet, metric.NewNetStats(netFilter)), nil
+		}},
+		{config.CollectorProcess, cfg.ProcessRules, func() (metric.Collector, error) {
+			return metric.NewProcessCollector(cfg.ProcessRules)
+		}},
+		{config.CollectorExec, execSettings{cfg.ExecDir, cfg.ExecTimeout}, func() (metric.Collector, error) {
+			if cfg.ExecDir == "" {
+				return nil, nil
+			}
+			return metric.NewExecCollector(cfg.ExecDir, cfg.ExecTimeout), nil
+		}},
+		{config.CollectorPrometheus, prometheusSettings{cfg.PrometheusTargets, cfg.PrometheusTimeout}, func() (metric.Collector, error) {
+			if len(cfg.PrometheusTargets) == 0 {
+				return nil, nil
+			}
+			return metric.NewPrometheusCollector(cfg.PrometheusTargets, cfg.PrometheusTimeout), nil
+		}},
+		{config.CollectorAgent, nil, func() (metric.Collector, error) {
+			return telemetry, nil
+		}},
+	}
+
+	var entries []collectorEntry
+	for _, spec := range specs {
+		if !cfg.IsCollectorEnabled(spec.name) {
+			continue
+		}
+		if e, ok := findCollector(prev, spec.name, spec.settings); ok {
+			entries = append(entries, e)
+			continue
+		}
+		collector, err := spec.create()
+		if err != nil {
+			return nil, err
+		}
+		if collector != nil {
+			entries = append(entries, collectorEntry{name: spec.name, settings: spec.settings, collector: collector})
+		}
+	}
+
+	return entries, nil
+}
+
+// findCollector ищет сборщик, созданный с теми же настройками
+func findCollector(entries []collectorEntry, name string, settings any) (collectorEntry, bool) {
+	for _, e := range entries {
+		if e.name == name && reflect.DeepEqual(e.settings, settings) {
+			return e, true
+		}
+	}
+	return collectorEntry{}, false
+}
+
+// clientReloader компонент агента, который отправляет данные на сервер собственным клиентом
+type clientReloader interface {
+	Reload(*config.AgentConfig, clients.Client)
+}
+
+// applyAgentConfig применяет новую конфигурацию к работающему агенту и возвращает новый набор сборщиков.
+// При любой ошибке агент продолжает работать с текущей конфигурацией
+func applyAgentConfig(
+	ctx context.Context,
+	metricClient clients.Agent,
+	telemetry *metric.Telemetry,
+	collectors []collectorEntry,
+	current *config.AgentConfig,
+	next *config.AgentConfig,
+	logger zerolog.Logger,
+	reloaders ...clientReloader,
+) ([]collectorEntry, error) {
+	if err := next.Validate(); err != nil {
+		return nil, err
+	}
+
+	nextCollectors, err := newCollectors(next, telemetry, collectors)
+	if err != nil {
+		return nil, err
+	}
+
+	reloaderClients := make([]clients.Client, 0, len(reloaders))
+	closeClients := func() {
+		for _, client := range reloaderClients {
+			_ = client.Close()
+		}
+	}
+	for range reloaders {
+		client, err := clients.NewClient(ctx, next)
+		if err != nil {
+			closeClients()
+			return nil, err
+		}
+		reloaderClients = append(reloaderClients, clients.Instrument(client, telemetry, next))
+	}
+
+	if err := metricClient.Reload(next, nil, collectorsOf(nextCollectors)...); err != nil {
+		closeClients()
+		return nil, err
+	}
+
+	for i, r := range reloaders {
+		r.Reload(next, reloaderClients[i])
+	}
+	if next.StatsDAddress != current.StatsDAddress {
+		logger.Warn().Msg("agent: statsd address change requires restart")
+	}
 
-	<-shutdown
-	cancel()
-	wg.Wait()
-	log.Info().Msg("Metrics agent shutdown")
+	return nextCollectors, nil
 }
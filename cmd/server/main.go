@@ -14,6 +14,7 @@ import (
 	"github.com/c0dered273/go-adv-metrics/internal/handler"
 	serverLog "github.com/c0dered273/go-adv-metrics/internal/log/server"
 	"github.com/c0dered273/go-adv-metrics/internal/server"
+	"github.com/c0dered273/go-adv-metrics/internal/service"
 	"github.com/rs/zerolog/log"
 )
 
@@ -35,7 +36,9 @@ func main() {
 	fmt.Printf("Build commit: %s\n", buildCommit)
 
 	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
 	serverCtx, serverStopCtx := context.WithCancel(context.Background())
 
 	logger := serverLog.NewServerLogger()
@@ -80,6 +83,27 @@ func main() {
 		}
 	}()
 
+	go cfg.SelfMetrics.Run(serverCtx, cfg.SelfMetricsInterval)
+	go cfg.History.Run(serverCtx, cfg.Repo, cfg.QuerySampleInterval, logger)
+	go cfg.Alerts.Run(serverCtx, cfg.AlertEvalInterval)
+	cfg.Recording.SetStore(service.StoreRecorded(cfg))
+	go cfg.Recording.Run(serverCtx, cfg.RecordingInterval)
+
+	go func() {
+		for {
+			select {
+			case <-reload:
+				if err := cfg.Reload(); err != nil {
+					logger.Error().Err(err).Msg("server: configuration reload failed, keeping current configuration")
+					continue
+				}
+				logger.Info().Msg("server: configuration reloaded")
+			case <-serverCtx.Done():
+				return
+			}
+		}
+	}()
+
 	go func() {
 		<-shutdown
 		shutdownCtx, shutdownCancelCtx := context.WithTimeout(serverCtx, 30*time.Second)
@@ -91,6 +115,9 @@ func main() {
 			}
 		}()
 
+		// Остановка серверов ждет завершения вызовов, поэтому подписки на метрики закрываются сразу
+		cfg.Stream.Close()
+
 		err = httpServer.Shutdown(shutdownCtx)
 		if err != nil {
 			log.Fatal().Err(err)
@@ -20,6 +20,7 @@ require (
 	github.com/swaggo/swag v1.16.1
 	github.com/testcontainers/testcontainers-go v0.18.0
 	golang.org/x/tools v0.8.0
+	google.golang.org/genproto v0.0.0-20230110181048-76db0878b65f
 	google.golang.org/grpc v1.53.0
 	google.golang.org/protobuf v1.30.0
 	honnef.co/go/tools v0.4.3
@@ -77,6 +78,5 @@ require (
 	golang.org/x/net v0.9.0 // indirect
 	golang.org/x/sys v0.7.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
-	google.golang.org/genproto v0.0.0-20230110181048-76db0878b65f // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -4,28 +4,45 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"net/url"
 	"os"
+	"sync"
 
 	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/fleet"
 	"github.com/c0dered273/go-adv-metrics/internal/interceptors"
 	"github.com/c0dered273/go-adv-metrics/internal/metric"
 	"github.com/c0dered273/go-adv-metrics/internal/service"
 	"github.com/go-resty/resty/v2"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/logging"
+	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 )
 
 type Agent interface {
-	SendAllMetricsContinuously([]metric.UpdatableMetric)
+	SendAllMetricsContinuously([]metric.UpdatableMetric, ...metric.Collector)
+	Reload(*config.AgentConfig, []metric.UpdatableMetric, ...metric.Collector) error
 }
 
 type Client interface {
 	PostMetric([]metric.UpdatableMetric) error
+	SendHeartbeat(fleet.Heartbeat) error
+	// Close освобождает соединения клиента, после вызова клиент не используется
+	Close() error
 }
 
-func NewGRPCClient(ctx context.Context, cfg *config.AgentConfig) (Client, error) {
+// NewClient возвращает gRPC или HTTP клиента для отправки метрик, в зависимости от настроек агента
+func NewClient(ctx context.Context, cfg *config.AgentConfig) (Client, error) {
+	if cfg.GRPCClient {
+		return NewGRPCClient(ctx, cfg)
+	}
+	return NewHTTPClient(ctx, cfg)
+}
+
+// dialGRPC открывает защищенное соединение с gRPC сервером метрик
+func dialGRPC(cfg *config.AgentConfig) (*grpc.ClientConn, error) {
 	caPem, err := os.ReadFile(cfg.CACertFile)
 	if err != nil {
 		return nil, err
@@ -33,7 +50,7 @@ func NewGRPCClient(ctx context.Context, cfg *config.AgentConfig) (Client, error)
 
 	certPool := x509.NewCertPool()
 	if !certPool.AppendCertsFromPEM(caPem) {
-		return nil, err
+		return nil, errors.New("agent: failed to load CA certificate to cert pool")
 	}
 
 	tlsConfig := &tls.Config{
@@ -49,24 +66,34 @@ func NewGRPCClient(ctx context.Context, cfg *config.AgentConfig) (Client, error)
 	connectParams := grpc.ConnectParams{
 		MinConnectTimeout: connTimeout,
 	}
-	conn, err := grpc.Dial(
+	return grpc.Dial(
 		targetURL.Host,
 		grpc.WithConnectParams(connectParams),
 		grpc.WithTransportCredentials(tlsCredentials),
 		grpc.WithChainUnaryInterceptor(
 			logging.UnaryClientInterceptor(interceptors.InterceptorLogger(cfg.Logger), interceptors.GetLoggerOpts()...),
 		),
+		grpc.WithChainStreamInterceptor(
+			logging.StreamClientInterceptor(interceptors.InterceptorLogger(cfg.Logger), interceptors.GetLoggerOpts()...),
+		),
 	)
+}
+
+func NewGRPCClient(ctx context.Context, cfg *config.AgentConfig) (Client, error) {
+	conn, err := dialGRPC(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	grpcClient := service.NewMetricsServiceClient(conn)
-
 	return &GRPCClient{
-		ctx:          ctx,
-		cfg:          cfg,
-		metricClient: grpcClient,
+		ctx:            ctx,
+		cfg:            cfg,
+		conn:           conn,
+		metricClient:   service.NewMetricsServiceClient(conn),
+		registryClient: service.NewAgentRegistryServiceClient(conn),
+		mu:             new(sync.Mutex),
+		window:         streamWindow,
+		ackTimeout:     streamAckTimeout,
 	}, nil
 }
 
@@ -84,3 +111,70 @@ func NewHTTPClient(ctx context.Context, cfg *config.AgentConfig) (Client, error)
 		client: restyClient,
 	}, nil
 }
+
+// retiredClients клиенты, замененные при перезагрузке конфигурации.
+// Их закрывает горутина отправки, когда они ей уже не нужны, чтобы не прервать начатую отправку
+type retiredClients struct {
+	mu      sync.Mutex
+	clients []Client
+}
+
+func (r *retiredClients) add(client Client) {
+	if client == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients = append(r.clients, client)
+}
+
+// close закрывает замененных клиентов
+func (r *retiredClients) close(logger zerolog.Logger) {
+	r.mu.Lock()
+	clients := r.clients
+	r.clients = nil
+	r.mu.Unlock()
+
+	for _, client := range clients {
+		if err := client.Close(); err != nil {
+			logger.Error().Err(err).Msg("agent: failed to close replaced client")
+		}
+	}
+}
+
+// Destination возвращает имя адресата отправки метрик для телеметрии агента, например grpc_localhost_8080
+func Destination(cfg *config.AgentConfig) string {
+	transport := "http"
+	if cfg.GRPCClient {
+		transport = "grpc"
+	}
+	if targetURL, err := url.Parse(cfg.Address); err == nil && targetURL.Host != "" {
+		return transport + "_" + targetURL.Host
+	}
+	return transport + "_" + cfg.Address
+}
+
+// instrumentedClient учитывает в телеметрии агента результаты отправки метрик
+type instrumentedClient struct {
+	Client
+	telemetry   *metric.Telemetry
+	destination string
+}
+
+// Instrument оборачивает клиента так, что каждая отправка корзины метрик учитывается в телеметрии
+func Instrument(client Client, telemetry *metric.Telemetry, cfg *config.AgentConfig) Client {
+	if telemetry == nil {
+		return client
+	}
+	return &instrumentedClient{
+		Client:      client,
+		telemetry:   telemetry,
+		destination: Destination(cfg),
+	}
+}
+
+func (c *instrumentedClient) PostMetric(metrics []metric.UpdatableMetric) error {
+	err := c.Client.PostMetric(metrics)
+	c.telemetry.ObserveSend(c.destination, len(metrics), err)
+	return err
+}
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/fleet"
+)
+
+// BuildInfo версия сборки агента, заполняется флагами линковщика
+type BuildInfo struct {
+	Version string
+	Commit  string
+}
+
+// Heartbeat периодически сообщает серверу, что агент работает
+type Heartbeat struct {
+	Ctx     context.Context
+	Wg      *sync.WaitGroup
+	Config  *config.AgentConfig
+	client  Client
+	retired retiredClients
+	build   BuildInfo
+	started time.Time
+	reload  chan struct{}
+	mu      *sync.RWMutex
+}
+
+// NewHeartbeat возвращает настроенную отправку heartbeat, отправка начинается после вызова Run
+func NewHeartbeat(ctx context.Context, wg *sync.WaitGroup, cfg *config.AgentConfig, client Client, build BuildInfo) *Heartbeat {
+	return &Heartbeat{
+		Ctx:     ctx,
+		Wg:      wg,
+		Config:  cfg,
+		client:  client,
+		build:   build,
+		started: time.Now(),
+		reload:  make(chan struct{}, 1),
+		mu:      new(sync.RWMutex),
+	}
+}
+
+// Reload заменяет настройки и клиента для отправки heartbeat
+func (h *Heartbeat) Reload(cfg *config.AgentConfig, client Client) {
+	h.mu.Lock()
+	h.retired.add(h.client)
+	h.Config = cfg
+	h.client = client
+	h.mu.Unlock()
+
+	notify(h.reload)
+}
+
+func (h *Heartbeat) settings() (*config.AgentConfig, Client) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.Config, h.client
+}
+
+// Run запускает отправку heartbeat, первый heartbeat отправляется сразу
+func (h *Heartbeat) Run() {
+	go func() {
+		cfg, _ := h.settings()
+		ticker := time.NewTicker(cfg.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			h.send()
+
+			select {
+			case <-ticker.C:
+			case <-h.reload:
+				// Отключение heartbeat требует перезапуска агента, до тех пор сохраняется прежний интервал
+				if cfg, _ = h.settings(); cfg.HeartbeatInterval > 0 {
+					ticker.Reset(cfg.HeartbeatInterval)
+				}
+			case <-h.Ctx.Done():
+				cfg, client := h.settings()
+				h.retired.close(cfg.Logger)
+				if err := client.Close(); err != nil {
+					cfg.Logger.Error().Err(err).Msg("agent: failed to close heartbeat client")
+				}
+				h.Wg.Done()
+				return
+			}
+		}
+	}()
+}
+
+func (h *Heartbeat) send() {
+	cfg, client := h.settings()
+	h.retired.close(cfg.Logger)
+	heartbeat := h.heartbeat(cfg)
+	heartbeat.SetHash(cfg.Key)
+	if err := client.SendHeartbeat(heartbeat); err != nil {
+		cfg.Logger.Error().Err(err).Msg("agent: failed to send heartbeat")
+	}
+}
+
+func (h *Heartbeat) heartbeat(cfg *config.AgentConfig) fleet.Heartbeat {
+	hostname, _ := os.Hostname()
+	return fleet.Heartbeat{
+		Hostname:      hostname,
+		Group:         cfg.Group,
+		Version:       h.build.Version,
+		Commit:        h.build.Commit,
+		OS:            runtime.GOOS + "/" + runtime.GOARCH,
+		UptimeSeconds: int64(time.Since(h.started).Seconds()),
+	}
+}
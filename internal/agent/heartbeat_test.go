@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeat_Run(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	cfg := &config.AgentConfig{
+		AgentInParams: &config.AgentInParams{
+			Group:             "db",
+			HeartbeatInterval: 10 * time.Millisecond,
+		},
+	}
+	client := &testClient{}
+	heartbeat := NewHeartbeat(ctx, &wg, cfg, client, BuildInfo{Version: "v1.2.3", Commit: "abc"})
+	wg.Add(1)
+	heartbeat.Run()
+
+	assert.Eventually(t, func() bool {
+		client.mu.Lock()
+		defer client.mu.Unlock()
+		return len(client.heartbeats) >= 2
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	wg.Wait()
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	h := client.heartbeats[0]
+	assert.NotEmpty(t, h.Hostname)
+	assert.Equal(t, "db", h.Group)
+	assert.Equal(t, "v1.2.3", h.Version)
+	assert.Equal(t, "abc", h.Commit)
+	assert.NotEmpty(t, h.OS)
+}
+
+func TestHeartbeat_Reload(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+
+	cfg := &config.AgentConfig{
+		AgentInParams: &config.AgentInParams{
+			HeartbeatInterval: 10 * time.Millisecond,
+		},
+	}
+	oldClient := &testClient{}
+	heartbeat := NewHeartbeat(ctx, &wg, cfg, oldClient, BuildInfo{})
+	wg.Add(1)
+	heartbeat.Run()
+
+	newClient := &testClient{}
+	heartbeat.Reload(cfg, newClient)
+	assert.Eventually(t, func() bool {
+		newClient.mu.Lock()
+		defer newClient.mu.Unlock()
+		return len(newClient.heartbeats) > 0
+	}, time.Second, 5*time.Millisecond)
+	assert.True(t, oldClient.isClosed(), "replaced client should be closed")
+	assert.False(t, newClient.isClosed())
+
+	cancel()
+	wg.Wait()
+	assert.True(t, newClient.isClosed(), "client should be closed on shutdown")
+}
@@ -7,27 +7,38 @@ import (
 	"crypto/rsa"
 	"crypto/sha256"
 	"encoding/json"
+	"fmt"
 	"net"
 	"net/url"
+	"os"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/fleet"
 	"github.com/c0dered273/go-adv-metrics/internal/metric"
 	"github.com/c0dered273/go-adv-metrics/internal/model"
 	"github.com/c0dered273/go-adv-metrics/internal/service"
 	"github.com/go-resty/resty/v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 // Настройки отправки обновлений от агента
 const (
-	updateEndpoint   = "/updates/"
-	connTimeout      = 5 * time.Second
-	retryCount       = 3
-	retryWaitTime    = 5 * time.Second
-	retryMaxWaitTime = 15 * time.Second
-	BufferLen        = 3
+	updateEndpoint    = "/updates/"
+	heartbeatEndpoint = "/api/v1/agents/heartbeat"
+	connTimeout       = 5 * time.Second
+	retryCount        = 3
+	retryWaitTime     = 5 * time.Second
+	retryMaxWaitTime  = 15 * time.Second
+	BufferLen         = 3
+	streamAckTimeout  = 15 * time.Second
+	// streamWindow сколько корзин, отправленных в поток, может одновременно ожидать подтверждения
+	streamWindow = 8
 )
 
 type metricUpdate struct {
@@ -55,7 +66,16 @@ type MetricAgent struct {
 	Wg     *sync.WaitGroup
 	Config *config.AgentConfig
 	client Client
-	buffer []metric.UpdatableMetric
+	// retired клиенты, замененные при перезагрузке, закрываются горутиной отправки
+	retired retiredClients
+	buffer  []metric.UpdatableMetric
+
+	telemetry    *metric.Telemetry
+	mu           *sync.RWMutex
+	sources      []metric.UpdatableMetric
+	collectors   []metric.Collector
+	updateReload chan struct{}
+	sendReload   chan struct{}
 }
 
 type HTTPClient struct {
@@ -75,32 +95,28 @@ func (c *HTTPClient) PostMetric(metrics []metric.UpdatableMetric) error {
 		EnableTrace().
 		SetHeader("Content-Type", "application/json").
 		SetHeader("X-Real-IP", getPreferredHostIP(c.config.Address)).
+		SetHeader("X-Client-ID", getClientID()).
+		SetHeader("X-Instance-ID", instanceID).
 		SetBody(body).
 		Post(c.config.Address + updateEndpoint)
 	if err != nil {
 		return err
 	}
-	if response.IsSuccess() {
-		c.config.Logger.
-			Info().
-			Int("status_code", response.StatusCode()).
-			Str("method", response.Request.Method).
-			Str("url", response.Request.URL).
-			Msg("send update success")
-	} else {
-		c.config.Logger.
-			Error().
-			Int("status_code", response.StatusCode()).
-			Str("method", response.Request.Method).
-			Str("url", response.Request.URL).
-			Msg("agent: metric update failed")
+	if !response.IsSuccess() {
+		return fmt.Errorf("agent: metric update failed with status code %d", response.StatusCode())
 	}
+	c.config.Logger.
+		Info().
+		Int("status_code", response.StatusCode()).
+		Str("method", response.Request.Method).
+		Str("url", response.Request.URL).
+		Msg("send update success")
 	return nil
 }
 
-func (c *HTTPClient) encryptBody(metrics []metric.UpdatableMetric, key *rsa.PublicKey) (any, error) {
+func (c *HTTPClient) encryptBody(payload any, key *rsa.PublicKey) (any, error) {
 	if key != nil {
-		m, err := json.Marshal(metrics)
+		m, err := json.Marshal(payload)
 		if err != nil {
 			return nil, err
 		}
@@ -108,28 +124,153 @@ func (c *HTTPClient) encryptBody(metrics []metric.UpdatableMetric, key *rsa.Publ
 		return rsa.EncryptOAEP(sha256.New(), rand.Reader, key, m, nil)
 	}
 
-	return metrics, nil
+	return payload, nil
 }
 
-type GRPCClient struct {
-	ctx          context.Context
-	cfg          *config.AgentConfig
-	metricClient service.MetricsServiceClient
+// Close закрывает неиспользуемые соединения с сервером
+func (c *HTTPClient) Close() error {
+	c.client.GetClient().CloseIdleConnections()
+	return nil
 }
 
-func (c *GRPCClient) PostMetric(metrics []metric.UpdatableMetric) error {
-	pbMetrics := make([]*model.Metric, len(metrics))
-	err := service.MapSliceWithSerialization(service.ToSliceOfPointers(metrics), pbMetrics)
+func (c *HTTPClient) SendHeartbeat(heartbeat fleet.Heartbeat) error {
+	body, err := c.encryptBody(heartbeat, c.config.PublicKey)
 	if err != nil {
 		return err
 	}
 
+	response, err := c.client.R().
+		SetContext(c.ctx).
+		SetHeader("Content-Type", "application/json").
+		SetHeader("X-Real-IP", getPreferredHostIP(c.config.Address)).
+		SetHeader("X-Client-ID", getClientID()).
+		SetBody(body).
+		Post(c.config.Address + heartbeatEndpoint)
+	if err != nil {
+		return err
+	}
+	if !response.IsSuccess() {
+		return fmt.Errorf("agent: heartbeat failed with status code %d", response.StatusCode())
+	}
+	return nil
+}
+
+type GRPCClient struct {
+	ctx            context.Context
+	cfg            *config.AgentConfig
+	conn           *grpc.ClientConn
+	metricClient   service.MetricsServiceClient
+	registryClient service.AgentRegistryServiceClient
+
+	// mu защищает поток отправки метрик, который открывается при первой отправке и переоткрывается после ошибки
+	mu     *sync.Mutex
+	stream *ackStream
+	seq    uint64
+	// inflight номера отправленных в поток корзин, подтверждения на которые еще не пришли, в порядке отправки
+	inflight []uint64
+	// window наибольшее число неподтвержденных корзин, ackTimeout время ожидания подтверждения
+	window     int
+	ackTimeout time.Duration
+}
+
+// ackStream поток отправки корзин метрик. Подтверждения читаются в фоне, чтобы отправлять
+// следующие корзины, не дожидаясь подтверждения предыдущих
+type ackStream struct {
+	stream service.MetricsService_SaveStreamClient
+	cancel context.CancelFunc
+	// acks закрывается, когда поток оборвался, ошибка остается в err
+	acks chan *model.SaveAck
+	err  error
+}
+
+func newAckStream(ctx context.Context, client service.MetricsServiceClient, window int) (*ackStream, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := client.SaveStream(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s := &ackStream{
+		stream: stream,
+		cancel: cancel,
+		acks:   make(chan *model.SaveAck, window),
+	}
+	go func() {
+		for {
+			ack, err := stream.Recv()
+			if err != nil {
+				s.err = err
+				close(s.acks)
+				return
+			}
+			select {
+			case s.acks <- ack:
+			case <-ctx.Done():
+				s.err = ctx.Err()
+				close(s.acks)
+				return
+			}
+		}
+	}()
+	return s, nil
+}
+
+// next возвращает следующее подтверждение. Если wait false и подтверждений нет, сразу возвращает nil,
+// иначе ждет подтверждения не дольше timeout
+func (s *ackStream) next(wait bool, timeout time.Duration) (*model.SaveAck, error) {
+	if !wait {
+		select {
+		case ack, ok := <-s.acks:
+			return s.received(ack, ok)
+		default:
+			return nil, nil
+		}
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case ack, ok := <-s.acks:
+		return s.received(ack, ok)
+	case <-timer.C:
+		return nil, status.Errorf(codes.DeadlineExceeded, "agent: no ack received in %s", timeout)
+	}
+}
+
+func (s *ackStream) received(ack *model.SaveAck, ok bool) (*model.SaveAck, error) {
+	if !ok {
+		return nil, s.err
+	}
+	return ack, nil
+}
+
+// outgoingContext добавляет в контекст вызова адрес и идентификатор агента
+func (c *GRPCClient) outgoingContext() context.Context {
 	md := metadata.New(map[string]string{
-		"X-Real-IP": getPreferredHostIP(c.cfg.Address),
+		"X-Real-IP":     getPreferredHostIP(c.cfg.Address),
+		"X-Client-ID":   getClientID(),
+		"X-Instance-ID": instanceID,
 	})
-	outCtx := metadata.NewOutgoingContext(c.ctx, md)
+	return metadata.NewOutgoingContext(c.ctx, md)
+}
 
-	_, err = c.metricClient.SaveAll(outCtx, &model.Metrics{Metrics: pbMetrics})
+func (c *GRPCClient) SendHeartbeat(heartbeat fleet.Heartbeat) error {
+	_, err := c.registryClient.Heartbeat(c.outgoingContext(), service.ToPbHeartbeat(heartbeat))
+	return err
+}
+
+func (c *GRPCClient) PostMetric(metrics []metric.UpdatableMetric) error {
+	pbMetrics := make([]*model.Metric, len(metrics))
+	for i := range metrics {
+		pbMetrics[i] = service.ToPbMetric(metrics[i].Metric)
+	}
+
+	if c.cfg.GRPCStream {
+		return c.postStream(pbMetrics)
+	}
+
+	_, err := c.metricClient.SaveAll(c.outgoingContext(), &model.Metrics{Metrics: pbMetrics})
 	if err != nil {
 		return err
 	}
@@ -137,45 +278,183 @@ func (c *GRPCClient) PostMetric(metrics []metric.UpdatableMetric) error {
 	return nil
 }
 
-// NewMetricAgent возвращает настроенного агента
-func NewMetricAgent(ctx context.Context, wg *sync.WaitGroup, config *config.AgentConfig) (Agent, error) {
-	var client Client
-	var err error
-
-	if config.GRPCClient {
-		client, err = NewGRPCClient(ctx, config)
+// postStream отправляет корзину метрик в поток, не дожидаясь подтверждения. Ждать приходится, только когда
+// подтверждения ожидают window корзин. Подтверждения сверяются с номерами отправленных корзин по порядку,
+// ошибка из подтверждения возвращается тем вызовом, который его получил, поэтому может относиться к одной из
+// предыдущих корзин. Если поток оборвался или подтверждение не пришло вовремя, поток закрывается,
+// неподтвержденные корзины считаются потерянными, поток открывается при следующей отправке
+func (c *GRPCClient) postStream(pbMetrics []*model.Metric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stream == nil {
+		stream, err := newAckStream(c.outgoingContext(), c.metricClient, c.window)
 		if err != nil {
-			return nil, err
+			return err
 		}
-	} else {
-		client, err = NewHTTPClient(ctx, config)
+		c.stream = stream
+	}
+
+	c.seq++
+	if err := c.stream.stream.Send(&model.MetricBatch{Seq: c.seq, Metrics: pbMetrics}); err != nil {
+		c.closeStream()
+		return err
+	}
+	c.inflight = append(c.inflight, c.seq)
+
+	return c.receiveAcks(c.window)
+}
+
+// receiveAcks забирает пришедшие подтверждения и ждет новых, пока неподтвержденных корзин не меньше limit.
+// Возвращает ошибку первой отклоненной сервером корзины
+func (c *GRPCClient) receiveAcks(limit int) error {
+	var rejected error
+	for len(c.inflight) > 0 {
+		ack, err := c.stream.next(len(c.inflight) >= limit, c.ackTimeout)
 		if err != nil {
-			return nil, err
+			c.closeStream()
+			return err
+		}
+		if ack == nil {
+			break
 		}
+
+		if ack.GetSeq() != c.inflight[0] {
+			err = fmt.Errorf("agent: unexpected ack for batch %d, want %d", ack.GetSeq(), c.inflight[0])
+			c.closeStream()
+			return err
+		}
+		c.inflight = c.inflight[1:]
+		if code := codes.Code(ack.GetCode()); code != codes.OK && rejected == nil {
+			rejected = status.Error(code, ack.GetMessage())
+		}
+	}
+	return rejected
+}
+
+func (c *GRPCClient) closeStream() {
+	c.stream.cancel()
+	c.stream, c.inflight = nil, nil
+}
+
+// Close дожидается подтверждения отправленных корзин, закрывает поток отправки метрик и соединение с сервером.
+// Возвращает ошибку, если корзина отклонена сервером или подтверждение не пришло
+func (c *GRPCClient) Close() error {
+	c.mu.Lock()
+	var err error
+	if c.stream != nil {
+		err = c.receiveAcks(1)
+	}
+	// Если поток оборвался, он уже закрыт при получении подтверждений
+	if c.stream != nil {
+		c.closeStream()
+	}
+	c.mu.Unlock()
+
+	if closeErr := c.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// NewMetricAgent возвращает настроенного агента.
+// Если передана телеметрия, в нее записываются результаты отправки, глубина очереди и длительность опроса сборщиков
+func NewMetricAgent(ctx context.Context, wg *sync.WaitGroup, config *config.AgentConfig, telemetry *metric.Telemetry) (Agent, error) {
+	client, err := NewClient(ctx, config)
+	if err != nil {
+		return nil, err
 	}
 
 	return &MetricAgent{
-		Ctx:    ctx,
-		Wg:     wg,
-		Config: config,
-		client: client,
-		buffer: make([]metric.UpdatableMetric, 0, BufferLen),
+		Ctx:          ctx,
+		Wg:           wg,
+		Config:       config,
+		client:       Instrument(client, telemetry, config),
+		buffer:       make([]metric.UpdatableMetric, 0, BufferLen),
+		telemetry:    telemetry,
+		mu:           new(sync.RWMutex),
+		updateReload: make(chan struct{}, 1),
+		sendReload:   make(chan struct{}, 1),
 	}, nil
 }
 
-func (ma *MetricAgent) update(allMetrics []metric.UpdatableMetric, metricUpdate *metricUpdate) {
-	ticker := time.NewTicker(ma.Config.PollInterval)
+// Reload применяет новую конфигурацию агента без остановки.
+// Интервалы опроса и отправки, клиент и набор источников метрик заменяются,
+// уже собранные, но еще не отправленные метрики сохраняются и отправляются с новыми настройками.
+// Прежний клиент закрывается после завершения начатой им отправки.
+// Если не удалось создать клиента по новой конфигурации, агент продолжает работать со старой
+func (ma *MetricAgent) Reload(cfg *config.AgentConfig, allMetrics []metric.UpdatableMetric, collectors ...metric.Collector) error {
+	client, err := NewClient(ma.Ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	ma.mu.Lock()
+	ma.retired.add(ma.client)
+	ma.Config = cfg
+	ma.client = Instrument(client, ma.telemetry, cfg)
+	ma.sources = allMetrics
+	ma.collectors = collectors
+	ma.mu.Unlock()
+
+	notify(ma.updateReload)
+	notify(ma.sendReload)
+	return nil
+}
+
+func (ma *MetricAgent) getConfig() *config.AgentConfig {
+	ma.mu.RLock()
+	defer ma.mu.RUnlock()
+
+	return ma.Config
+}
+
+func (ma *MetricAgent) getClient() Client {
+	ma.mu.RLock()
+	defer ma.mu.RUnlock()
+
+	return ma.client
+}
+
+func (ma *MetricAgent) getSources() ([]metric.UpdatableMetric, []metric.Collector) {
+	ma.mu.RLock()
+	defer ma.mu.RUnlock()
+
+	return ma.sources, ma.collectors
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+func (ma *MetricAgent) update(metricUpdate *metricUpdate) {
+	ticker := time.NewTicker(ma.getConfig().PollInterval)
 	defer ticker.Stop()
 	for {
+		allMetrics, collectors := ma.getSources()
 		for i := range allMetrics {
 			allMetrics[i].Update()
 		}
 
-		metricUpdate.set(allMetrics)
+		updated := make([]metric.UpdatableMetric, len(allMetrics))
+		copy(updated, allMetrics)
+		for _, c := range collectors {
+			start := time.Now()
+			updated = append(updated, c.Collect()...)
+			ma.telemetry.ObserveCollect(c.Name(), time.Since(start))
+		}
+
+		metricUpdate.set(ma.getConfig().Relabel.ApplyUpdatable(updated))
 
 		select {
 		case <-ticker.C:
 			continue
+		case <-ma.updateReload:
+			ticker.Reset(ma.getConfig().PollInterval)
+			continue
 		case <-ma.Ctx.Done():
 			ma.Wg.Done()
 			return
@@ -184,37 +463,78 @@ func (ma *MetricAgent) update(allMetrics []metric.UpdatableMetric, metricUpdate
 }
 
 func (ma *MetricAgent) send(metricUpdate *metricUpdate) {
-	ticker := time.NewTicker(ma.Config.ReportInterval)
+	ticker := time.NewTicker(ma.getConfig().ReportInterval)
 	defer ticker.Stop()
 	for {
+		cfg := ma.getConfig()
+		ma.retired.close(cfg.Logger)
+		client := ma.getClient()
 		updated := metricUpdate.get()
 		for i := range updated {
-			updated[i].SetHash(ma.Config.Key)
+			updated[i].SetHash(cfg.Key)
 			ma.buffer = append(ma.buffer, updated[i])
 
 			if cap(ma.buffer) == len(ma.buffer) {
-				err := ma.client.PostMetric(ma.buffer)
+				err := client.PostMetric(ma.buffer)
 				if err != nil {
-					ma.Config.Logger.Error().Err(err).Msg("agent: failed to send update request")
+					cfg.Logger.Error().Err(err).Msg("agent: failed to send update request")
 				}
 				ma.buffer = ma.buffer[:0]
 			}
 		}
+		ma.telemetry.SetQueueDepth(len(ma.buffer))
 
+		if !ma.waitSend(ticker) {
+			return
+		}
+	}
+}
+
+// waitSend ожидает следующей отправки метрик. При перезагрузке конфигурации интервал отправки сбрасывается,
+// а неотправленные метрики подписываются заново, ключ мог измениться.
+// Возвращает false, если агент остановлен
+func (ma *MetricAgent) waitSend(ticker *time.Ticker) bool {
+	for {
 		select {
 		case <-ticker.C:
-			continue
+			return true
+		case <-ma.sendReload:
+			cfg := ma.getConfig()
+			for i := range ma.buffer {
+				ma.buffer[i].SetHash(cfg.Key)
+			}
+			ticker.Reset(cfg.ReportInterval)
 		case <-ma.Ctx.Done():
-			err := ma.client.PostMetric(ma.buffer)
+			cfg, client := ma.getConfig(), ma.getClient()
+			ma.retired.close(cfg.Logger)
+			err := client.PostMetric(ma.buffer)
 			if err != nil {
-				ma.Config.Logger.Error().Err(err).Msg("agent: failed to send update request")
+				cfg.Logger.Error().Err(err).Msg("agent: failed to send update request")
+			}
+			if err := client.Close(); err != nil {
+				cfg.Logger.Error().Err(err).Msg("agent: failed to close client")
 			}
 			ma.Wg.Done()
-			return
+			return false
 		}
 	}
 }
 
+// instanceID идентификатор процесса агента из имени хоста и времени запуска. По нему сервер различает
+// накопительные счетчики нескольких агентов на одном хосте
+var instanceID = newInstanceID()
+
+func newInstanceID() string {
+	hostname, _ := os.Hostname()
+	return hostname + "-" + strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// getClientID возвращает идентификатор агента, по которому сервер может ограничивать частоту запросов
+func getClientID() string {
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
 func getPreferredHostIP(target string) string {
 	targetURL, err := url.Parse(target)
 	if err != nil {
@@ -253,14 +573,20 @@ func getPreferredHostIP(target string) string {
 }
 
 // SendAllMetricsContinuously метод инкапсулирует периодическое обновление и отправку метрик
-// На вход получает слайс с сырыми метриками и последовательно обновляет каждую
-func (ma *MetricAgent) SendAllMetricsContinuously(allMetrics []metric.UpdatableMetric) {
+// На вход получает слайс с сырыми метриками и последовательно обновляет каждую.
+// Дополнительно при каждом обновлении опрашиваются сборщики с изменяемым набором метрик
+func (ma *MetricAgent) SendAllMetricsContinuously(allMetrics []metric.UpdatableMetric, collectors ...metric.Collector) {
 	mUpdate := &metricUpdate{
 		mu:    new(sync.RWMutex),
 		value: make([]metric.UpdatableMetric, 0),
 	}
 
-	go ma.update(allMetrics, mUpdate)
+	ma.mu.Lock()
+	ma.sources = allMetrics
+	ma.collectors = collectors
+	ma.mu.Unlock()
+
+	go ma.update(mUpdate)
 
 	time.AfterFunc(10*time.Millisecond, func() {
 		ma.send(mUpdate)
@@ -5,15 +5,25 @@ import (
 	"context"
 	"encoding/json"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/c0dered273/go-adv-metrics/internal/config"
 	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/model"
+	"github.com/c0dered273/go-adv-metrics/internal/service"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 func TestMetricClient_SendUpdateContinuously(t *testing.T) {
@@ -80,7 +90,7 @@ func TestMetricClient_SendUpdateContinuously(t *testing.T) {
 			}
 
 			upd := metric.ConcatSources([]metric.UpdatableMetric{tt.metric})
-			metricClient, _ := NewMetricAgent(ctx, &wg, cfg)
+			metricClient, _ := NewMetricAgent(ctx, &wg, cfg, nil)
 			metricClient.SendAllMetricsContinuously(upd)
 
 			time.Sleep(20 * time.Millisecond)
@@ -88,3 +98,257 @@ func TestMetricClient_SendUpdateContinuously(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricAgent_Reload(t *testing.T) {
+	type received struct {
+		mu      sync.Mutex
+		metrics []metric.Metric
+	}
+	newServer := func(r *received) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			var batch []metric.Metric
+			if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+				panic(err)
+			}
+			r.mu.Lock()
+			r.metrics = append(r.metrics, batch...)
+			r.mu.Unlock()
+		}))
+	}
+	names := func(r *received, hashKey string) []string {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		var result []string
+		for _, m := range r.metrics {
+			if ok, _ := m.CheckHash(hashKey); ok {
+				result = append(result, m.GetName())
+			}
+		}
+		return result
+	}
+	gauges := func(ids ...string) []metric.UpdatableMetric {
+		result := make([]metric.UpdatableMetric, len(ids))
+		for i, id := range ids {
+			result[i] = metric.NewUpdatableGauge(id, func() float64 { return 1 })
+		}
+		return result
+	}
+
+	oldReceived, newReceived := &received{}, &received{}
+	oldSrv, newSrv := newServer(oldReceived), newServer(newReceived)
+	defer oldSrv.Close()
+	defer newSrv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	oldCfg := &config.AgentConfig{
+		AgentInParams: &config.AgentInParams{
+			Address:        oldSrv.URL,
+			Key:            "old",
+			ReportInterval: time.Hour,
+			PollInterval:   time.Hour,
+		},
+	}
+	agent, err := NewMetricAgent(ctx, &wg, oldCfg, nil)
+	if err != nil {
+		panic(err)
+	}
+	agent.SendAllMetricsContinuously(gauges("Old1", "Old2", "Old3"))
+
+	assert.Eventually(t, func() bool {
+		return len(names(oldReceived, "old")) == 3
+	}, time.Second, 10*time.Millisecond)
+
+	newCfg := &config.AgentConfig{
+		AgentInParams: &config.AgentInParams{
+			Address:        newSrv.URL,
+			Key:            "new",
+			ReportInterval: 50 * time.Millisecond,
+			PollInterval:   time.Hour,
+		},
+	}
+	assert.NoError(t, agent.Reload(newCfg, gauges("New1", "New2", "New3")))
+
+	assert.Eventually(t, func() bool {
+		got := names(newReceived, "new")
+		return len(got) >= 3 && assert.ObjectsAreEqual([]string{"New1", "New2", "New3"}, got[len(got)-3:])
+	}, time.Second, 10*time.Millisecond, "metrics from new sources should be signed with new key and sent to new address")
+	assert.Len(t, names(oldReceived, "old"), 3, "old server should not receive metrics after reload")
+
+	cancel()
+	wg.Wait()
+}
+
+func TestMetricAgent_Telemetry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	cfg := &config.AgentConfig{
+		AgentInParams: &config.AgentInParams{
+			Address:        srv.URL,
+			ReportInterval: time.Hour,
+			PollInterval:   time.Hour,
+		},
+	}
+	telemetry := metric.NewTelemetry()
+	agent, err := NewMetricAgent(ctx, &wg, cfg, telemetry)
+	if err != nil {
+		panic(err)
+	}
+	gauge := func() float64 { return 1 }
+	agent.SendAllMetricsContinuously(nil, metric.NewSourceCollector("test", []metric.UpdatableMetric{
+		metric.NewUpdatableGauge("First", gauge),
+		metric.NewUpdatableGauge("Second", gauge),
+		metric.NewUpdatableGauge("Third", gauge),
+	}))
+
+	collected := func() map[string]string {
+		result := make(map[string]string)
+		for _, m := range telemetry.Collect() {
+			result[m.GetName()] = m.GetStringValue()
+		}
+		return result
+	}
+	destination := "http_" + strings.TrimPrefix(srv.URL, "http://")
+	destination = strings.NewReplacer(".", "_", ":", "_").Replace(destination)
+
+	assert.Eventually(t, func() bool {
+		return collected()["AgentSendFailures_"+destination] == "1"
+	}, 30*time.Second, 10*time.Millisecond, "server errors should be counted as failures")
+
+	got := collected()
+	assert.Equal(t, "3", got["AgentDroppedMetrics"])
+	assert.Equal(t, "0", got["AgentSendSuccess_"+destination])
+	assert.Equal(t, "0", got["AgentQueueDepth"])
+	assert.Contains(t, got, "AgentCollectDuration_test")
+
+	cancel()
+	wg.Wait()
+}
+
+// fakeSaveStreamServer подтверждает корзины, отклоняет корзины с метрикой Bad, не подтверждает корзины
+// с метрикой Hang и закрывает поток на метрике Drop. Если задан hold, подтверждения отправляются сразу
+// после получения hold корзин
+type fakeSaveStreamServer struct {
+	service.UnimplementedMetricsServiceServer
+	streams atomic.Int32
+	hold    int
+}
+
+func (s *fakeSaveStreamServer) SaveStream(srv service.MetricsService_SaveStreamServer) error {
+	s.streams.Add(1)
+	var held []*model.SaveAck
+	for {
+		batch, err := srv.Recv()
+		if err != nil {
+			return nil
+		}
+		ack := &model.SaveAck{Seq: batch.GetSeq()}
+		switch batch.GetMetrics()[0].GetId() {
+		case "Bad":
+			ack.Code = int32(codes.InvalidArgument)
+			ack.Message = "invalid metric"
+		case "Hang":
+			continue
+		case "Drop":
+			return status.Error(codes.Unavailable, "stream closed")
+		}
+
+		held = append(held, ack)
+		if len(held) < s.hold {
+			continue
+		}
+		for _, a := range held {
+			if err = srv.Send(a); err != nil {
+				return err
+			}
+		}
+		held = held[:0]
+	}
+}
+
+// newStreamClient возвращает клиента, отправляющего метрики потоком на fake
+func newStreamClient(t *testing.T, fake *fakeSaveStreamServer, window int) *GRPCClient {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	service.RegisterMetricsServiceServer(server, fake)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+
+	return &GRPCClient{
+		ctx: context.Background(),
+		cfg: &config.AgentConfig{
+			AgentInParams: &config.AgentInParams{Address: "http://localhost:3200", GRPCClient: true, GRPCStream: true},
+		},
+		conn:         conn,
+		metricClient: service.NewMetricsServiceClient(conn),
+		mu:           new(sync.Mutex),
+		window:       window,
+		ackTimeout:   200 * time.Millisecond,
+	}
+}
+
+func streamBatch(id string) []metric.UpdatableMetric {
+	return []metric.UpdatableMetric{metric.NewUpdatableGauge(id, func() float64 { return 1 })}
+}
+
+func TestGRPCClient_PostStream(t *testing.T) {
+	fake := &fakeSaveStreamServer{}
+	client := newStreamClient(t, fake, 1)
+	defer client.Close()
+
+	tests := []struct {
+		name        string
+		id          string
+		wantCode    codes.Code
+		wantStreams int32
+	}{
+		{name: "should open stream and save batch", id: "Alloc", wantCode: codes.OK, wantStreams: 1},
+		{name: "should reuse stream", id: "HeapAlloc", wantCode: codes.OK, wantStreams: 1},
+		{name: "should return rejected batch error and keep stream", id: "Bad", wantCode: codes.InvalidArgument, wantStreams: 1},
+		{name: "should return error when stream is closed", id: "Drop", wantCode: codes.Unavailable, wantStreams: 1},
+		{name: "should reopen stream", id: "Alloc", wantCode: codes.OK, wantStreams: 2},
+		{name: "should return error when ack is not received in time", id: "Hang", wantCode: codes.DeadlineExceeded, wantStreams: 2},
+		{name: "should reopen stream after ack timeout", id: "Alloc", wantCode: codes.OK, wantStreams: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.PostMetric(streamBatch(tt.id))
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantStreams, fake.streams.Load())
+		})
+	}
+}
+
+func TestGRPCClient_PostStreamWindow(t *testing.T) {
+	fake := &fakeSaveStreamServer{hold: 3}
+	client := newStreamClient(t, fake, 3)
+
+	// Сервер подтверждает корзины только после третьей, первые отправки не должны ждать подтверждения
+	assert.NoError(t, client.PostMetric(streamBatch("Bad")))
+	assert.NoError(t, client.PostMetric(streamBatch("Alloc")))
+	err := client.PostMetric(streamBatch("HeapAlloc"))
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "rejected batch should be reported when its ack is received")
+
+	assert.NoError(t, client.Close(), "close should receive acks of remaining batches")
+	assert.Empty(t, client.inflight)
+	assert.Equal(t, int32(1), fake.streams.Load())
+}
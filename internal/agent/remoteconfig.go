@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/model"
+	"github.com/c0dered273/go-adv-metrics/internal/relabel"
+	"github.com/c0dered273/go-adv-metrics/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RemoteConfig запрашивает у сервера настройки группы агента
+type RemoteConfig struct {
+	hostname string
+	reload   chan struct{}
+
+	mu     *sync.RWMutex
+	cfg    *config.AgentConfig
+	conn   *grpc.ClientConn
+	client service.AgentConfigServiceClient
+}
+
+// NewRemoteConfig открывает gRPC соединение с сервером для получения настроек
+func NewRemoteConfig(cfg *config.AgentConfig) (*RemoteConfig, error) {
+	conn, err := dialGRPC(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+
+	return &RemoteConfig{
+		hostname: hostname,
+		reload:   make(chan struct{}, 1),
+		mu:       new(sync.RWMutex),
+		cfg:      cfg,
+		conn:     conn,
+		client:   service.NewAgentConfigServiceClient(conn),
+	}, nil
+}
+
+// Reload применяет новую конфигурацию агента: группу, интервал опроса и адрес сервера.
+// Если изменились адрес сервера или корневой сертификат, открывается новое соединение, а прежнее закрывается.
+// Отключение получения настроек требует перезапуска агента, до тех пор сохраняется прежний интервал
+func (r *RemoteConfig) Reload(cfg *config.AgentConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cfg.Address != r.cfg.Address || cfg.CACertFile != r.cfg.CACertFile {
+		conn, err := dialGRPC(cfg)
+		if err != nil {
+			return err
+		}
+		_ = r.conn.Close()
+		r.conn, r.client = conn, service.NewAgentConfigServiceClient(conn)
+	}
+	if cfg.RemoteConfigInterval <= 0 {
+		params := *cfg.AgentInParams
+		params.RemoteConfigInterval = r.cfg.RemoteConfigInterval
+		applied := *cfg
+		applied.AgentInParams = &params
+		cfg = &applied
+	}
+	r.cfg = cfg
+
+	notify(r.reload)
+	return nil
+}
+
+func (r *RemoteConfig) settings() (*config.AgentConfig, service.AgentConfigServiceClient) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.cfg, r.client
+}
+
+// Fetch запрашивает текущие настройки группы агента
+func (r *RemoteConfig) Fetch(ctx context.Context) (*model.AgentRemoteConfig, error) {
+	cfg, client := r.settings()
+	md := metadata.New(map[string]string{
+		"X-Real-IP":   getPreferredHostIP(cfg.Address),
+		"X-Client-ID": getClientID(),
+	})
+	outCtx, cancel := context.WithTimeout(metadata.NewOutgoingContext(ctx, md), connTimeout)
+	defer cancel()
+
+	return client.GetConfig(outCtx, &model.AgentConfigRequest{
+		Group:    cfg.Group,
+		Hostname: r.hostname,
+	})
+}
+
+// Watch периодически запрашивает настройки и отдает их в канал, только если они изменились
+// по сравнению с last. Канал закрывается, а соединение с сервером освобождается после отмены контекста
+func (r *RemoteConfig) Watch(ctx context.Context, last *model.AgentRemoteConfig) <-chan *model.AgentRemoteConfig {
+	updates := make(chan *model.AgentRemoteConfig)
+	go func() {
+		defer close(updates)
+		defer func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			_ = r.conn.Close()
+		}()
+
+		cfg, _ := r.settings()
+		ticker := time.NewTicker(cfg.RemoteConfigInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				remote, err := r.Fetch(ctx)
+				if err != nil {
+					cfg, _ := r.settings()
+					cfg.Logger.Error().Err(err).Msg("agent: failed to fetch remote configuration")
+					continue
+				}
+				if last != nil && last.GetVersion() == remote.GetVersion() {
+					continue
+				}
+				last = remote
+
+				select {
+				case updates <- remote:
+				case <-ctx.Done():
+					return
+				}
+			case <-r.reload:
+				cfg, _ := r.settings()
+				ticker.Reset(cfg.RemoteConfigInterval)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return updates
+}
+
+// ApplyRemoteConfig возвращает копию локальной конфигурации агента, дополненную настройками группы с сервера.
+// Параметры, не заданные на сервере, остаются локальными
+func ApplyRemoteConfig(local *config.AgentConfig, remote *model.AgentRemoteConfig) *config.AgentConfig {
+	if remote == nil {
+		return local
+	}
+
+	params := *local.AgentInParams
+	if d := remote.GetPollInterval(); d != nil {
+		params.PollInterval = d.AsDuration()
+	}
+	if d := remote.GetReportInterval(); d != nil {
+		params.ReportInterval = d.AsDuration()
+	}
+	if len(remote.GetCollectors()) > 0 {
+		params.Collectors = remote.GetCollectors()
+	}
+	if len(remote.GetDiskMountPointsInclude()) > 0 {
+		params.DiskMountPointsInclude = remote.GetDiskMountPointsInclude()
+	}
+	if len(remote.GetDiskMountPointsExclude()) > 0 {
+		params.DiskMountPointsExclude = remote.GetDiskMountPointsExclude()
+	}
+	if len(remote.GetDiskFsTypesInclude()) > 0 {
+		params.DiskFSTypesInclude = remote.GetDiskFsTypesInclude()
+	}
+	if len(remote.GetDiskFsTypesExclude()) > 0 {
+		params.DiskFSTypesExclude = remote.GetDiskFsTypesExclude()
+	}
+	if len(remote.GetNetInterfacesInclude()) > 0 {
+		params.NetInterfacesInclude = remote.GetNetInterfacesInclude()
+	}
+	if len(remote.GetNetInterfacesExclude()) > 0 {
+		params.NetInterfacesExclude = remote.GetNetInterfacesExclude()
+	}
+
+	applied := *local
+	applied.AgentInParams = &params
+	if len(remote.GetRelabelRules()) > 0 {
+		params.RelabelRules = make([]relabel.Rule, len(remote.GetRelabelRules()))
+		for i, r := range remote.GetRelabelRules() {
+			params.RelabelRules[i] = relabel.Rule{
+				Action:      r.GetAction(),
+				Regex:       r.GetRegex(),
+				Replacement: r.GetReplacement(),
+				Prefix:      r.GetPrefix(),
+			}
+		}
+		// Некорректные правила оставляют прежний набор, ошибку находит проверка конфигурации перед применением
+		if pipeline, err := relabel.New(params.RelabelRules); err == nil {
+			applied.Relabel = pipeline
+		}
+	}
+	return &applied
+}
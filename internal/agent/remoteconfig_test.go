@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/relabel"
+	"github.com/c0dered273/go-adv-metrics/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyRemoteConfig(t *testing.T) {
+	local := &config.AgentConfig{
+		AgentInParams: &config.AgentInParams{
+			Address:              "http://localhost:8080",
+			PollInterval:         2 * time.Second,
+			ReportInterval:       10 * time.Second,
+			NetInterfacesExclude: []string{"lo"},
+		},
+	}
+	remote := service.ToRemoteConfig("db", config.AgentGroupConfig{
+		ReportInterval:     time.Minute,
+		Collectors:         []string{config.CollectorMem, config.CollectorDisk},
+		DiskFSTypesExclude: []string{"tmpfs"},
+		RelabelRules:       []relabel.Rule{{Action: relabel.ActionDrop, Regex: "Go.*"}},
+	})
+
+	applied := ApplyRemoteConfig(local, remote)
+
+	assert.Equal(t, 2*time.Second, applied.PollInterval, "unset remote settings should stay local")
+	assert.Equal(t, time.Minute, applied.ReportInterval)
+	assert.Equal(t, []string{"tmpfs"}, applied.DiskFSTypesExclude)
+	assert.Equal(t, []string{"lo"}, applied.NetInterfacesExclude)
+	assert.True(t, applied.IsCollectorEnabled(config.CollectorDisk))
+	assert.False(t, applied.IsCollectorEnabled(config.CollectorProcess))
+	_, keep := applied.Relabel.Relabel("GoRoutines")
+	assert.False(t, keep, "remote relabel rules should be applied")
+	_, keep = local.Relabel.Relabel("GoRoutines")
+	assert.True(t, keep)
+
+	assert.Equal(t, 10*time.Second, local.ReportInterval, "local configuration should not be modified")
+	assert.True(t, local.IsCollectorEnabled(config.CollectorProcess))
+	assert.Same(t, local, ApplyRemoteConfig(local, nil))
+}
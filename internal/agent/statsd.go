@@ -0,0 +1,332 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+)
+
+// Максимальный размер UDP пакета StatsD
+const statsDPacketSize = 65535
+
+// Перцентили, которые рассчитываются для таймеров
+var statsDPercentiles = []float64{50, 90, 99}
+
+// StatsDListener принимает метрики в формате StatsD по UDP и агрегирует их за интервал отправки.
+// Счетчики суммируются, для gauge сохраняется последнее значение, для таймеров считается сводка
+// (count, sum, min, max, mean и перцентили), для множеств - количество уникальных значений.
+// Агрегированные метрики отправляются на сервер корзинами через Client
+type StatsDListener struct {
+	Ctx    context.Context
+	Wg     *sync.WaitGroup
+	Config *config.AgentConfig
+	client Client
+	// retired клиенты, замененные при перезагрузке, закрываются при следующей отправке
+	retired retiredClients
+	conn    net.PacketConn
+	reload  chan struct{}
+
+	mu       *sync.Mutex
+	counters map[string]float64
+	// remainders дробные остатки счетчиков с учетом частоты выборки, переносятся в следующий интервал
+	remainders map[string]float64
+	gauges     map[string]float64
+	timers     map[string][]float64
+	sets       map[string]map[string]struct{}
+}
+
+// NewStatsDListener открывает UDP порт для приема метрик StatsD
+func NewStatsDListener(ctx context.Context, wg *sync.WaitGroup, cfg *config.AgentConfig, client Client) (*StatsDListener, error) {
+	conn, err := net.ListenPacket("udp", cfg.StatsDAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &StatsDListener{
+		Ctx:        ctx,
+		Wg:         wg,
+		Config:     cfg,
+		client:     client,
+		conn:       conn,
+		reload:     make(chan struct{}, 1),
+		mu:         new(sync.Mutex),
+		remainders: make(map[string]float64),
+	}
+	s.reset()
+
+	return s, nil
+}
+
+// Addr возвращает адрес, на котором принимаются метрики
+func (s *StatsDListener) Addr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+// Listen запускает прием пакетов и периодическую отправку агрегатов на сервер
+func (s *StatsDListener) Listen() {
+	go s.read()
+	go s.flushContinuously()
+}
+
+// Reload заменяет настройки отправки агрегатов на сервер.
+// Адрес приема метрик при этом не меняется, для его смены нужен перезапуск агента
+func (s *StatsDListener) Reload(cfg *config.AgentConfig, client Client) {
+	s.mu.Lock()
+	s.retired.add(s.client)
+	s.Config = cfg
+	s.client = client
+	s.mu.Unlock()
+
+	notify(s.reload)
+}
+
+func (s *StatsDListener) settings() (*config.AgentConfig, Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Config, s.client
+}
+
+func (s *StatsDListener) reset() {
+	s.counters = make(map[string]float64)
+	s.gauges = make(map[string]float64)
+	s.timers = make(map[string][]float64)
+	s.sets = make(map[string]map[string]struct{})
+}
+
+func (s *StatsDListener) read() {
+	buf := make([]byte, statsDPacketSize)
+	for {
+		n, _, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			cfg, _ := s.settings()
+			cfg.Logger.Error().Err(err).Msg("statsd: failed to read packet")
+			continue
+		}
+
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if err := s.handleLine(line); err != nil {
+				cfg, _ := s.settings()
+				cfg.Logger.Error().Err(err).Str("line", line).Msg("statsd: failed to parse metric")
+			}
+		}
+	}
+}
+
+func (s *StatsDListener) flushContinuously() {
+	cfg, _ := s.settings()
+	ticker := time.NewTicker(cfg.ReportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.flush()
+		case <-s.reload:
+			cfg, _ = s.settings()
+			ticker.Reset(cfg.ReportInterval)
+		case <-s.Ctx.Done():
+			_ = s.conn.Close()
+			s.flush()
+			cfg, client := s.settings()
+			s.retired.close(cfg.Logger)
+			if err := client.Close(); err != nil {
+				cfg.Logger.Error().Err(err).Msg("statsd: failed to close client")
+			}
+			s.Wg.Done()
+			return
+		}
+	}
+}
+
+// flush отправляет накопленные за интервал агрегаты и начинает новый интервал
+func (s *StatsDListener) flush() {
+	cfg, client := s.settings()
+	s.retired.close(cfg.Logger)
+	aggregated := cfg.Relabel.ApplyUpdatable(s.aggregate())
+	if len(aggregated) == 0 {
+		return
+	}
+
+	for start := 0; start < len(aggregated); start += BufferLen {
+		end := start + BufferLen
+		if end > len(aggregated) {
+			end = len(aggregated)
+		}
+		batch := aggregated[start:end]
+		for i := range batch {
+			batch[i].SetHash(cfg.Key)
+		}
+
+		if err := client.PostMetric(batch); err != nil {
+			cfg.Logger.Error().Err(err).Msg("statsd: failed to send update request")
+		}
+	}
+}
+
+// statsDMetric разобранная строка протокола StatsD вида name:value|type[|@rate][|#tags]
+type statsDMetric struct {
+	name       string
+	value      string
+	mType      string
+	sampleRate float64
+}
+
+func parseStatsDLine(line string) (statsDMetric, error) {
+	m := statsDMetric{sampleRate: 1}
+
+	parts := strings.Split(line, "|")
+	if len(parts) < 2 {
+		return m, fmt.Errorf("statsd: missing metric type in %q", line)
+	}
+
+	colon := strings.LastIndex(parts[0], ":")
+	if colon <= 0 {
+		return m, fmt.Errorf("statsd: invalid line %q", line)
+	}
+	m.name = strings.TrimSpace(parts[0][:colon])
+	m.value = strings.TrimSpace(parts[0][colon+1:])
+	m.mType = strings.TrimSpace(parts[1])
+
+	for _, p := range parts[2:] {
+		if strings.HasPrefix(p, "@") {
+			rate, err := strconv.ParseFloat(p[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return m, fmt.Errorf("statsd: invalid sample rate in %q", line)
+			}
+			m.sampleRate = rate
+		}
+	}
+
+	return m, nil
+}
+
+func (s *StatsDListener) handleLine(line string) error {
+	m, err := parseStatsDLine(line)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch m.mType {
+	case "c":
+		v, err := strconv.ParseFloat(m.value, 64)
+		if err != nil {
+			return err
+		}
+		s.counters[m.name] += v / m.sampleRate
+	case "g":
+		v, err := strconv.ParseFloat(m.value, 64)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(m.value, "+") || strings.HasPrefix(m.value, "-") {
+			s.gauges[m.name] += v
+		} else {
+			s.gauges[m.name] = v
+		}
+	case "ms", "h":
+		v, err := strconv.ParseFloat(m.value, 64)
+		if err != nil {
+			return err
+		}
+		s.timers[m.name] = append(s.timers[m.name], v)
+	case "s":
+		if _, ok := s.sets[m.name]; !ok {
+			s.sets[m.name] = make(map[string]struct{})
+		}
+		s.sets[m.name][m.value] = struct{}{}
+	default:
+		return fmt.Errorf("statsd: unknown metric type %q", m.mType)
+	}
+
+	return nil
+}
+
+// aggregate превращает накопленные значения в метрики и сбрасывает счетчики, таймеры и множества.
+// Значения gauge сохраняются между интервалами, как это принято в StatsD. Счетчик отправляется целой частью
+// суммы, дробный остаток от частоты выборки прибавляется к счетчику в следующем интервале
+func (s *StatsDListener) aggregate() []metric.UpdatableMetric {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]metric.UpdatableMetric, 0)
+	for _, name := range sortedKeys(s.counters) {
+		total := s.counters[name] + s.remainders[name]
+		whole := math.Trunc(total)
+		s.remainders[name] = total - whole
+		result = append(result, toUpdatable(metric.NewCounterMetric(name, int64(whole))))
+	}
+	for _, name := range sortedKeys(s.gauges) {
+		result = append(result, toUpdatable(metric.NewGaugeMetric(name, s.gauges[name])))
+	}
+	for _, name := range sortedKeys(s.timers) {
+		result = append(result, summarize(name, s.timers[name])...)
+	}
+	for _, name := range sortedKeys(s.sets) {
+		result = append(result, toUpdatable(metric.NewGaugeMetric(name, float64(len(s.sets[name])))))
+	}
+
+	gauges := s.gauges
+	s.reset()
+	s.gauges = gauges
+
+	return result
+}
+
+func summarize(name string, values []float64) []metric.UpdatableMetric {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+
+	result := []metric.UpdatableMetric{
+		toUpdatable(metric.NewCounterMetric(name+"_count", int64(len(sorted)))),
+		toUpdatable(metric.NewGaugeMetric(name+"_sum", sum)),
+		toUpdatable(metric.NewGaugeMetric(name+"_min", sorted[0])),
+		toUpdatable(metric.NewGaugeMetric(name+"_max", sorted[len(sorted)-1])),
+		toUpdatable(metric.NewGaugeMetric(name+"_mean", sum/float64(len(sorted)))),
+	}
+	for _, p := range statsDPercentiles {
+		idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		result = append(result, toUpdatable(metric.NewGaugeMetric(fmt.Sprintf("%s_p%d", name, int(p)), sorted[idx])))
+	}
+	return result
+}
+
+func toUpdatable(m metric.Metric) metric.UpdatableMetric {
+	return metric.UpdatableMetric{Metric: m}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
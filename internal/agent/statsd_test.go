@@ -0,0 +1,231 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/fleet"
+	"github.com/c0dered273/go-adv-metrics/internal/handler"
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClient struct {
+	mu         sync.Mutex
+	batches    [][]metric.UpdatableMetric
+	heartbeats []fleet.Heartbeat
+	closed     bool
+}
+
+func (c *testClient) SendHeartbeat(heartbeat fleet.Heartbeat) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.heartbeats = append(c.heartbeats, heartbeat)
+	return nil
+}
+
+func (c *testClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *testClient) isClosed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+func (c *testClient) PostMetric(metrics []metric.UpdatableMetric) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	batch := make([]metric.UpdatableMetric, len(metrics))
+	copy(batch, metrics)
+	c.batches = append(c.batches, batch)
+	return nil
+}
+
+func (c *testClient) sent() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []string
+	for _, b := range c.batches {
+		for _, m := range b {
+			result = append(result, m.String())
+		}
+	}
+	return result
+}
+
+func TestParseStatsDLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    statsDMetric
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "should parse counter with sample rate",
+			line:    "app.requests:1|c|@0.5",
+			want:    statsDMetric{name: "app.requests", value: "1", mType: "c", sampleRate: 0.5},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "should parse timer with tags",
+			line:    "app.latency:320|ms|#env:prod",
+			want:    statsDMetric{name: "app.latency", value: "320", mType: "ms", sampleRate: 1},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "should fail without type",
+			line:    "app.requests:1",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on invalid sample rate",
+			line:    "app.requests:1|c|@2",
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStatsDLine(tt.line)
+			if !tt.wantErr(t, err) || err != nil {
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestStatsDListener_Flush(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+
+	cfg := &config.AgentConfig{
+		AgentInParams: &config.AgentInParams{
+			ReportInterval: time.Hour,
+			StatsDAddress:  "127.0.0.1:0",
+		},
+	}
+	client := &testClient{}
+	listener, err := NewStatsDListener(ctx, &wg, cfg, client)
+	if err != nil {
+		panic(err)
+	}
+	wg.Add(1)
+	listener.Listen()
+
+	conn, err := net.Dial("udp", listener.Addr().String())
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+
+	packets := []string{
+		"hits:1|c\nhits:2|c",
+		"hits:1|c|@0.5",
+		"temp:20|g\ntemp:+5|g",
+		"latency:10|ms\nlatency:30|ms\nlatency:20|ms",
+		"users:alice|s\nusers:bob|s\nusers:alice|s",
+	}
+	for _, p := range packets {
+		if _, err := conn.Write([]byte(p)); err != nil {
+			panic(err)
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		listener.mu.Lock()
+		defer listener.mu.Unlock()
+		return len(listener.sets["users"]) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	listener.flush()
+	assert.Equal(t, []string{
+		"/counter/hits/5",
+		"/gauge/temp/25",
+		"/counter/latency_count/3",
+		"/gauge/latency_sum/60",
+		"/gauge/latency_min/10",
+		"/gauge/latency_max/30",
+		"/gauge/latency_mean/20",
+		"/gauge/latency_p50/20",
+		"/gauge/latency_p90/30",
+		"/gauge/latency_p99/30",
+		"/gauge/users/2",
+	}, client.sent())
+
+	for _, b := range client.batches {
+		assert.LessOrEqual(t, len(b), BufferLen)
+	}
+
+	client.batches = nil
+	listener.flush()
+	assert.Equal(t, []string{"/gauge/temp/25"}, client.sent(), "only gauges should survive the interval")
+
+	cancel()
+	wg.Wait()
+}
+
+func TestStatsDListener_FlushToServer(t *testing.T) {
+	srvCfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{},
+		Repo:           storage.NewPersistenceRepo(storage.NewMemStorage()),
+	}
+	srv := httptest.NewServer(handler.Service(srvCfg))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	cfg := &config.AgentConfig{
+		AgentInParams: &config.AgentInParams{
+			Address:        srv.URL,
+			ReportInterval: time.Hour,
+			StatsDAddress:  "127.0.0.1:0",
+		},
+	}
+	client, err := NewHTTPClient(ctx, cfg)
+	require.NoError(t, err)
+	listener, err := NewStatsDListener(ctx, &wg, cfg, client)
+	require.NoError(t, err)
+	wg.Add(1)
+	listener.Listen()
+
+	conn, err := net.Dial("udp", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// Приращения из разных интервалов складываются на сервере,
+	// дробные остатки от частоты выборки не теряются: 3 * 1/0.3 = 10
+	for i := 0; i < 3; i++ {
+		_, err = conn.Write([]byte("hits:2|c\ndrops:1|c|@0.3"))
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			listener.mu.Lock()
+			defer listener.mu.Unlock()
+			return listener.counters["drops"] > 0
+		}, time.Second, 10*time.Millisecond)
+		listener.flush()
+	}
+
+	for name, want := range map[string]int64{"hits": 6, "drops": 10} {
+		m, err := srvCfg.Repo.FindByID(context.Background(), metric.NewCounterMetric(name, 0))
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, want, m.GetCounterValue(), name)
+		}
+	}
+
+	cancel()
+	wg.Wait()
+}
@@ -0,0 +1,306 @@
+package alert
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/query"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+// Состояния оповещения
+const (
+	StateInactive = "inactive"
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+)
+
+// Alert состояние оповещения по правилу
+type Alert struct {
+	Rule        string     `json:"rule"`
+	Description string     `json:"description,omitempty"`
+	State       string     `json:"state"`
+	Value       *float64   `json:"value,omitempty"`
+	ActiveSince *time.Time `json:"active_since,omitempty"`
+	FiredAt     *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+	EvaluatedAt time.Time  `json:"evaluated_at"`
+	// LastUpdate время последней записи метрики для правил Absent, пусто если метрику
+	// не записывали с запуска сервера
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+}
+
+type ruleState struct {
+	alert    Alert
+	created  time.Time
+	lastEval time.Time
+}
+
+// Engine проверяет правила по метрикам из хранилища. Методы безопасно вызывать у nil
+type Engine struct {
+	mu      *sync.RWMutex
+	repo    storage.Repository
+	history *query.History
+	logger  zerolog.Logger
+	rules   []compiledRule
+	states  map[string]*ruleState
+	// notifiers рассылают изменения состояния на webhook
+	notifiers []*notifier
+	// writes время последней записи каждой метрики, обновляется Observe
+	writesMu *sync.Mutex
+	writes   map[string]time.Time
+	now      func() time.Time
+}
+
+// NewEngine возвращает движок без правил, правила задаются SetRules.
+// Функции над окном, например rate, вычисляются по истории значений history
+func NewEngine(repo storage.Repository, history *query.History, logger zerolog.Logger) *Engine {
+	return &Engine{
+		mu:       new(sync.RWMutex),
+		repo:     repo,
+		history:  history,
+		logger:   logger,
+		states:   make(map[string]*ruleState),
+		writesMu: new(sync.Mutex),
+		writes:   make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Observe запоминает время записи принятых метрик, по нему проверяются правила Absent.
+// Время хранится только в памяти, после перезапуска сервера отсчет идет от первой проверки правила
+func (e *Engine) Observe(metrics []metric.Metric) {
+	if e == nil || len(metrics) == 0 {
+		return
+	}
+	now := e.now()
+	e.writesMu.Lock()
+	defer e.writesMu.Unlock()
+
+	for _, m := range metrics {
+		e.writes[m.ID] = now
+	}
+}
+
+func (e *Engine) lastWrite(id string) (time.Time, bool) {
+	e.writesMu.Lock()
+	defer e.writesMu.Unlock()
+
+	t, ok := e.writes[id]
+	return t, ok
+}
+
+// SetRules заменяет набор правил. Состояние правил, которые не изменились, сохраняется
+func (e *Engine) SetRules(rs *RuleSet) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	states := make(map[string]*ruleState, len(rs.rules))
+	for _, r := range rs.rules {
+		if st, ok := e.states[r.Name]; ok && e.ruleUnchanged(r.Rule) {
+			states[r.Name] = st
+		}
+	}
+	e.states = states
+	e.rules = rs.rules
+}
+
+func (e *Engine) ruleUnchanged(rule Rule) bool {
+	for _, r := range e.rules {
+		if r.Rule == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// SetWebhooks заменяет адресатов уведомлений. Накопленные изменения, очередь и время повтора
+// сохраняются для адресатов, настройки которых не изменились, рассылка на удаленных адресатов останавливается
+func (e *Engine) SetWebhooks(ws *Webhooks) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	notifiers := make([]*notifier, 0, len(ws.webhooks))
+	kept := make(map[*notifier]struct{}, len(ws.webhooks))
+	for _, w := range ws.webhooks {
+		var n *notifier
+		for _, prev := range e.notifiers {
+			if _, ok := kept[prev]; !ok && prev.Webhook == w {
+				n = prev
+				break
+			}
+		}
+		if n == nil {
+			n = newNotifier(w, e.logger)
+		}
+		kept[n] = struct{}{}
+		notifiers = append(notifiers, n)
+	}
+	for _, prev := range e.notifiers {
+		if _, ok := kept[prev]; !ok {
+			prev.stop()
+		}
+	}
+	e.notifiers = notifiers
+}
+
+// Evaluate проверяет все правила по текущим значениям метрик и ставит в очередь уведомления о переходах
+// в состояния firing и resolved. Уведомления отправляются в фоне, метод не ждет их доставки
+func (e *Engine) Evaluate(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.rules) == 0 {
+		return nil
+	}
+
+	metrics, err := e.repo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	now := e.now()
+	env := &query.Env{Values: query.Values(metrics), History: e.history, Now: now}
+	var changed, firing []Alert
+	for _, r := range e.rules {
+		st, ok := e.states[r.Name]
+		if !ok {
+			st = &ruleState{
+				alert:   Alert{Rule: r.Name, Description: r.Description, State: StateInactive},
+				created: now,
+			}
+			e.states[r.Name] = st
+		}
+
+		active, value, err := r.cond.eval(env)
+		if err != nil {
+			e.logger.Warn().Err(err).Str("rule", r.Name).Msg("alert: failed to evaluate rule")
+		}
+		since := now
+		if a, ok := r.cond.(*absent); ok {
+			// Условие выполняется, если с прошлой проверки метрику не записывали,
+			// и выполняется с момента последней записи
+			since = st.created
+			st.alert.LastUpdate = nil
+			if written, ok := e.lastWrite(a.metric); ok {
+				since = written
+				st.alert.LastUpdate = &written
+			}
+			active = !since.After(st.lastEval)
+		}
+		st.lastEval = now
+
+		prev := st.alert.State
+		st.alert.Value = value
+		st.alert.EvaluatedAt = now
+		transition(&st.alert, r.For, active, since, now)
+		if prev != st.alert.State {
+			e.logger.Info().
+				Str("rule", r.Name).
+				Str("from", prev).
+				Str("to", st.alert.State).
+				Msg("alert: state changed")
+			if st.alert.State == StateFiring || st.alert.State == StateResolved {
+				changed = append(changed, st.alert)
+			}
+		}
+		if st.alert.State == StateFiring {
+			firing = append(firing, st.alert)
+		}
+	}
+
+	for _, n := range e.notifiers {
+		if notification := n.collect(changed, firing, now); notification != nil {
+			n.enqueue(notification)
+		}
+	}
+	return nil
+}
+
+// transition переводит оповещение в следующее состояние. since - время, с которого выполняется условие
+func transition(a *Alert, forDuration time.Duration, active bool, since time.Time, now time.Time) {
+	if !active {
+		switch a.State {
+		case StateFiring:
+			a.State = StateResolved
+			a.ResolvedAt = &now
+		case StatePending:
+			a.State = StateInactive
+			a.ActiveSince = nil
+		}
+		return
+	}
+
+	if a.State == StateInactive || a.State == StateResolved {
+		a.State = StatePending
+		a.ActiveSince = &since
+		a.FiredAt = nil
+		a.ResolvedAt = nil
+	}
+	if a.State == StatePending && now.Sub(*a.ActiveSince) >= forDuration {
+		a.State = StateFiring
+		a.FiredAt = &now
+	}
+}
+
+// Run проверяет правила с заданным интервалом до отмены контекста, нулевой интервал отключает проверку.
+// После отмены контекста рассылка уведомлений останавливается
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	if e == nil || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer e.stopNotifiers()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.Evaluate(ctx); err != nil {
+				e.logger.Error().Err(err).Msg("alert: failed to evaluate rules")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *Engine) stopNotifiers() {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, n := range e.notifiers {
+		n.stop()
+	}
+}
+
+// Alerts возвращает состояние всех проверенных правил, отсортированное по имени правила
+func (e *Engine) Alerts() []Alert {
+	result := make([]Alert, 0)
+	if e == nil {
+		return result
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, st := range e.states {
+		result = append(result, st.alert)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Rule < result[j].Rule
+	})
+	return result
+}
@@ -0,0 +1,179 @@
+package alert
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/query"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_Evaluate(t *testing.T) {
+	type step struct {
+		wait    time.Duration
+		metrics []metric.Metric
+		want    string
+	}
+	tests := []struct {
+		name  string
+		rule  Rule
+		steps []step
+	}{
+		{
+			name: "should fire threshold alert after for duration and resolve",
+			rule: Rule{Name: "LowMemory", Expr: "FreeMemory < 1e9", For: 5 * time.Minute},
+			steps: []step{
+				{metrics: []metric.Metric{metric.NewGaugeMetric("FreeMemory", 2e9)}, want: StateInactive},
+				{wait: time.Minute, metrics: []metric.Metric{metric.NewGaugeMetric("FreeMemory", 5e8)}, want: StatePending},
+				{wait: 4 * time.Minute, want: StatePending},
+				{wait: time.Minute, want: StateFiring},
+				{wait: time.Minute, metrics: []metric.Metric{metric.NewGaugeMetric("FreeMemory", 3e9)}, want: StateResolved},
+			},
+		},
+		{
+			name: "should return to inactive when condition stops before for duration",
+			rule: Rule{Name: "LowMemory", Expr: "FreeMemory <= 1e9", For: 5 * time.Minute},
+			steps: []step{
+				{metrics: []metric.Metric{metric.NewGaugeMetric("FreeMemory", 1e9)}, want: StatePending},
+				{wait: time.Minute, metrics: []metric.Metric{metric.NewGaugeMetric("FreeMemory", 2e9)}, want: StateInactive},
+			},
+		},
+		{
+			name: "should fire immediately without for duration",
+			rule: Rule{Name: "TooManyPolls", Expr: "PollCount > 10"},
+			steps: []step{
+				{metrics: []metric.Metric{metric.NewCounterMetric("PollCount", 11)}, want: StateFiring},
+			},
+		},
+		{
+			name: "should fire on expression with rate",
+			rule: Rule{Name: "SlowPolling", Expr: "rate(PollCount[1m]) * 60 < Threshold"},
+			steps: []step{
+				{metrics: []metric.Metric{metric.NewCounterMetric("PollCount", 10), metric.NewGaugeMetric("Threshold", 20)}, want: StateInactive},
+				{wait: 30 * time.Second, metrics: []metric.Metric{metric.NewCounterMetric("PollCount", 10)}, want: StateInactive},
+				{wait: 30 * time.Second, metrics: []metric.Metric{metric.NewCounterMetric("PollCount", 5)}, want: StateFiring},
+			},
+		},
+		{
+			name: "should fire absent alert when metric is not written",
+			rule: Rule{Name: "AgentDown", Absent: "PollCount", For: 2 * time.Minute},
+			steps: []step{
+				{metrics: []metric.Metric{metric.NewCounterMetric("PollCount", 1)}, want: StateInactive},
+				{wait: time.Minute, metrics: []metric.Metric{metric.NewCounterMetric("PollCount", 2)}, want: StateInactive},
+				{wait: time.Minute, want: StatePending},
+				{wait: time.Minute, want: StateFiring},
+				{wait: time.Minute, metrics: []metric.Metric{metric.NewCounterMetric("PollCount", 3)}, want: StateResolved},
+			},
+		},
+		{
+			name: "should not fire absent alert when unchanged value is written",
+			rule: Rule{Name: "AgentDown", Absent: "Threshold", For: 2 * time.Minute},
+			steps: []step{
+				{metrics: []metric.Metric{metric.NewGaugeMetric("Threshold", 20)}, want: StateInactive},
+				{wait: time.Minute, metrics: []metric.Metric{metric.NewGaugeMetric("Threshold", 20)}, want: StateInactive},
+				{wait: time.Minute, metrics: []metric.Metric{metric.NewGaugeMetric("Threshold", 20)}, want: StateInactive},
+				{wait: time.Minute, metrics: []metric.Metric{metric.NewGaugeMetric("Threshold", 20)}, want: StateInactive},
+			},
+		},
+		{
+			name: "should fire absent alert for missing metric",
+			rule: Rule{Name: "AgentDown", Absent: "PollCount", For: 2 * time.Minute},
+			steps: []step{
+				{want: StateInactive},
+				{wait: time.Minute, want: StatePending},
+				{wait: time.Minute, want: StateFiring},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := storage.NewPersistenceRepo(storage.NewMemStorage())
+			history := query.NewHistory(time.Minute)
+			engine := NewEngine(repo, history, zerolog.Nop())
+			now := time.Now()
+			engine.now = func() time.Time { return now }
+
+			rules, err := NewRuleSet([]Rule{tt.rule})
+			assert.NoError(t, err)
+			engine.SetRules(rules)
+
+			for i, s := range tt.steps {
+				now = now.Add(s.wait)
+				assert.NoError(t, repo.SaveAll(context.Background(), s.metrics))
+				engine.Observe(s.metrics)
+				assert.NoError(t, history.Sample(context.Background(), repo, now))
+				assert.NoError(t, engine.Evaluate(context.Background()))
+
+				alerts := engine.Alerts()
+				assert.Len(t, alerts, 1)
+				assert.Equal(t, s.want, alerts[0].State, "step %d", i)
+			}
+		})
+	}
+}
+
+func TestEngine_SetRules(t *testing.T) {
+	repo := storage.NewPersistenceRepo(storage.NewMemStorage())
+	assert.NoError(t, repo.Save(context.Background(), metric.NewGaugeMetric("FreeMemory", 5e8)))
+	engine := NewEngine(repo, nil, zerolog.Nop())
+
+	lowMemory := Rule{Name: "LowMemory", Expr: "FreeMemory < 1e9"}
+	rules, err := NewRuleSet([]Rule{lowMemory, {Name: "HighMemory", Expr: "FreeMemory > 1e10"}})
+	assert.NoError(t, err)
+	engine.SetRules(rules)
+	assert.NoError(t, engine.Evaluate(context.Background()))
+	firedAt := engine.Alerts()[1].FiredAt
+
+	rules, err = NewRuleSet([]Rule{lowMemory})
+	assert.NoError(t, err)
+	engine.SetRules(rules)
+
+	alerts := engine.Alerts()
+	assert.Len(t, alerts, 1)
+	assert.Equal(t, StateFiring, alerts[0].State)
+	assert.Equal(t, firedAt, alerts[0].FiredAt, "unchanged rule should keep its state")
+}
+
+func TestNewRuleSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "should accept threshold and absent rules",
+			rules:   []Rule{{Name: "LowMemory", Expr: "FreeMemory<1e9"}, {Name: "AgentDown", Absent: "PollCount"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "should accept query expression",
+			rules:   []Rule{{Name: "HighCPU", Expr: `avg(~"CPUutilization.*") >= 90`}, {Name: "HeapUsage", Expr: "HeapInuse / HeapSys > 0.9"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "should fail on invalid expression",
+			rules:   []Rule{{Name: "LowMemory", Expr: "FreeMemory is low"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on duplicate names",
+			rules:   []Rule{{Name: "AgentDown", Absent: "PollCount"}, {Name: "AgentDown", Absent: "Alloc"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail without condition",
+			rules:   []Rule{{Name: "AgentDown"}},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRuleSet(tt.rules)
+			tt.wantErr(t, err)
+		})
+	}
+}
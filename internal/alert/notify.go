@@ -0,0 +1,239 @@
+package alert
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// SignatureHeader заголовок с HMAC-SHA256 подписью тела уведомления в hex
+	SignatureHeader = "X-Alert-Signature"
+
+	webhookTimeout           = 10 * time.Second
+	defaultWebhookRetries    = 3
+	defaultWebhookRetryWait  = time.Second
+	defaultWebhookMaxBackoff = 30 * time.Second
+	// notificationQueueSize сколько уведомлений ждут отправки на один webhook, пока предыдущее не доставлено
+	notificationQueueSize = 16
+	// noWebhookRetries значение MaxRetries, отключающее повторные попытки
+	noWebhookRetries = -1
+)
+
+// Webhook адресат уведомлений об изменении состояния оповещений
+type Webhook struct {
+	URL string `mapstructure:"url" json:"url"`
+	// Secret ключ подписи тела уведомления, пустой ключ отключает подпись
+	Secret string `mapstructure:"secret" json:"secret,omitempty"`
+	// MaxRetries количество повторных попыток при ошибке сети или ответе 5xx. Нулевое значение заменяется
+	// значением по умолчанию, -1 отключает повторные попытки
+	MaxRetries int `mapstructure:"max_retries" json:"max_retries"`
+	// RetryWait и RetryMaxWait задают экспоненциальную задержку между попытками
+	RetryWait    time.Duration `mapstructure:"retry_wait" json:"retry_wait"`
+	RetryMaxWait time.Duration `mapstructure:"retry_max_wait" json:"retry_max_wait"`
+	// GroupInterval минимальный интервал между уведомлениями, изменения за интервал отправляются одним уведомлением
+	GroupInterval time.Duration `mapstructure:"group_interval" json:"group_interval"`
+	// RepeatInterval как часто повторять уведомление об активном оповещении, нулевое значение отключает повтор
+	RepeatInterval time.Duration `mapstructure:"repeat_interval" json:"repeat_interval"`
+}
+
+// Webhooks проверенный набор адресатов уведомлений
+type Webhooks struct {
+	webhooks []Webhook
+}
+
+// NewWebhooks проверяет адреса и интервалы, незаданные параметры повторных попыток заполняет значениями по умолчанию.
+// MaxRetries -1 сохраняется как есть и означает отправку без повторов
+func NewWebhooks(webhooks []Webhook) (*Webhooks, error) {
+	ws := &Webhooks{webhooks: make([]Webhook, 0, len(webhooks))}
+	for i, w := range webhooks {
+		u, err := url.Parse(w.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook %d: invalid url %q", i, w.URL)
+		}
+		if w.MaxRetries < noWebhookRetries {
+			return nil, fmt.Errorf("webhook %s: max_retries must be -1 to disable retries or not negative", w.URL)
+		}
+		if w.RetryWait < 0 || w.RetryMaxWait < 0 || w.GroupInterval < 0 || w.RepeatInterval < 0 {
+			return nil, fmt.Errorf("webhook %s: retries and intervals must not be negative", w.URL)
+		}
+		if w.MaxRetries == 0 {
+			w.MaxRetries = defaultWebhookRetries
+		}
+		if w.RetryWait == 0 {
+			w.RetryWait = defaultWebhookRetryWait
+		}
+		if w.RetryMaxWait == 0 {
+			w.RetryMaxWait = defaultWebhookMaxBackoff
+		}
+		ws.webhooks = append(ws.webhooks, w)
+	}
+	return ws, nil
+}
+
+// Notification тело уведомления
+type Notification struct {
+	Firing   int       `json:"firing"`
+	Resolved int       `json:"resolved"`
+	Alerts   []Alert   `json:"alerts"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+// Sign возвращает подпись тела уведомления ключом secret
+func Sign(body []byte, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// notifier состояние рассылки на один webhook. Уведомления отправляются по очереди из отдельной горутины,
+// чтобы медленный адресат не задерживал проверку правил
+type notifier struct {
+	Webhook
+	client *resty.Client
+	logger zerolog.Logger
+	// pending изменения состояния, накопленные до следующей отправки
+	pending map[string]Alert
+	// lastSent время последнего уведомления об активных оповещениях
+	lastSent  map[string]time.Time
+	lastFlush time.Time
+	queue     chan *Notification
+	cancel    context.CancelFunc
+}
+
+// newNotifier запускает горутину отправки, которая работает до вызова stop
+func newNotifier(w Webhook, logger zerolog.Logger) *notifier {
+	retries := w.MaxRetries
+	if retries == noWebhookRetries {
+		retries = 0
+	}
+	client := resty.New().
+		SetTimeout(webhookTimeout).
+		SetRetryCount(retries).
+		SetRetryWaitTime(w.RetryWait).
+		SetRetryMaxWaitTime(w.RetryMaxWait).
+		AddRetryCondition(func(r *resty.Response, err error) bool {
+			return err != nil || r.StatusCode() >= http.StatusInternalServerError
+		})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &notifier{
+		Webhook:  w,
+		client:   client,
+		logger:   logger,
+		pending:  make(map[string]Alert),
+		lastSent: make(map[string]time.Time),
+		queue:    make(chan *Notification, notificationQueueSize),
+		cancel:   cancel,
+	}
+	go n.run(ctx)
+	return n
+}
+
+// run отправляет уведомления из очереди до отмены контекста.
+// Уведомление, которое не удалось отправить после всех попыток, отбрасывается
+func (n *notifier) run(ctx context.Context) {
+	for {
+		select {
+		case notification := <-n.queue:
+			if err := n.send(ctx, notification); err != nil {
+				n.logger.Error().Err(err).Str("url", n.URL).Msg("alert: failed to send notification")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// enqueue ставит уведомление в очередь без ожидания, при переполненной очереди уведомление отбрасывается
+func (n *notifier) enqueue(notification *Notification) {
+	select {
+	case n.queue <- notification:
+	default:
+		n.logger.Error().Str("url", n.URL).Msg("alert: notification queue is full, notification dropped")
+	}
+}
+
+// stop прерывает текущую отправку и останавливает горутину, уведомления в очереди отбрасываются
+func (n *notifier) stop() {
+	n.cancel()
+}
+
+// collect добавляет изменения к накопленным и возвращает уведомление, если пора отправлять.
+// changed - оповещения, перешедшие в firing или resolved, firing - все активные оповещения
+func (n *notifier) collect(changed []Alert, firing []Alert, now time.Time) *Notification {
+	for _, a := range changed {
+		n.pending[a.Rule] = a
+	}
+
+	active := make(map[string]struct{}, len(firing))
+	for _, a := range firing {
+		active[a.Rule] = struct{}{}
+		sent, ok := n.lastSent[a.Rule]
+		if _, queued := n.pending[a.Rule]; !queued && ok && n.RepeatInterval > 0 && now.Sub(sent) >= n.RepeatInterval {
+			n.pending[a.Rule] = a
+		}
+	}
+	for rule := range n.lastSent {
+		if _, ok := active[rule]; !ok {
+			delete(n.lastSent, rule)
+		}
+	}
+
+	if len(n.pending) == 0 || now.Sub(n.lastFlush) < n.GroupInterval {
+		return nil
+	}
+
+	notification := &Notification{Alerts: make([]Alert, 0, len(n.pending)), SentAt: now}
+	for _, a := range n.pending {
+		notification.Alerts = append(notification.Alerts, a)
+		if a.State == StateFiring {
+			notification.Firing++
+			n.lastSent[a.Rule] = now
+		} else {
+			notification.Resolved++
+			delete(n.lastSent, a.Rule)
+		}
+	}
+	sort.Slice(notification.Alerts, func(i, j int) bool {
+		return notification.Alerts[i].Rule < notification.Alerts[j].Rule
+	})
+	n.pending = make(map[string]Alert)
+	n.lastFlush = now
+	return notification
+}
+
+// send отправляет уведомление, повторяя попытки при ошибке сети или ответе 5xx
+func (n *notifier) send(ctx context.Context, notification *Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	req := n.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(body)
+	if n.Secret != "" {
+		req.SetHeader(SignatureHeader, Sign(body, n.Secret))
+	}
+
+	resp, err := req.Post(n.URL)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return fmt.Errorf("unexpected status %s", resp.Status())
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// receiver принимает уведомления, первые failures запросов отвечает ошибкой
+type receiver struct {
+	mu            sync.Mutex
+	secret        string
+	failures      int
+	attempts      int
+	notifications []Notification
+	t             *testing.T
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.attempts++
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	assert.NoError(rc.t, err)
+	if rc.secret != "" {
+		assert.Equal(rc.t, Sign(body, rc.secret), r.Header.Get(SignatureHeader))
+	}
+	var n Notification
+	assert.NoError(rc.t, json.Unmarshal(body, &n))
+	rc.notifications = append(rc.notifications, n)
+}
+
+func (rc *receiver) attemptCount() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return rc.attempts
+}
+
+// states возвращает состояния оповещений в каждом уведомлении в виде "rule:state"
+func (rc *receiver) states() [][]string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	result := make([][]string, len(rc.notifications))
+	for i, n := range rc.notifications {
+		for _, a := range n.Alerts {
+			result[i] = append(result[i], a.Rule+":"+a.State)
+		}
+	}
+	return result
+}
+
+func TestEngine_Notify(t *testing.T) {
+	low := metric.NewGaugeMetric("FreeMemory", 5e8)
+	high := metric.NewGaugeMetric("FreeMemory", 3e9)
+	type step struct {
+		wait    time.Duration
+		metrics []metric.Metric
+	}
+	tests := []struct {
+		name     string
+		webhook  Webhook
+		failures int
+		steps    []step
+		want     [][]string
+	}{
+		{
+			name:    "should notify firing and resolved alerts",
+			webhook: Webhook{Secret: "secret"},
+			steps: []step{
+				{metrics: []metric.Metric{low}},
+				{wait: time.Minute},
+				{wait: time.Minute, metrics: []metric.Metric{high}},
+			},
+			want: [][]string{{"HighPollCount:firing", "LowMemory:firing"}, {"LowMemory:resolved"}},
+		},
+		{
+			name:     "should retry failed notification",
+			webhook:  Webhook{MaxRetries: 2, RetryWait: time.Millisecond, RetryMaxWait: time.Millisecond},
+			failures: 2,
+			steps:    []step{{metrics: []metric.Metric{low}}},
+			want:     [][]string{{"HighPollCount:firing", "LowMemory:firing"}},
+		},
+		{
+			name:     "should not retry when retries are disabled",
+			webhook:  Webhook{MaxRetries: -1, RetryWait: time.Millisecond, RetryMaxWait: time.Millisecond},
+			failures: 1,
+			steps:    []step{{metrics: []metric.Metric{low}}},
+			want:     [][]string{},
+		},
+		{
+			name:    "should group changes within group interval",
+			webhook: Webhook{GroupInterval: 5 * time.Minute},
+			steps: []step{
+				{metrics: []metric.Metric{high}},
+				{wait: time.Minute, metrics: []metric.Metric{low}},
+				{wait: time.Minute, metrics: []metric.Metric{high}},
+				{wait: time.Minute},
+				{wait: 3 * time.Minute},
+			},
+			want: [][]string{{"HighPollCount:firing"}, {"LowMemory:resolved"}},
+		},
+		{
+			name:    "should repeat firing alerts",
+			webhook: Webhook{RepeatInterval: 2 * time.Minute},
+			steps: []step{
+				{metrics: []metric.Metric{high}},
+				{wait: time.Minute},
+				{wait: time.Minute},
+				{wait: time.Minute},
+			},
+			want: [][]string{{"HighPollCount:firing"}, {"HighPollCount:firing"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &receiver{secret: tt.webhook.Secret, failures: tt.failures, t: t}
+			srv := httptest.NewServer(rc)
+			defer srv.Close()
+
+			repo := storage.NewPersistenceRepo(storage.NewMemStorage())
+			assert.NoError(t, repo.Save(context.Background(), metric.NewCounterMetric("PollCount", 100)))
+			engine := NewEngine(repo, nil, zerolog.Nop())
+			now := time.Now()
+			engine.now = func() time.Time { return now }
+
+			rules, err := NewRuleSet([]Rule{
+				{Name: "LowMemory", Expr: "FreeMemory < 1e9"},
+				{Name: "HighPollCount", Expr: "PollCount > 10"},
+			})
+			assert.NoError(t, err)
+			engine.SetRules(rules)
+
+			tt.webhook.URL = srv.URL
+			webhooks, err := NewWebhooks([]Webhook{tt.webhook})
+			assert.NoError(t, err)
+			engine.SetWebhooks(webhooks)
+
+			for _, s := range tt.steps {
+				now = now.Add(s.wait)
+				assert.NoError(t, repo.SaveAll(context.Background(), s.metrics))
+				assert.NoError(t, engine.Evaluate(context.Background()))
+			}
+			assert.Eventually(t, func() bool {
+				return assert.ObjectsAreEqual(tt.want, rc.states())
+			}, time.Second, 10*time.Millisecond)
+			wantAttempts := len(tt.want) + tt.failures
+			assert.Eventually(t, func() bool {
+				return rc.attemptCount() == wantAttempts
+			}, time.Second, 10*time.Millisecond)
+			assert.Never(t, func() bool {
+				return rc.attemptCount() > wantAttempts
+			}, 50*time.Millisecond, 10*time.Millisecond, "should not make extra attempts")
+		})
+	}
+}
+
+func TestEngine_NotifyDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	repo := storage.NewPersistenceRepo(storage.NewMemStorage())
+	assert.NoError(t, repo.Save(context.Background(), metric.NewGaugeMetric("FreeMemory", 5e8)))
+	engine := NewEngine(repo, nil, zerolog.Nop())
+	now := time.Now()
+	engine.now = func() time.Time { return now }
+
+	rules, err := NewRuleSet([]Rule{{Name: "LowMemory", Expr: "FreeMemory < 1e9"}})
+	assert.NoError(t, err)
+	engine.SetRules(rules)
+	webhooks, err := NewWebhooks([]Webhook{{URL: srv.URL}})
+	assert.NoError(t, err)
+	engine.SetWebhooks(webhooks)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < notificationQueueSize+2; i++ {
+			now = now.Add(time.Minute)
+			assert.NoError(t, repo.Save(context.Background(), metric.NewGaugeMetric("FreeMemory", float64(i%2)*3e9)))
+			assert.NoError(t, engine.Evaluate(context.Background()))
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("evaluate should not wait for webhook delivery")
+	}
+
+	empty, err := NewWebhooks(nil)
+	assert.NoError(t, err)
+	engine.SetWebhooks(empty)
+}
+
+func TestNewWebhooks(t *testing.T) {
+	tests := []struct {
+		name           string
+		webhooks       []Webhook
+		wantMaxRetries int
+		wantErr        assert.ErrorAssertionFunc
+	}{
+		{
+			name:           "should accept http url and set default retries",
+			webhooks:       []Webhook{{URL: "http://localhost:9093/alerts", Secret: "secret"}},
+			wantMaxRetries: defaultWebhookRetries,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:           "should keep disabled retries",
+			webhooks:       []Webhook{{URL: "http://localhost:9093/alerts", MaxRetries: -1}},
+			wantMaxRetries: -1,
+			wantErr:        assert.NoError,
+		},
+		{
+			name:     "should fail on url without scheme",
+			webhooks: []Webhook{{URL: "localhost:9093/alerts"}},
+			wantErr:  assert.Error,
+		},
+		{
+			name:     "should fail on negative interval",
+			webhooks: []Webhook{{URL: "http://localhost:9093/alerts", RepeatInterval: -time.Minute}},
+			wantErr:  assert.Error,
+		},
+		{
+			name:     "should fail on retries below -1",
+			webhooks: []Webhook{{URL: "http://localhost:9093/alerts", MaxRetries: -2}},
+			wantErr:  assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, err := NewWebhooks(tt.webhooks)
+			if !tt.wantErr(t, err) || err != nil {
+				return
+			}
+			assert.Equal(t, tt.wantMaxRetries, ws.webhooks[0].MaxRetries)
+		})
+	}
+}
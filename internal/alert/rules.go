@@ -0,0 +1,179 @@
+// Package alert периодически проверяет условия правил по сохраненным метрикам и отслеживает состояние оповещений.
+// Правило срабатывает, если условие выполняется дольше заданного времени: сначала оповещение ожидает (pending),
+// затем активно (firing), а после того как условие перестало выполняться, считается разрешенным (resolved)
+package alert
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/query"
+)
+
+// Rule правило оповещения. Задается либо условие Expr - сравнение двух выражений query,
+// например "FreeMemory < 1e9" или "rate(PollCount[1m]) < 0.1", либо имя метрики Absent,
+// которую не должны долго оставлять без записи. Запись с тем же значением считается обновлением.
+// Время записи хранится в памяти, после перезапуска сервера отсутствие отсчитывается от первой проверки
+type Rule struct {
+	Name        string        `mapstructure:"name" json:"name"`
+	Expr        string        `mapstructure:"expr" json:"expr,omitempty"`
+	Absent      string        `mapstructure:"absent" json:"absent,omitempty"`
+	For         time.Duration `mapstructure:"for" json:"for"`
+	Description string        `mapstructure:"description" json:"description,omitempty"`
+}
+
+// condition проверяет правило по текущим значениям метрик
+type condition interface {
+	// eval возвращает выполняется ли условие и значение метрики, nil если метрики нет
+	eval(env *query.Env) (bool, *float64, error)
+}
+
+var comparisonOps = []string{"<=", ">=", "==", "!=", "<", ">"}
+
+// comparison сравнение значений двух выражений. Условие не выполняется, если у выражения нет значения
+type comparison struct {
+	lhs, rhs *query.Expr
+	op       string
+}
+
+func parseComparison(expr string) (*comparison, error) {
+	lhs, op, rhs, ok := splitComparison(expr)
+	if !ok {
+		return nil, fmt.Errorf("invalid expression %q, expected <expr> <op> <expr>", expr)
+	}
+
+	c := &comparison{op: op}
+	var err error
+	if c.lhs, err = query.Parse(lhs); err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", lhs, err)
+	}
+	if c.rhs, err = query.Parse(rhs); err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", rhs, err)
+	}
+	return c, nil
+}
+
+// splitComparison делит выражение по первому оператору сравнения вне строк в кавычках
+func splitComparison(expr string) (string, string, string, bool) {
+	quoted := false
+	for i := 0; i < len(expr); i++ {
+		switch {
+		case expr[i] == '\\' && quoted:
+			i++
+		case expr[i] == '"':
+			quoted = !quoted
+		case !quoted:
+			for _, op := range comparisonOps {
+				if strings.HasPrefix(expr[i:], op) {
+					return expr[:i], op, expr[i+len(op):], true
+				}
+			}
+		}
+	}
+	return "", "", "", false
+}
+
+func (c *comparison) eval(env *query.Env) (bool, *float64, error) {
+	v, err := c.lhs.Eval(env)
+	if errors.Is(err, query.ErrNoData) {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	threshold, err := c.rhs.Eval(env)
+	if errors.Is(err, query.ErrNoData) {
+		return false, &v, nil
+	}
+	if err != nil {
+		return false, &v, err
+	}
+
+	switch c.op {
+	case "<":
+		return v < threshold, &v, nil
+	case "<=":
+		return v <= threshold, &v, nil
+	case ">":
+		return v > threshold, &v, nil
+	case ">=":
+		return v >= threshold, &v, nil
+	case "==":
+		return v == threshold, &v, nil
+	default:
+		return v != threshold, &v, nil
+	}
+}
+
+// absent условие отсутствия обновлений. Условие отдает только значение метрики,
+// время последней записи отслеживает Engine по принятым метрикам, см. Engine.Observe
+type absent struct {
+	metric string
+}
+
+func (a *absent) eval(env *query.Env) (bool, *float64, error) {
+	v, ok := env.Values[a.metric]
+	if !ok {
+		return false, nil, nil
+	}
+	return false, &v, nil
+}
+
+type compiledRule struct {
+	Rule
+	cond condition
+}
+
+// RuleSet проверенный набор правил
+type RuleSet struct {
+	rules  []compiledRule
+	window time.Duration
+}
+
+// NewRuleSet проверяет правила: имена должны быть уникальны, у каждого правила ровно одно условие
+func NewRuleSet(rules []Rule) (*RuleSet, error) {
+	rs := &RuleSet{rules: make([]compiledRule, 0, len(rules))}
+	names := make(map[string]struct{}, len(rules))
+	for i, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("rule %d: name is required", i)
+		}
+		if _, ok := names[r.Name]; ok {
+			return nil, fmt.Errorf("rule %s: duplicate name", r.Name)
+		}
+		names[r.Name] = struct{}{}
+		if r.For < 0 {
+			return nil, fmt.Errorf("rule %s: for must not be negative", r.Name)
+		}
+
+		var cond condition
+		switch {
+		case r.Expr != "" && r.Absent != "":
+			return nil, fmt.Errorf("rule %s: only one of expr and absent can be set", r.Name)
+		case r.Expr != "":
+			c, err := parseComparison(r.Expr)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+			}
+			for _, e := range []*query.Expr{c.lhs, c.rhs} {
+				if e.Window() > rs.window {
+					rs.window = e.Window()
+				}
+			}
+			cond = c
+		case r.Absent != "":
+			cond = &absent{metric: r.Absent}
+		default:
+			return nil, fmt.Errorf("rule %s: expr or absent is required", r.Name)
+		}
+		rs.rules = append(rs.rules, compiledRule{Rule: r, cond: cond})
+	}
+	return rs, nil
+}
+
+// Window возвращает наибольшее окно выражений, сколько истории значений нужно для проверки правил
+func (rs *RuleSet) Window() time.Duration {
+	return rs.window
+}
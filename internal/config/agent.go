@@ -4,10 +4,14 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/relabel"
 	"github.com/rs/zerolog"
 	"github.com/spf13/pflag"
 )
@@ -21,7 +25,20 @@ var (
 	// CRYPTO_KEY - имя файла с публичным RSA ключом, должен соответствовать приватному ключу сервера
 	// CONFIG - имя файла конфигурации в формате json
 	// GRPC_CLIENT - использовать gRPC для передачи метрик
+	// GRPC_STREAM - передавать метрики по gRPC в одном долгоживущем потоке вместо отдельного вызова на каждую корзину
 	// CA_CERT_FILE - файл с корневым сертификатом
+	// DISK_MOUNT_POINTS_INCLUDE, DISK_MOUNT_POINTS_EXCLUDE - glob шаблоны точек монтирования через запятую
+	// DISK_FS_TYPES_INCLUDE, DISK_FS_TYPES_EXCLUDE - glob шаблоны типов файловых систем через запятую
+	// NET_INTERFACES_INCLUDE, NET_INTERFACES_EXCLUDE - glob шаблоны сетевых интерфейсов через запятую
+	// EXEC_DIR - каталог со скриптами, выводящими пользовательские метрики
+	// EXEC_TIMEOUT - максимальное время работы одного скрипта
+	// PROMETHEUS_TARGETS - адреса, отдающие метрики в формате Prometheus, через запятую
+	// PROMETHEUS_TIMEOUT - максимальное время опроса одной цели Prometheus
+	// STATSD_ADDRESS - адрес:порт для приема метрик StatsD по UDP, если не задан - прием отключен
+	// COLLECTORS - включенные сборщики метрик через запятую, по умолчанию включены все
+	// AGENT_GROUP - группа агента, настройки которой запрашиваются у сервера
+	// REMOTE_CONFIG_INTERVAL - интервал запроса настроек группы у сервера по gRPC, если не задан - настройки не запрашиваются
+	// HEARTBEAT_INTERVAL - интервал отправки heartbeat на сервер, 0 - heartbeat отключен
 	agentEnvVars = []string{
 		"ADDRESS",
 		"REPORT_INTERVAL",
@@ -30,8 +47,28 @@ var (
 		"CRYPTO_KEY",
 		"CONFIG",
 		"GRPC_CLIENT",
+		"GRPC_STREAM",
 		"CA_CERT_FILE",
+		"DISK_MOUNT_POINTS_INCLUDE",
+		"DISK_MOUNT_POINTS_EXCLUDE",
+		"DISK_FS_TYPES_INCLUDE",
+		"DISK_FS_TYPES_EXCLUDE",
+		"NET_INTERFACES_INCLUDE",
+		"NET_INTERFACES_EXCLUDE",
+		"EXEC_DIR",
+		"EXEC_TIMEOUT",
+		"PROMETHEUS_TARGETS",
+		"PROMETHEUS_TIMEOUT",
+		"STATSD_ADDRESS",
+		"COLLECTORS",
+		"AGENT_GROUP",
+		"REMOTE_CONFIG_INTERVAL",
+		"HEARTBEAT_INTERVAL",
 	}
+
+	// Флаги командной строки разбираются один раз, при перечитывании конфигурации используются сохраненные значения
+	agentPFlagOnce   sync.Once
+	agentPFlagParams Params
 )
 
 type AgentConfigFileParams struct {
@@ -40,7 +77,30 @@ type AgentConfigFileParams struct {
 	PollInterval      time.Duration `json:"poll_interval"`
 	PublicKeyFileName string        `json:"crypto_key"`
 	GRPCClient        bool          `json:"grpc_client"`
+	GRPCStream        bool          `json:"grpc_stream"`
 	CACertFile        string        `json:"ca_cert_file"`
+
+	DiskMountPointsInclude []string `json:"disk_mount_points_include"`
+	DiskMountPointsExclude []string `json:"disk_mount_points_exclude"`
+	DiskFSTypesInclude     []string `json:"disk_fs_types_include"`
+	DiskFSTypesExclude     []string `json:"disk_fs_types_exclude"`
+	NetInterfacesInclude   []string `json:"net_interfaces_include"`
+	NetInterfacesExclude   []string `json:"net_interfaces_exclude"`
+
+	ProcessRules []metric.ProcessRule `json:"process_rules"`
+	ExecDir      string               `json:"exec_dir"`
+	ExecTimeout  time.Duration        `json:"exec_timeout"`
+
+	PrometheusTargets []string      `json:"prometheus_targets"`
+	PrometheusTimeout time.Duration `json:"prometheus_timeout"`
+	StatsDAddress     string        `json:"statsd_address"`
+
+	Collectors           []string      `json:"collectors"`
+	Group                string        `json:"group"`
+	RemoteConfigInterval time.Duration `json:"remote_config_interval"`
+	HeartbeatInterval    time.Duration `json:"heartbeat_interval"`
+
+	RelabelRules []relabel.Rule `json:"relabel_rules"`
 }
 
 type AgentInParams struct {
@@ -51,11 +111,87 @@ type AgentInParams struct {
 	PublicKeyFileName string        `mapstructure:"crypto_key"`
 	ConfigFileName    string        `mapstructure:"config"`
 	GRPCClient        bool          `mapstructure:"grpc_client"`
+	GRPCStream        bool          `mapstructure:"grpc_stream"`
 	CACertFile        string        `mapstructure:"ca_cert_file"`
+
+	DiskMountPointsInclude []string `mapstructure:"disk_mount_points_include"`
+	DiskMountPointsExclude []string `mapstructure:"disk_mount_points_exclude"`
+	DiskFSTypesInclude     []string `mapstructure:"disk_fs_types_include"`
+	DiskFSTypesExclude     []string `mapstructure:"disk_fs_types_exclude"`
+	NetInterfacesInclude   []string `mapstructure:"net_interfaces_include"`
+	NetInterfacesExclude   []string `mapstructure:"net_interfaces_exclude"`
+
+	ProcessRules []metric.ProcessRule `mapstructure:"process_rules"`
+	ExecDir      string               `mapstructure:"exec_dir"`
+	ExecTimeout  time.Duration        `mapstructure:"exec_timeout"`
+
+	PrometheusTargets []string      `mapstructure:"prometheus_targets"`
+	PrometheusTimeout time.Duration `mapstructure:"prometheus_timeout"`
+	StatsDAddress     string        `mapstructure:"statsd_address"`
+
+	Collectors           []string      `mapstructure:"collectors"`
+	Group                string        `mapstructure:"group"`
+	RemoteConfigInterval time.Duration `mapstructure:"remote_config_interval"`
+	HeartbeatInterval    time.Duration `mapstructure:"heartbeat_interval"`
+
+	RelabelRules []relabel.Rule `mapstructure:"relabel_rules"`
+}
+
+// Имена сборщиков метрик агента
+const (
+	CollectorMem        = "mem"
+	CollectorPsUtil     = "psutil"
+	CollectorDisk       = "disk"
+	CollectorNet        = "net"
+	CollectorProcess    = "process"
+	CollectorExec       = "exec"
+	CollectorPrometheus = "prometheus"
+	// CollectorAgent метрики о работе самого агента
+	CollectorAgent = "agent"
+)
+
+var knownCollectors = []string{
+	CollectorMem,
+	CollectorPsUtil,
+	CollectorDisk,
+	CollectorNet,
+	CollectorProcess,
+	CollectorExec,
+	CollectorPrometheus,
+	CollectorAgent,
+}
+
+func isKnownCollector(name string) bool {
+	for _, c := range knownCollectors {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCollectorEnabled сообщает, включен ли сборщик метрик. Если список сборщиков не задан, включены все
+func (p *AgentInParams) IsCollectorEnabled(name string) bool {
+	if len(p.Collectors) == 0 {
+		return true
+	}
+	for _, c := range p.Collectors {
+		if c == name {
+			return true
+		}
+	}
+	return false
 }
 
 // getAgentPFlag получает конфигурацию агента из командной строки.
 func getAgentPFlag() Params {
+	agentPFlagOnce.Do(func() {
+		agentPFlagParams = parseAgentPFlag()
+	})
+	return agentPFlagParams
+}
+
+func parseAgentPFlag() Params {
 	pflag.StringP("address", "a", "", "Server address:port")
 	pflag.StringP("report_interval", "r", "", "Send metrics to server interval")
 	pflag.StringP("poll_interval", "p", "", "Collect metrics interval")
@@ -81,10 +217,15 @@ func getAgentPFlag() Params {
 
 func getAgentDefaults() Params {
 	return map[string]any{
-		"address":         Address,
-		"report_interval": ReportInterval,
-		"poll_interval":   PollInterval,
-		"grpc_client":     "false",
+		"address":            Address,
+		"report_interval":    ReportInterval,
+		"poll_interval":      PollInterval,
+		"exec_timeout":       ExecTimeout,
+		"prometheus_timeout": PrometheusTimeout,
+		"grpc_client":        "false",
+		"grpc_stream":        "false",
+		"group":              DefaultAgentGroup,
+		"heartbeat_interval": HeartbeatInterval,
 	}
 }
 
@@ -92,6 +233,8 @@ type AgentConfig struct {
 	*AgentInParams
 	PublicKey *rsa.PublicKey
 	Logger    zerolog.Logger
+	// Relabel правила обработки имен метрик перед отправкой, nil если правила не заданы
+	Relabel *relabel.Pipeline
 }
 
 func getRSAPublicKey(fileName string) (*rsa.PublicKey, error) {
@@ -109,7 +252,40 @@ func getRSAPublicKey(fileName string) (*rsa.PublicKey, error) {
 	return pub, nil
 }
 
-// NewAgentConfig отдает готовую структуру с необходимыми настройками для агента
+// Validate проверяет согласованность настроек агента и возвращает все найденные ошибки
+func (c *AgentConfig) Validate() error {
+	v := &validator{}
+
+	serverURL, err := url.Parse(c.Address)
+	v.check(err == nil && serverURL.Host != "", "invalid server address %q", c.Address)
+	v.check(c.PollInterval > 0, "poll_interval must be positive, got %v", c.PollInterval)
+	v.check(c.ReportInterval > 0, "report_interval must be positive, got %v", c.ReportInterval)
+	v.check(!c.GRPCClient || c.CACertFile != "", "ca_cert_file is required for gRPC client")
+	v.check(!c.GRPCStream || c.GRPCClient, "grpc_stream requires grpc_client")
+	v.check(c.ExecDir == "" || c.ExecTimeout > 0, "exec_timeout must be positive, got %v", c.ExecTimeout)
+	v.check(len(c.PrometheusTargets) == 0 || c.PrometheusTimeout > 0, "prometheus_timeout must be positive, got %v", c.PrometheusTimeout)
+	for _, target := range c.PrometheusTargets {
+		targetURL, err := url.Parse(target)
+		v.check(err == nil && targetURL.Host != "", "invalid prometheus target %q", target)
+	}
+	for _, rule := range c.ProcessRules {
+		v.check(rule.Name != "", "process rule name is required")
+		v.check(rule.MaxProcesses >= 0, "process rule %s: max_processes must not be negative, got %d", rule.Name, rule.MaxProcesses)
+	}
+	for _, name := range c.Collectors {
+		v.check(isKnownCollector(name), "unknown collector %q", name)
+	}
+	v.check(c.RemoteConfigInterval >= 0, "remote_config_interval must not be negative, got %v", c.RemoteConfigInterval)
+	v.check(c.RemoteConfigInterval == 0 || c.CACertFile != "", "ca_cert_file is required for remote configuration")
+	v.check(c.HeartbeatInterval >= 0, "heartbeat_interval must not be negative, got %v", c.HeartbeatInterval)
+	_, err = relabel.New(c.RelabelRules)
+	v.check(err == nil, "relabel_rules: %v", err)
+
+	return v.err()
+}
+
+// NewAgentConfig отдает готовую структуру с необходимыми настройками для агента.
+// Функцию можно вызывать повторно для перечитывания конфигурации, флаги командной строки при этом не меняются
 func NewAgentConfig(logger zerolog.Logger) (*AgentConfig, error) {
 	defaults := getAgentDefaults()
 	pFlagCfg := getAgentPFlag()
@@ -145,5 +321,14 @@ func NewAgentConfig(logger zerolog.Logger) (*AgentConfig, error) {
 		agentCfg.PublicKey = pubKey
 	}
 
+	if err := agentCfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	agentCfg.Relabel, err = relabel.New(agentCfg.RelabelRules)
+	if err != nil {
+		return nil, err
+	}
+
 	return &agentCfg, nil
 }
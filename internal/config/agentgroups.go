@@ -0,0 +1,84 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/relabel"
+)
+
+// DefaultAgentGroup группа, настройки которой получают агенты без собственной группы или из неизвестной группы
+const DefaultAgentGroup = "default"
+
+// AgentGroupConfig настройки, которые сервер раздает агентам группы.
+// Незаданные параметры агент берет из собственной конфигурации. Адрес сервера, ключи, сертификаты, транспорт,
+// правила сборщиков process, exec и prometheus и адрес statsd задаются только локально
+type AgentGroupConfig struct {
+	PollInterval   time.Duration `mapstructure:"poll_interval" json:"poll_interval"`
+	ReportInterval time.Duration `mapstructure:"report_interval" json:"report_interval"`
+	Collectors     []string      `mapstructure:"collectors" json:"collectors"`
+
+	DiskMountPointsInclude []string `mapstructure:"disk_mount_points_include" json:"disk_mount_points_include"`
+	DiskMountPointsExclude []string `mapstructure:"disk_mount_points_exclude" json:"disk_mount_points_exclude"`
+	DiskFSTypesInclude     []string `mapstructure:"disk_fs_types_include" json:"disk_fs_types_include"`
+	DiskFSTypesExclude     []string `mapstructure:"disk_fs_types_exclude" json:"disk_fs_types_exclude"`
+	NetInterfacesInclude   []string `mapstructure:"net_interfaces_include" json:"net_interfaces_include"`
+	NetInterfacesExclude   []string `mapstructure:"net_interfaces_exclude" json:"net_interfaces_exclude"`
+
+	RelabelRules []relabel.Rule `mapstructure:"relabel_rules" json:"relabel_rules"`
+}
+
+// Version возвращает отпечаток настроек, по которому агент понимает, что настройки изменились
+func (g AgentGroupConfig) Version() string {
+	data, _ := json.Marshal(g)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+func (g AgentGroupConfig) validate(v *validator, group string) {
+	v.check(g.PollInterval >= 0, "group %s: poll_interval must not be negative", group)
+	v.check(g.ReportInterval >= 0, "group %s: report_interval must not be negative", group)
+	for _, c := range g.Collectors {
+		v.check(isKnownCollector(c), "group %s: unknown collector %q", group, c)
+	}
+	_, err := relabel.New(g.RelabelRules)
+	v.check(err == nil, "group %s: relabel_rules: %v", group, err)
+}
+
+// AgentGroups настройки агентов по группам
+type AgentGroups map[string]AgentGroupConfig
+
+// Find возвращает настройки группы агента, для неизвестной группы - настройки группы по умолчанию
+func (g AgentGroups) Find(group string) (string, AgentGroupConfig, bool) {
+	if cfg, ok := g[group]; ok {
+		return group, cfg, true
+	}
+	cfg, ok := g[DefaultAgentGroup]
+	return DefaultAgentGroup, cfg, ok
+}
+
+// LoadAgentGroups читает настройки групп агентов из json файла вида {"группа": {настройки}}
+func LoadAgentGroups(fileName string) (AgentGroups, error) {
+	params, err := readFileCfg(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := AgentGroups{}
+	if err := bindParams(params, &groups); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+
+	v := &validator{}
+	for name, g := range groups {
+		g.validate(v, name)
+	}
+	if err := v.err(); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAgentGroups(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		group     string
+		wantGroup string
+		want      AgentGroupConfig
+		wantErr   assert.ErrorAssertionFunc
+	}{
+		{
+			name: "should load group settings",
+			content: `{
+				"default": {"poll_interval": "5s"},
+				"db": {"report_interval": "30s", "collectors": ["mem", "disk"], "disk_mount_points_include": ["/var/lib/*"]}
+			}`,
+			group:     "db",
+			wantGroup: "db",
+			want: AgentGroupConfig{
+				ReportInterval:         30 * time.Second,
+				Collectors:             []string{"mem", "disk"},
+				DiskMountPointsInclude: []string{"/var/lib/*"},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:      "should fall back to default group",
+			content:   `{"default": {"poll_interval": "5s"}}`,
+			group:     "unknown",
+			wantGroup: DefaultAgentGroup,
+			want:      AgentGroupConfig{PollInterval: 5 * time.Second},
+			wantErr:   assert.NoError,
+		},
+		{
+			name:    "should fail on unknown collector",
+			content: `{"default": {"collectors": ["gpu"]}}`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on invalid relabel rule",
+			content: `{"default": {"relabel_rules": [{"action": "drop", "regex": "("}]}}`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on invalid interval",
+			content: `{"default": {"poll_interval": "soon"}}`,
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "agents.json")
+			if err := os.WriteFile(fileName, []byte(tt.content), 0o600); err != nil {
+				panic(err)
+			}
+
+			groups, err := LoadAgentGroups(fileName)
+			if !tt.wantErr(t, err) || err != nil {
+				return
+			}
+
+			group, got, ok := groups.Find(tt.group)
+			assert.True(t, ok)
+			assert.Equal(t, tt.wantGroup, group)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
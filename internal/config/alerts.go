@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/alert"
+)
+
+// alertRulesFile формат файла правил оповещений
+type alertRulesFile struct {
+	Rules    []alert.Rule    `mapstructure:"rules"`
+	Webhooks []alert.Webhook `mapstructure:"webhooks"`
+}
+
+// LoadAlertRules читает правила оповещений и адресатов уведомлений из json файла вида
+// {"rules": [{"name": ..., "expr": ..., "for": "5m"}], "webhooks": [{"url": ..., "secret": ..., "repeat_interval": "1h"}]}.
+// Окно функций вроде rate в выражениях не должно превышать время хранения истории retention
+func LoadAlertRules(fileName string, retention time.Duration) (*alert.RuleSet, *alert.Webhooks, error) {
+	params, err := readFileCfg(fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file := alertRulesFile{}
+	if err := bindParams(params, &file); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+
+	rules, err := alert.NewRuleSet(file.Rules)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	if err := checkQueryWindow(rules.Window(), retention); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	webhooks, err := alert.NewWebhooks(file.Webhooks)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return rules, webhooks, nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAlertRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "should load rules and webhooks",
+			content: `{
+				"rules": [{"name": "LowMemory", "expr": "FreeMemory < 1e9", "for": "5m"}],
+				"webhooks": [{"url": "http://localhost:9093/alerts", "max_retries": 0, "repeat_interval": "1h"}]
+			}`,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "should accept disabled webhook retries",
+			content: `{"webhooks": [{"url": "http://localhost:9093/alerts", "max_retries": -1}]}`,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "should fail on webhook retries below -1",
+			content: `{"webhooks": [{"url": "http://localhost:9093/alerts", "max_retries": -2}]}`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on rate window longer than history retention",
+			content: `{"rules": [{"name": "SlowPolling", "expr": "rate(PollCount[2h]) < 1"}]}`,
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "alerts.json")
+			if err := os.WriteFile(fileName, []byte(tt.content), 0o600); err != nil {
+				panic(err)
+			}
+
+			_, _, err := LoadAlertRules(fileName, time.Hour)
+			tt.wantErr(t, err)
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// CertCheckInterval как часто проверяется изменение файлов сертификатов
+const CertCheckInterval = 10 * time.Second
+
+// certBundle загруженные сертификаты сервера
+type certBundle struct {
+	caFile, certFile, keyFile string
+	cert                      *tls.Certificate
+	clientCAs                 *x509.CertPool
+	modTime                   time.Time
+}
+
+// CertStore хранит TLS сертификаты сервера и подменяет их без перезапуска.
+// Сертификаты перечитываются по запросу и автоматически, если файлы на диске изменились
+type CertStore struct {
+	logger zerolog.Logger
+
+	mu      *sync.RWMutex
+	bundle  *certBundle
+	checked time.Time
+}
+
+// NewCertStore загружает сертификаты из файлов
+func NewCertStore(caFile, certFile, keyFile string, logger zerolog.Logger) (*CertStore, error) {
+	bundle, err := loadCertBundle(caFile, certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CertStore{
+		logger:  logger,
+		mu:      new(sync.RWMutex),
+		bundle:  bundle,
+		checked: time.Now(),
+	}, nil
+}
+
+func loadCertBundle(caFile, certFile, keyFile string) (*certBundle, error) {
+	modTime, err := latestModTime(caFile, certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	caPem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(caPem) {
+		return nil, errors.New("failed to load CA certificate to cert pool")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &certBundle{
+		caFile:    caFile,
+		certFile:  certFile,
+		keyFile:   keyFile,
+		cert:      &cert,
+		clientCAs: certPool,
+		modTime:   modTime,
+	}, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// Reload загружает сертификаты из указанных файлов, при ошибке остаются прежние сертификаты
+func (s *CertStore) Reload(caFile, certFile, keyFile string) error {
+	bundle, err := loadCertBundle(caFile, certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.bundle = bundle
+	s.checked = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// current возвращает актуальные сертификаты, перечитывая их, если файлы изменились с момента загрузки
+func (s *CertStore) current() *certBundle {
+	s.mu.RLock()
+	bundle, checked := s.bundle, s.checked
+	s.mu.RUnlock()
+
+	if time.Since(checked) < CertCheckInterval {
+		return bundle
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.bundle != bundle {
+		return s.bundle
+	}
+	s.checked = time.Now()
+
+	modTime, err := latestModTime(bundle.caFile, bundle.certFile, bundle.keyFile)
+	if err != nil || !modTime.After(bundle.modTime) {
+		return bundle
+	}
+
+	reloaded, err := loadCertBundle(bundle.caFile, bundle.certFile, bundle.keyFile)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("config: failed to reload rotated certificates, keeping current ones")
+		return bundle
+	}
+	s.bundle = reloaded
+	s.logger.Info().Msg("config: rotated certificates reloaded")
+
+	return reloaded
+}
+
+// GetCertificate отдает актуальный сертификат сервера, используется в tls.Config
+func (s *CertStore) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return s.current().cert, nil
+}
+
+// GetConfigForClient отдает настройки TLS сервера с актуальным корневым сертификатом клиентов
+func (s *CertStore) GetConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	cfg := s.baseConfig()
+	cfg.ClientCAs = s.current().clientCAs
+	return cfg, nil
+}
+
+// baseConfig общие настройки TLS сервера. Настройки, которые отдает GetConfigForClient, полностью заменяют
+// исходные, поэтому в них должны быть все поля, включая протоколы ALPN: gRPC работает только поверх h2
+func (s *CertStore) baseConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: s.GetCertificate,
+		ClientAuth:     tls.VerifyClientCertIfGiven,
+		MinVersion:     tls.VersionTLS13,
+		NextProtos:     []string{"h2"},
+	}
+}
+
+// TLSConfig возвращает настройки TLS сервера, сертификаты в которых подменяются без перезапуска
+func (s *CertStore) TLSConfig() *tls.Config {
+	cfg := s.baseConfig()
+	cfg.GetConfigForClient = s.GetConfigForClient
+	return cfg
+}
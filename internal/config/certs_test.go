@@ -0,0 +1,134 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeSelfSigned записывает самоподписанный сертификат, который служит и корневым, и серверным
+func writeSelfSigned(t *testing.T, dir string, serial int64, modTime time.Time) (caFile, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+
+	caFile = filepath.Join(dir, "ca.pem")
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	for file, data := range map[string][]byte{caFile: certPem, certFile: certPem, keyFile: keyPem} {
+		if err := os.WriteFile(file, data, 0o600); err != nil {
+			panic(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			panic(err)
+		}
+	}
+	return caFile, certFile, keyFile
+}
+
+func servedSerial(t *testing.T, s *CertStore) int64 {
+	cert, err := s.GetCertificate(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.NoError(t, err)
+	return leaf.SerialNumber.Int64()
+}
+
+func TestCertStore_Rotation(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	caFile, certFile, keyFile := writeSelfSigned(t, dir, 1, now.Add(-time.Minute))
+
+	store, err := NewCertStore(caFile, certFile, keyFile, zerolog.Nop())
+	if err != nil {
+		panic(err)
+	}
+	assert.Equal(t, int64(1), servedSerial(t, store))
+
+	writeSelfSigned(t, dir, 2, now)
+	assert.Equal(t, int64(1), servedSerial(t, store), "files should not be checked more often than CertCheckInterval")
+
+	store.checked = now.Add(-CertCheckInterval)
+	assert.Equal(t, int64(2), servedSerial(t, store), "rotated certificate should be picked up")
+
+	assert.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+	assert.NoError(t, os.Chtimes(keyFile, now.Add(time.Minute), now.Add(time.Minute)))
+	store.checked = now.Add(-CertCheckInterval)
+	assert.Equal(t, int64(2), servedSerial(t, store), "broken rotation should keep current certificate")
+
+	assert.Error(t, store.Reload(caFile, certFile, keyFile))
+	writeSelfSigned(t, dir, 3, now.Add(2*time.Minute))
+	assert.NoError(t, store.Reload(caFile, certFile, keyFile))
+	assert.Equal(t, int64(3), servedSerial(t, store))
+
+	cfg, err := store.GetConfigForClient(&tls.ClientHelloInfo{})
+	assert.NoError(t, err)
+	assert.NotNil(t, cfg.ClientCAs)
+}
+
+func TestCertStore_TLSConfig(t *testing.T) {
+	caFile, certFile, keyFile := writeSelfSigned(t, t.TempDir(), 1, time.Now())
+	store, err := NewCertStore(caFile, certFile, keyFile, zerolog.Nop())
+	assert.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", store.TLSConfig())
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+	}()
+
+	caPem, err := os.ReadFile(caFile)
+	assert.NoError(t, err)
+	roots := x509.NewCertPool()
+	assert.True(t, roots.AppendCertsFromPEM(caPem))
+
+	conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{
+		RootCAs:    roots,
+		ServerName: "localhost",
+		NextProtos: []string{"h2"},
+		MinVersion: tls.VersionTLS13,
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	assert.Equal(t, "h2", conn.ConnectionState().NegotiatedProtocol, "server should negotiate h2 for gRPC clients")
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"strings"
 	"time"
@@ -26,6 +27,22 @@ const (
 	ReportInterval = 10 * time.Second
 	// PollInterval Интервал обновления метрик
 	PollInterval = 2 * time.Second
+	// ExecTimeout Максимальное время работы скрипта, собирающего метрики
+	ExecTimeout = 5 * time.Second
+	// PrometheusTimeout Максимальное время опроса одной цели Prometheus
+	PrometheusTimeout = 5 * time.Second
+	// HeartbeatInterval Интервал отправки heartbeat агента на сервер
+	HeartbeatInterval = 30 * time.Second
+	// SelfMetricsInterval Интервал сохранения метрик о работе сервера в хранилище
+	SelfMetricsInterval = 10 * time.Second
+	// AlertEvalInterval Интервал проверки правил оповещений
+	AlertEvalInterval = 15 * time.Second
+	// RecordingInterval Интервал вычисления правил записи производных метрик
+	RecordingInterval = 15 * time.Second
+	// QuerySampleInterval Интервал сохранения значений метрик в историю для функций над окном, например rate
+	QuerySampleInterval = 15 * time.Second
+	// QueryHistoryRetention Сколько хранится история значений метрик
+	QueryHistoryRetention = 10 * time.Minute
 )
 
 type Params map[string]any
@@ -51,6 +68,7 @@ func readFileCfg(fileName string) (Params, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer file.Close()
 	decoder := json.NewDecoder(file)
 
 	params := make(map[string]any)
@@ -89,6 +107,7 @@ func bindParams(params Params, output any) error {
 		DecodeHook: mapstructure.ComposeDecodeHookFunc(
 			mapstructure.StringToTimeDurationHookFunc(),
 			mapstructure.StringToIPNetHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
 		),
 		WeaklyTypedInput: true,
 		Metadata:         nil,
@@ -110,3 +129,34 @@ func bindParams(params Params, output any) error {
 func hasSchema(addr string) bool {
 	return strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://")
 }
+
+// ValidationError содержит все ошибки, найденные при проверке конфигурации
+type ValidationError struct {
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(msgs, "; "))
+}
+
+// validator накапливает ошибки проверки конфигурации
+type validator struct {
+	errs []error
+}
+
+func (v *validator) check(ok bool, format string, args ...any) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf(format, args...))
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errs: v.errs}
+}
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/c0dered273/go-adv-metrics/internal/ingest"
+)
+
+// LoadIngestRules читает правила приема метрик из json файла вида
+// {"allow": [...], "deny": [...], "relabel": [{"action": ..., "regex": ...}], "max_series": N}
+func LoadIngestRules(fileName string) (*ingest.Pipeline, error) {
+	params, err := readFileCfg(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := ingest.Rules{}
+	if err := bindParams(params, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+
+	pipeline, err := ingest.New(rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return pipeline, nil
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// queryRetention возвращает, за какое время хранится история значений метрик, 0 если история отключена
+func (c *ServerConfig) queryRetention() time.Duration {
+	if c.History == nil {
+		return 0
+	}
+	return c.QueryHistoryRetention
+}
+
+// checkQueryWindow проверяет, что истории значений хватает для функций над окном в выражениях правил
+func checkQueryWindow(window time.Duration, retention time.Duration) error {
+	if window > retention {
+		return fmt.Errorf("expression window %s exceeds query history retention %s", window, retention)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/recording"
+)
+
+// recordingRulesFile формат файла правил записи
+type recordingRulesFile struct {
+	Rules []recording.Rule `mapstructure:"rules"`
+}
+
+// LoadRecordingRules читает правила записи производных метрик из json файла вида
+// {"rules": [{"record": "HeapUsage", "expr": "HeapInuse / HeapSys"}]}.
+// Окно функций вроде rate в выражениях не должно превышать время хранения истории retention
+func LoadRecordingRules(fileName string, retention time.Duration) (*recording.RuleSet, error) {
+	params, err := readFileCfg(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	file := recordingRulesFile{}
+	if err := bindParams(params, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+
+	rules, err := recording.NewRuleSet(file.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	if err := checkQueryWindow(rules.Window(), retention); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return rules, nil
+}
@@ -5,12 +5,25 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
+	"errors"
+	"fmt"
 	"net"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/c0dered273/go-adv-metrics/internal/alert"
+	"github.com/c0dered273/go-adv-metrics/internal/cumulative"
+	"github.com/c0dered273/go-adv-metrics/internal/fleet"
+	"github.com/c0dered273/go-adv-metrics/internal/ingest"
+	"github.com/c0dered273/go-adv-metrics/internal/query"
+	"github.com/c0dered273/go-adv-metrics/internal/ratelimit"
+	"github.com/c0dered273/go-adv-metrics/internal/recording"
+	"github.com/c0dered273/go-adv-metrics/internal/selfmetrics"
 	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/c0dered273/go-adv-metrics/internal/stream"
 	"github.com/rs/zerolog"
 	"github.com/spf13/pflag"
 )
@@ -28,6 +41,19 @@ var (
 	// CA_CERT_FILE - файл с корневым сертификатом
 	// SERVER_CERT_FILE - файл с серверным сертификатом
 	// SERVER_KEY_FILE - файл с серверным ключом
+	// AGENT_CONFIG_FILE - json файл с настройками групп агентов, которые сервер раздает по gRPC
+	// INGEST_RULES_FILE - json файл с правилами приема метрик
+	// RATE_LIMIT_RPS - допустимое количество запросов в секунду от одного клиента, 0 - без ограничения
+	// RATE_LIMIT_BURST - количество запросов, которое клиент может выполнить сразу
+	// RATE_LIMIT_METRICS_PER_MINUTE - допустимое количество метрик в минуту от одного клиента, 0 - без ограничения
+	// RATE_LIMIT_BY_CLIENT_ID - различать клиентов по заголовку X-Client-ID вместо IP адреса
+	// ALERT_RULES_FILE - json файл с правилами оповещений и webhook для уведомлений
+	// ALERT_EVAL_INTERVAL - интервал проверки правил оповещений, 0 отключает проверку
+	// RECORDING_RULES_FILE - json файл с правилами записи производных метрик
+	// RECORDING_INTERVAL - интервал вычисления правил записи, 0 отключает вычисление
+	// QUERY_SAMPLE_INTERVAL - интервал сохранения значений метрик в историю для rate(), 0 отключает историю
+	// QUERY_HISTORY_RETENTION - сколько хранится история значений, ограничивает окно rate()
+	// SELF_METRICS_INTERVAL - интервал сохранения метрик о работе сервера, 0 отключает их сбор
 	serverEnvVars = []string{
 		"ADDRESS",
 		"GRPC_ADDRESS",
@@ -42,40 +68,98 @@ var (
 		"CA_CERT_FILE",
 		"SERVER_CERT_FILE",
 		"SERVER_KEY_FILE",
+		"AGENT_CONFIG_FILE",
+		"SELF_METRICS_INTERVAL",
+		"INGEST_RULES_FILE",
+		"RATE_LIMIT_RPS",
+		"RATE_LIMIT_BURST",
+		"RATE_LIMIT_METRICS_PER_MINUTE",
+		"RATE_LIMIT_BY_CLIENT_ID",
+		"ALERT_RULES_FILE",
+		"ALERT_EVAL_INTERVAL",
+		"RECORDING_RULES_FILE",
+		"RECORDING_INTERVAL",
+		"QUERY_SAMPLE_INTERVAL",
+		"QUERY_HISTORY_RETENTION",
 	}
+
+	serverPFlagOnce   sync.Once
+	serverPFlagParams Params
 )
 
 type ServerConfigFileParams struct {
-	Address            string        `json:"address"`
-	GRPCAddress        string        `json:"grpc_address"`
-	DatabaseDsn        string        `json:"database_dsn"`
-	StoreInterval      time.Duration `json:"store_interval"`
-	StoreFile          string        `json:"store_file"`
-	Restore            bool          `json:"restore"`
-	PrivateKeyFileName string        `json:"crypto_key"`
-	TrustedSubnet      string        `json:"trusted_subnet"`
-	CACertFile         string        `json:"ca_cert_file"`
-	ServerCertFile     string        `json:"server_cert_file"`
-	ServerKeyFile      string        `json:"server_key_file"`
+	Address             string        `json:"address"`
+	GRPCAddress         string        `json:"grpc_address"`
+	DatabaseDsn         string        `json:"database_dsn"`
+	StoreInterval       time.Duration `json:"store_interval"`
+	StoreFile           string        `json:"store_file"`
+	Restore             bool          `json:"restore"`
+	PrivateKeyFileName  string        `json:"crypto_key"`
+	TrustedSubnet       string        `json:"trusted_subnet"`
+	CACertFile          string        `json:"ca_cert_file"`
+	ServerCertFile      string        `json:"server_cert_file"`
+	ServerKeyFile       string        `json:"server_key_file"`
+	AgentConfigFile     string        `json:"agent_config_file"`
+	SelfMetricsInterval time.Duration `json:"self_metrics_interval"`
+	IngestRulesFile     string        `json:"ingest_rules_file"`
+
+	RateLimitRPS              float64 `json:"rate_limit_rps"`
+	RateLimitBurst            int     `json:"rate_limit_burst"`
+	RateLimitMetricsPerMinute int     `json:"rate_limit_metrics_per_minute"`
+	RateLimitByClientID       bool    `json:"rate_limit_by_client_id"`
+
+	AlertRulesFile    string        `json:"alert_rules_file"`
+	AlertEvalInterval time.Duration `json:"alert_eval_interval"`
+
+	RecordingRulesFile string        `json:"recording_rules_file"`
+	RecordingInterval  time.Duration `json:"recording_interval"`
+
+	QuerySampleInterval   time.Duration `json:"query_sample_interval"`
+	QueryHistoryRetention time.Duration `json:"query_history_retention"`
 }
 
 type ServerInParams struct {
-	Address            string        `mapstructure:"address"`
-	GRPCAddress        string        `mapstructure:"grpc_address"`
-	DatabaseDsn        string        `mapstructure:"database_dsn"`
-	StoreInterval      time.Duration `mapstructure:"store_interval"`
-	StoreFile          string        `mapstructure:"store_file"`
-	Restore            bool          `mapstructure:"restore"`
-	Key                string        `mapstructure:"key"`
-	PrivateKeyFileName string        `mapstructure:"crypto_key"`
-	TrustedSubnet      *net.IPNet    `mapstructure:"trusted_subnet"`
-	CACertFile         string        `mapstructure:"ca_cert_file"`
-	ServerCertFile     string        `mapstructure:"server_cert_file"`
-	ServerKeyFile      string        `mapstructure:"server_key_file"`
+	Address             string        `mapstructure:"address"`
+	GRPCAddress         string        `mapstructure:"grpc_address"`
+	DatabaseDsn         string        `mapstructure:"database_dsn"`
+	StoreInterval       time.Duration `mapstructure:"store_interval"`
+	StoreFile           string        `mapstructure:"store_file"`
+	Restore             bool          `mapstructure:"restore"`
+	Key                 string        `mapstructure:"key"`
+	PrivateKeyFileName  string        `mapstructure:"crypto_key"`
+	TrustedSubnet       *net.IPNet    `mapstructure:"trusted_subnet"`
+	CACertFile          string        `mapstructure:"ca_cert_file"`
+	ServerCertFile      string        `mapstructure:"server_cert_file"`
+	ServerKeyFile       string        `mapstructure:"server_key_file"`
+	AgentConfigFile     string        `mapstructure:"agent_config_file"`
+	SelfMetricsInterval time.Duration `mapstructure:"self_metrics_interval"`
+	IngestRulesFile     string        `mapstructure:"ingest_rules_file"`
+
+	RateLimitRPS              float64 `mapstructure:"rate_limit_rps"`
+	RateLimitBurst            int     `mapstructure:"rate_limit_burst"`
+	RateLimitMetricsPerMinute int     `mapstructure:"rate_limit_metrics_per_minute"`
+	RateLimitByClientID       bool    `mapstructure:"rate_limit_by_client_id"`
+
+	AlertRulesFile    string        `mapstructure:"alert_rules_file"`
+	AlertEvalInterval time.Duration `mapstructure:"alert_eval_interval"`
+
+	RecordingRulesFile string        `mapstructure:"recording_rules_file"`
+	RecordingInterval  time.Duration `mapstructure:"recording_interval"`
+
+	QuerySampleInterval   time.Duration `mapstructure:"query_sample_interval"`
+	QueryHistoryRetention time.Duration `mapstructure:"query_history_retention"`
 }
 
 // getServerPFlag получает конфигурацией сервера из командной строки.
+// Флаги разбираются один раз, при перечитывании конфигурации используются сохраненные значения
 func getServerPFlag() Params {
+	serverPFlagOnce.Do(func() {
+		serverPFlagParams = parseServerPFlag()
+	})
+	return serverPFlagParams
+}
+
+func parseServerPFlag() Params {
 	pflag.StringP("address", "a", "", "Server address:port")
 	pflag.StringP("grpc_address", "g", "", "gRPC Server address:port")
 	pflag.StringP("databaseDsn", "d", "", "Database url")
@@ -114,11 +198,16 @@ func getServerPFlag() Params {
 
 func getSrvDefaults() Params {
 	return map[string]any{
-		"address":        Address,
-		"grpc_address":   GRPCAddress,
-		"store_interval": StoreInterval,
-		"restore":        Restore,
-		"store_file":     StoreFile,
+		"address":                 Address,
+		"grpc_address":            GRPCAddress,
+		"store_interval":          StoreInterval,
+		"restore":                 Restore,
+		"store_file":              StoreFile,
+		"self_metrics_interval":   SelfMetricsInterval,
+		"alert_eval_interval":     AlertEvalInterval,
+		"recording_interval":      RecordingInterval,
+		"query_sample_interval":   QuerySampleInterval,
+		"query_history_retention": QueryHistoryRetention,
 	}
 }
 
@@ -128,6 +217,91 @@ type ServerConfig struct {
 	PrivateKey   *rsa.PrivateKey
 	IsTLSEnabled bool
 	Repo         storage.Repository
+	AgentGroups  AgentGroups
+	Agents       *fleet.Registry
+	SelfMetrics  *selfmetrics.Registry
+	IngestRules  *ingest.Pipeline
+	// Series метрики, известные серверу, для ограничения их количества правилами приема
+	Series *ingest.Series
+	// Alerts состояние оповещений, правила перечитываются вместе с конфигурацией
+	Alerts *alert.Engine
+	// Recording вычисляет производные метрики, правила перечитываются вместе с конфигурацией
+	Recording *recording.Engine
+	// History история значений метрик для функций над окном в запросах, правилах оповещений и записи
+	History *query.History
+	// RateLimiter ограничения частоты запросов и количества метрик от клиентов, nil если ограничения не заданы
+	RateLimiter *ratelimit.Limiter
+	// Cumulative последние значения накопительных счетчиков по источникам
+	Cumulative *cumulative.Tracker
+	// Stream рассылает сохраненные метрики подписчикам потока событий
+	Stream *stream.Hub
+
+	// reloaded настройки, перечитанные по сигналу, имеют приоритет над исходными
+	reloaded atomic.Pointer[reloadableParams]
+	certs    *CertStore
+}
+
+// reloadableParams настройки сервера, которые можно изменить без перезапуска
+type reloadableParams struct {
+	key           string
+	trustedSubnet *net.IPNet
+	privateKey    *rsa.PrivateKey
+	agentGroups   AgentGroups
+	ingestRules   *ingest.Pipeline
+}
+
+// GetKey возвращает актуальный ключ подписи метрик
+func (c *ServerConfig) GetKey() string {
+	if r := c.reloaded.Load(); r != nil {
+		return r.key
+	}
+	return c.Key
+}
+
+// GetTrustedSubnet возвращает актуальную доверенную подсеть, nil если проверка отключена
+func (c *ServerConfig) GetTrustedSubnet() *net.IPNet {
+	if r := c.reloaded.Load(); r != nil {
+		return r.trustedSubnet
+	}
+	return c.TrustedSubnet
+}
+
+// GetPrivateKey возвращает актуальный приватный RSA ключ, nil если шифрование отключено
+func (c *ServerConfig) GetPrivateKey() *rsa.PrivateKey {
+	if r := c.reloaded.Load(); r != nil {
+		return r.privateKey
+	}
+	return c.PrivateKey
+}
+
+// GetAgentGroups возвращает актуальные настройки групп агентов
+func (c *ServerConfig) GetAgentGroups() AgentGroups {
+	if r := c.reloaded.Load(); r != nil {
+		return r.agentGroups
+	}
+	return c.AgentGroups
+}
+
+// GetIngestRules возвращает актуальные правила приема метрик, nil если правила не заданы
+func (c *ServerConfig) GetIngestRules() *ingest.Pipeline {
+	if r := c.reloaded.Load(); r != nil {
+		return r.ingestRules
+	}
+	return c.IngestRules
+}
+
+// RateLimitKey возвращает ключ клиента для ограничения частоты запросов: идентификатор клиента,
+// если так задано в настройках и клиент его передал, иначе IP адрес
+func (c *ServerConfig) RateLimitKey(ip string, clientID string) string {
+	if c.RateLimitByClientID && clientID != "" {
+		return "id:" + clientID
+	}
+	return "ip:" + ip
+}
+
+// GetCertStore возвращает хранилище TLS сертификатов, nil если TLS отключен
+func (c *ServerConfig) GetCertStore() *CertStore {
+	return c.certs
 }
 
 func getRSAPrivateKey(fileName string) (*rsa.PrivateKey, error) {
@@ -137,6 +311,9 @@ func getRSAPrivateKey(fileName string) (*rsa.PrivateKey, error) {
 	}
 
 	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", fileName)
+	}
 	prv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
 	if err != nil {
 		return nil, err
@@ -145,8 +322,8 @@ func getRSAPrivateKey(fileName string) (*rsa.PrivateKey, error) {
 	return prv, nil
 }
 
-// NewServerConfig возвращает структуру с необходимыми настройками сервера
-func NewServerConfig(ctx context.Context, logger zerolog.Logger) (*ServerConfig, error) {
+// getServerInParams собирает настройки сервера из всех источников
+func getServerInParams() (*ServerInParams, error) {
 	defaults := getSrvDefaults()
 	pFlagCfg := getServerPFlag()
 	envCfg := getEnvCfg(serverEnvVars)
@@ -164,14 +341,36 @@ func NewServerConfig(ctx context.Context, logger zerolog.Logger) (*ServerConfig,
 		return nil, err
 	}
 
-	srvCfg := ServerConfig{
-		ServerInParams: serverParams,
-		Logger:         logger,
+	if hasSchema(serverParams.Address) {
+		split := strings.Split(serverParams.Address, "//")
+		serverParams.Address = split[1]
+	}
+
+	return serverParams, nil
+}
+
+func isTLSEnabled(p *ServerInParams) bool {
+	return p.CACertFile != "" && p.ServerCertFile != "" && p.ServerKeyFile != ""
+}
+
+// NewServerConfig возвращает структуру с необходимыми настройками сервера
+func NewServerConfig(ctx context.Context, logger zerolog.Logger) (*ServerConfig, error) {
+	serverParams, err := getServerInParams()
+	if err != nil {
+		return nil, err
 	}
 
-	if hasSchema(srvCfg.Address) {
-		split := strings.Split(srvCfg.Address, "//")
-		srvCfg.Address = split[1]
+	srvCfg := &ServerConfig{
+		ServerInParams: serverParams,
+		Logger:         logger,
+		Agents:         fleet.NewRegistry(),
+		Cumulative:     cumulative.NewTracker(),
+		Stream:         stream.NewHub(),
+		RateLimiter: ratelimit.New(ratelimit.Limits{
+			RequestsPerSecond: serverParams.RateLimitRPS,
+			Burst:             serverParams.RateLimitBurst,
+			MetricsPerMinute:  serverParams.RateLimitMetricsPerMinute,
+		}),
 	}
 
 	if srvCfg.DatabaseDsn != "" {
@@ -182,6 +381,12 @@ func NewServerConfig(ctx context.Context, logger zerolog.Logger) (*ServerConfig,
 		)
 	}
 
+	if srvCfg.SelfMetricsInterval > 0 {
+		// Метрики сервера сохраняются в исходное хранилище, чтобы их сохранение не учитывалось в них самих
+		srvCfg.SelfMetrics = selfmetrics.NewRegistry(srvCfg.Repo, logger)
+		srvCfg.Repo = selfmetrics.InstrumentRepository(srvCfg.Repo, srvCfg.SelfMetrics)
+	}
+
 	if len(srvCfg.PrivateKeyFileName) > 0 {
 		prvKey, err := getRSAPrivateKey(srvCfg.PrivateKeyFileName)
 		if err != nil {
@@ -190,9 +395,144 @@ func NewServerConfig(ctx context.Context, logger zerolog.Logger) (*ServerConfig,
 		srvCfg.PrivateKey = prvKey
 	}
 
-	if srvCfg.CACertFile != "" && srvCfg.ServerCertFile != "" && srvCfg.ServerKeyFile != "" {
+	if srvCfg.AgentConfigFile != "" {
+		srvCfg.AgentGroups, err = LoadAgentGroups(srvCfg.AgentConfigFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if srvCfg.IngestRulesFile != "" {
+		srvCfg.IngestRules, err = LoadIngestRules(srvCfg.IngestRulesFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if srvCfg.QuerySampleInterval > 0 {
+		srvCfg.History = query.NewHistory(srvCfg.QueryHistoryRetention)
+	}
+	srvCfg.Alerts = alert.NewEngine(srvCfg.Repo, srvCfg.History, logger)
+	if srvCfg.AlertRulesFile != "" {
+		rules, webhooks, err := LoadAlertRules(srvCfg.AlertRulesFile, srvCfg.queryRetention())
+		if err != nil {
+			return nil, err
+		}
+		srvCfg.Alerts.SetRules(rules)
+		srvCfg.Alerts.SetWebhooks(webhooks)
+	}
+	srvCfg.Recording = recording.NewEngine(srvCfg.Repo, srvCfg.History, logger)
+	if srvCfg.RecordingRulesFile != "" {
+		rules, err := LoadRecordingRules(srvCfg.RecordingRulesFile, srvCfg.queryRetention())
+		if err != nil {
+			return nil, err
+		}
+		srvCfg.Recording.SetRules(rules)
+	}
+
+	// Ограничение количества метрик учитывает метрики, восстановленные из хранилища
+	known, err := srvCfg.Repo.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	srvCfg.Series = ingest.NewSeries(known)
+
+	if isTLSEnabled(srvCfg.ServerInParams) {
 		srvCfg.IsTLSEnabled = true
+		srvCfg.certs, err = NewCertStore(srvCfg.CACertFile, srvCfg.ServerCertFile, srvCfg.ServerKeyFile, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return srvCfg, nil
+}
+
+// Reload перечитывает конфигурацию из тех же источников, что и при запуске, и применяет
+// доверенную подсеть, ключ подписи, приватный RSA ключ, TLS сертификаты, настройки групп агентов,
+// правила приема метрик, правила оповещений, адресатов уведомлений и правила записи.
+// Остальные параметры требуют перезапуска сервера, их изменение только логируется.
+// Если новая конфигурация некорректна, продолжают действовать прежние настройки
+func (c *ServerConfig) Reload() error {
+	params, err := getServerInParams()
+	if err != nil {
+		return err
+	}
+
+	var prvKey *rsa.PrivateKey
+	if len(params.PrivateKeyFileName) > 0 {
+		prvKey, err = getRSAPrivateKey(params.PrivateKeyFileName)
+		if err != nil {
+			return err
+		}
+	}
+
+	var agentGroups AgentGroups
+	if params.AgentConfigFile != "" {
+		agentGroups, err = LoadAgentGroups(params.AgentConfigFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	var ingestRules *ingest.Pipeline
+	if params.IngestRulesFile != "" {
+		ingestRules, err = LoadIngestRules(params.IngestRulesFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	alertRules, alertWebhooks := new(alert.RuleSet), new(alert.Webhooks)
+	if params.AlertRulesFile != "" {
+		alertRules, alertWebhooks, err = LoadAlertRules(params.AlertRulesFile, c.queryRetention())
+		if err != nil {
+			return err
+		}
+	}
+
+	recordingRules := new(recording.RuleSet)
+	if params.RecordingRulesFile != "" {
+		recordingRules, err = LoadRecordingRules(params.RecordingRulesFile, c.queryRetention())
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.certs != nil {
+		if !isTLSEnabled(params) {
+			return errors.New("TLS can not be disabled without restart")
+		}
+		if err := c.certs.Reload(params.CACertFile, params.ServerCertFile, params.ServerKeyFile); err != nil {
+			return err
+		}
+	} else if isTLSEnabled(params) {
+		c.Logger.Warn().Msg("server: enabling TLS requires restart")
+	}
+
+	c.reloaded.Store(&reloadableParams{
+		key:           params.Key,
+		trustedSubnet: params.TrustedSubnet,
+		privateKey:    prvKey,
+		agentGroups:   agentGroups,
+		ingestRules:   ingestRules,
+	})
+	c.Alerts.SetRules(alertRules)
+	c.Alerts.SetWebhooks(alertWebhooks)
+	c.Recording.SetRules(recordingRules)
+
+	if params.Address != c.Address || params.GRPCAddress != c.GRPCAddress ||
+		params.DatabaseDsn != c.DatabaseDsn || params.StoreFile != c.StoreFile ||
+		params.StoreInterval != c.StoreInterval || params.Restore != c.Restore ||
+		params.SelfMetricsInterval != c.SelfMetricsInterval || params.AlertEvalInterval != c.AlertEvalInterval ||
+		params.RecordingInterval != c.RecordingInterval || params.QuerySampleInterval != c.QuerySampleInterval ||
+		params.QueryHistoryRetention != c.QueryHistoryRetention {
+		c.Logger.Warn().Msg("server: listen addresses, storage and evaluation intervals change requires restart")
+	}
+	if params.RateLimitRPS != c.RateLimitRPS || params.RateLimitBurst != c.RateLimitBurst ||
+		params.RateLimitMetricsPerMinute != c.RateLimitMetricsPerMinute ||
+		params.RateLimitByClientID != c.RateLimitByClientID {
+		c.Logger.Warn().Msg("server: rate limits change requires restart")
 	}
 
-	return &srvCfg, nil
+	return nil
 }
@@ -0,0 +1,125 @@
+// Package cumulative переводит накопительные счетчики в приращения на стороне сервера.
+// Источник, например агент, присылает в счетчике накопленное значение с момента своего запуска.
+// Сервер помнит последнее значение каждого счетчика от каждого источника и сохраняет разницу.
+// Если значение уменьшилось, источник перезапускался, и приращением считается все новое значение.
+// Первое значение от нового источника тоже считается приращением целиком: источник отсчитывает счетчик
+// с момента своего запуска. Значения хранятся в памяти, поэтому после перезапуска сервера
+// или после простоя источника дольше idleTimeout накопленные значения будут учтены повторно
+package cumulative
+
+import (
+	"sync"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+)
+
+const (
+	// idleTimeout время, после которого значения неактивного источника забываются
+	idleTimeout = 24 * time.Hour
+	// sweepInterval как часто удаляются значения неактивных источников
+	sweepInterval = 10 * time.Minute
+)
+
+// SourceKey возвращает ключ источника метрик: идентификатор процесса, если он передан, иначе идентификатор
+// клиента, иначе IP адрес. Идентификатор клиента обычно имя хоста, поэтому несколько процессов
+// на одном хосте различаются только по идентификатору процесса
+func SourceKey(ip string, clientID string, instanceID string) string {
+	if instanceID != "" {
+		return "instance:" + instanceID
+	}
+	if clientID != "" {
+		return "id:" + clientID
+	}
+	return "ip:" + ip
+}
+
+type source struct {
+	values   map[string]int64
+	lastSeen time.Time
+}
+
+// Result результат перевода счетчиков в приращения
+type Result struct {
+	// Metrics метрики для сохранения, накопительные счетчики заменены приращениями
+	Metrics []metric.Metric
+	// Resets количество счетчиков, значение которых уменьшилось
+	Resets int
+}
+
+// Tracker хранит последние значения накопительных счетчиков по источникам.
+// Методы безопасно вызывать у nil, тогда метрики не изменяются
+type Tracker struct {
+	mu        *sync.Mutex
+	sources   map[string]*source
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewTracker возвращает пустое хранилище значений
+func NewTracker() *Tracker {
+	return &Tracker{
+		mu:        new(sync.Mutex),
+		sources:   make(map[string]*source),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (t *Tracker) source(key string, now time.Time) *source {
+	if now.Sub(t.lastSweep) > sweepInterval {
+		for k, s := range t.sources {
+			if now.Sub(s.lastSeen) > idleTimeout {
+				delete(t.sources, k)
+			}
+		}
+		t.lastSweep = now
+	}
+
+	s, ok := t.sources[key]
+	if !ok {
+		s = &source{values: make(map[string]int64)}
+		t.sources[key] = s
+	}
+	s.lastSeen = now
+	return s
+}
+
+// Apply заменяет накопительные счетчики от источника sourceKey приращениями с прошлого значения.
+// Остальные метрики возвращаются без изменений. Измененные метрики подписываются ключом key
+func (t *Tracker) Apply(sourceKey string, metrics []metric.Metric, key string) Result {
+	if t == nil {
+		return Result{Metrics: metrics}
+	}
+
+	result := Result{Metrics: make([]metric.Metric, 0, len(metrics))}
+	var s *source
+	for _, m := range metrics {
+		if m.GetType() != metric.Counter || !m.Cumulative {
+			result.Metrics = append(result.Metrics, m)
+			continue
+		}
+
+		if s == nil {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			s = t.source(sourceKey, t.now())
+		}
+
+		value := m.GetCounterValue()
+		delta := value
+		if last, ok := s.values[m.ID]; ok {
+			delta = value - last
+			if delta < 0 {
+				delta = value
+				result.Resets++
+			}
+		}
+		s.values[m.ID] = value
+
+		counter := metric.NewCounterMetric(m.ID, delta)
+		counter.SetHash(key)
+		result.Metrics = append(result.Metrics, counter)
+	}
+	return result
+}
@@ -0,0 +1,122 @@
+package cumulative
+
+import (
+	"testing"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker_Apply(t *testing.T) {
+	type step struct {
+		source     string
+		metrics    []metric.Metric
+		want       []string
+		wantResets int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "should count first value and send deltas",
+			steps: []step{
+				{source: "id:agent1", metrics: []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 10)}, want: []string{"/counter/PollCount/10"}},
+				{source: "id:agent1", metrics: []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 15)}, want: []string{"/counter/PollCount/5"}},
+				{source: "id:agent1", metrics: []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 15)}, want: []string{"/counter/PollCount/0"}},
+			},
+		},
+		{
+			name: "should detect reset",
+			steps: []step{
+				{source: "id:agent1", metrics: []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 100)}, want: []string{"/counter/PollCount/100"}},
+				{source: "id:agent1", metrics: []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 3)}, want: []string{"/counter/PollCount/3"}, wantResets: 1},
+				{source: "id:agent1", metrics: []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 7)}, want: []string{"/counter/PollCount/4"}},
+			},
+		},
+		{
+			name: "should track sources separately",
+			steps: []step{
+				{source: "instance:host-1", metrics: []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 10)}, want: []string{"/counter/PollCount/10"}},
+				{source: "instance:host-2", metrics: []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 50)}, want: []string{"/counter/PollCount/50"}},
+				{source: "instance:host-1", metrics: []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 12)}, want: []string{"/counter/PollCount/2"}},
+				{source: "instance:host-2", metrics: []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 51)}, want: []string{"/counter/PollCount/1"}},
+			},
+		},
+		{
+			name: "should keep delta counters and gauges",
+			steps: []step{
+				{
+					source:  "id:agent1",
+					metrics: []metric.Metric{metric.NewCounterMetric("Requests", 5), metric.NewGaugeMetric("Alloc", 1.5)},
+					want:    []string{"/counter/Requests/5", "/gauge/Alloc/1.5"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewTracker()
+			for i, s := range tt.steps {
+				result := tracker.Apply(s.source, s.metrics, "")
+				got := make([]string, len(result.Metrics))
+				for j, m := range result.Metrics {
+					assert.False(t, m.Cumulative)
+					got[j] = m.String()
+				}
+				assert.Equal(t, s.want, got, "step %d", i)
+				assert.Equal(t, s.wantResets, result.Resets, "step %d", i)
+			}
+		})
+	}
+}
+
+func TestTracker_ApplySweepsIdleSources(t *testing.T) {
+	tracker := NewTracker()
+	now := time.Now()
+	tracker.now = func() time.Time { return now }
+
+	tracker.Apply("id:agent1", []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 10)}, "")
+	now = now.Add(idleTimeout + sweepInterval)
+	result := tracker.Apply("id:agent2", []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 1)}, "")
+	assert.Equal(t, []string{"/counter/PollCount/1"}, []string{result.Metrics[0].String()})
+	assert.NotContains(t, tracker.sources, "id:agent1")
+
+	var disabled *Tracker
+	m := []metric.Metric{metric.NewCumulativeCounterMetric("PollCount", 10)}
+	assert.Equal(t, m, disabled.Apply("id:agent1", m, "").Metrics)
+}
+
+func TestSourceKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		ip         string
+		clientID   string
+		instanceID string
+		want       string
+	}{
+		{name: "should prefer instance id", ip: "10.0.0.1", clientID: "host", instanceID: "host-1", want: "instance:host-1"},
+		{name: "should fall back to client id", ip: "10.0.0.1", clientID: "host", want: "id:host"},
+		{name: "should fall back to ip", ip: "10.0.0.1", want: "ip:10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SourceKey(tt.ip, tt.clientID, tt.instanceID))
+		})
+	}
+}
+
+func TestCumulativeCounterHash(t *testing.T) {
+	m := metric.NewCumulativeCounterMetric("PollCount", 10)
+	m.SetHash("secret")
+
+	ok, err := m.CheckHash("secret")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	m.Cumulative = false
+	ok, err = m.CheckHash("secret")
+	assert.NoError(t, err)
+	assert.False(t, ok, "cumulative flag should be signed")
+}
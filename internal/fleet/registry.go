@@ -0,0 +1,125 @@
+// Package fleet хранит сведения о работающих агентах, которые они сообщают серверу через heartbeat
+package fleet
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Heartbeat сведения, которые агент периодически сообщает серверу
+type Heartbeat struct {
+	Hostname      string `json:"hostname"`
+	Group         string `json:"group,omitempty"`
+	Version       string `json:"version"`
+	Commit        string `json:"commit"`
+	OS            string `json:"os"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	// Hash подпись heartbeat тем же ключом, что и подпись метрик
+	Hash string `json:"hash,omitempty"`
+}
+
+// IsValid проверяет, что агент можно идентифицировать
+func (h Heartbeat) IsValid() bool {
+	return h.Hostname != "" && h.UptimeSeconds >= 0
+}
+
+func (h *Heartbeat) generateHash(hashKey string) []byte {
+	mac := hmac.New(sha256.New, []byte(hashKey))
+	mac.Write([]byte(fmt.Sprintf("%s:%s:%s:%s:%s:%d", h.Hostname, h.Group, h.Version, h.Commit, h.OS, h.UptimeSeconds)))
+	return mac.Sum(nil)
+}
+
+// SetHash подписывает heartbeat, без ключа подпись не ставится
+func (h *Heartbeat) SetHash(hashKey string) {
+	if hashKey != "" {
+		h.Hash = hex.EncodeToString(h.generateHash(hashKey))
+	}
+}
+
+// CheckHash проверяет подпись heartbeat, без ключа любой heartbeat считается подписанным верно
+func (h *Heartbeat) CheckHash(hashKey string) (bool, error) {
+	if hashKey != "" {
+		hashActual, err := hex.DecodeString(h.Hash)
+		if err != nil {
+			return false, err
+		}
+		return hmac.Equal(hashActual, h.generateHash(hashKey)), nil
+	}
+	return true, nil
+}
+
+// Agent запись реестра агентов
+type Agent struct {
+	Heartbeat
+	Address   string    `json:"address"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Registry реестр агентов, агенты различаются по имени хоста
+type Registry struct {
+	mu     *sync.RWMutex
+	agents map[string]Agent
+	now    func() time.Time
+}
+
+// NewRegistry возвращает пустой реестр агентов
+func NewRegistry() *Registry {
+	return &Registry{
+		mu:     new(sync.RWMutex),
+		agents: make(map[string]Agent),
+		now:    time.Now,
+	}
+}
+
+// Heartbeat регистрирует агента или обновляет сведения о нем
+func (r *Registry) Heartbeat(h Heartbeat, address string) Agent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	agent, ok := r.agents[h.Hostname]
+	if !ok {
+		agent.FirstSeen = now
+	}
+	agent.Heartbeat = h
+	agent.Hash = ""
+	agent.Address = address
+	agent.LastSeen = now
+	r.agents[h.Hostname] = agent
+
+	return agent
+}
+
+// List возвращает всех известных агентов, отсортированных по имени хоста
+func (r *Registry) List() []Agent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]Agent, 0, len(r.agents))
+	for _, a := range r.agents {
+		result = append(result, a)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Hostname < result[j].Hostname
+	})
+	return result
+}
+
+// Silent возвращает агентов, от которых не было heartbeat дольше указанного времени
+func (r *Registry) Silent(d time.Duration) []Agent {
+	deadline := r.now().Add(-d)
+
+	result := make([]Agent, 0)
+	for _, a := range r.List() {
+		if a.LastSeen.Before(deadline) {
+			result = append(result, a)
+		}
+	}
+	return result
+}
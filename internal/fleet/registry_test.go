@@ -0,0 +1,35 @@
+package fleet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRegistry()
+	r.now = func() time.Time { return now }
+
+	r.Heartbeat(Heartbeat{Hostname: "web-2", Version: "v1.0.0", UptimeSeconds: 10}, "10.0.0.2")
+	r.Heartbeat(Heartbeat{Hostname: "web-1", Version: "v1.0.0", UptimeSeconds: 20}, "10.0.0.1")
+
+	now = now.Add(time.Minute)
+	agent := r.Heartbeat(Heartbeat{Hostname: "web-1", Version: "v1.1.0", UptimeSeconds: 5}, "10.0.0.1")
+	assert.Equal(t, now.Add(-time.Minute), agent.FirstSeen, "first seen time should be kept")
+	assert.Equal(t, now, agent.LastSeen)
+	assert.Equal(t, "v1.1.0", agent.Version)
+
+	var hosts []string
+	for _, a := range r.List() {
+		hosts = append(hosts, a.Hostname)
+	}
+	assert.Equal(t, []string{"web-1", "web-2"}, hosts)
+
+	silent := r.Silent(30 * time.Second)
+	if assert.Len(t, silent, 1) {
+		assert.Equal(t, "web-2", silent[0].Hostname)
+	}
+	assert.Empty(t, r.Silent(2*time.Minute))
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/fleet"
+	"github.com/c0dered273/go-adv-metrics/internal/selfmetrics"
+)
+
+// AgentHeartbeatHandler godoc
+//
+//	@Tags			Agents
+//	@Summary		Регистрирует heartbeat агента
+//	@Description	Сохраняет сведения об агенте и время последнего обращения в реестре агентов.
+//	@Description	Если на сервере задан ключ, heartbeat должен быть подписан им так же, как метрики.
+//	@ID				agentHeartbeat
+//	@Accept			json
+//	@Param			heartbeat	body	fleet.Heartbeat	true	"Agent heartbeat"
+//	@Success		200
+//	@Failure		400	{string}	string	"Bad request"
+//	@Router			/api/v1/agents/heartbeat [post]
+func AgentHeartbeatHandler(c *config.ServerConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var heartbeat fleet.Heartbeat
+		if err := json.NewDecoder(r.Body).Decode(&heartbeat); err != nil {
+			c.Logger.Error().Err(err).Msg("handler: failed to unmarshall request")
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		if !heartbeat.IsValid() {
+			c.Logger.Error().Msgf("handler: invalid heartbeat: %v", heartbeat)
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		ok, err := heartbeat.CheckHash(c.GetKey())
+		if err != nil {
+			c.Logger.Error().Err(err).Msg("handler: failed to check heartbeat hash")
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+		if !ok {
+			c.SelfMetrics.Inc(selfmetrics.HashFailures)
+			c.Logger.Error().Str("hostname", heartbeat.Hostname).Msg("handler: invalid heartbeat hash")
+			http.Error(w, "Bad request", http.StatusBadRequest)
+			return
+		}
+
+		c.Agents.Heartbeat(heartbeat, r.RemoteAddr)
+	}
+}
+
+// ListAgentsHandler godoc
+//
+//	@Tags			Agents
+//	@Summary		Отдает список агентов
+//	@Description	Отдает всех агентов, присылавших heartbeat, со временем последнего обращения.
+//	@Description	С параметром silent_for отдает только агентов, молчащих дольше указанного времени.
+//	@ID				listAgents
+//	@Produce		json
+//	@Param			silent_for	query		string	false	"Duration, e.g. 5m"
+//	@Success		200			{array}		fleet.Agent
+//	@Failure		400			{string}	string	"Bad request"
+//	@Failure		500			{string}	string	"Internal error"
+//	@Router			/api/v1/agents [get]
+func ListAgentsHandler(c *config.ServerConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		agents := c.Agents.List()
+		if silentFor := r.URL.Query().Get("silent_for"); silentFor != "" {
+			d, err := time.ParseDuration(silentFor)
+			if err != nil {
+				c.Logger.Error().Err(err).Msg("handler: invalid silent_for parameter")
+				http.Error(w, "Bad request", http.StatusBadRequest)
+				return
+			}
+			agents = c.Agents.Silent(d)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(agents); err != nil {
+			c.Logger.Error().Err(err).Msg("handler: failed to write response body")
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/fleet"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentsHandlers(t *testing.T) {
+	cfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{
+			Address: "localhost:8080",
+		},
+		Repo:   storage.NewPersistenceRepo(storage.NewMemStorage()),
+		Agents: fleet.NewRegistry(),
+	}
+	h := Service(cfg)
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		body     string
+		wantCode int
+	}{
+		{
+			name:     "should register heartbeat",
+			method:   http.MethodPost,
+			url:      "http://localhost:8080/api/v1/agents/heartbeat",
+			body:     `{"hostname":"web-1","version":"v1.0.0","commit":"abc","os":"linux/amd64","uptime_seconds":42}`,
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "should reject heartbeat without hostname",
+			method:   http.MethodPost,
+			url:      "http://localhost:8080/api/v1/agents/heartbeat",
+			body:     `{"version":"v1.0.0"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "should reject invalid silent_for",
+			method:   http.MethodGet,
+			url:      "http://localhost:8080/api/v1/agents?silent_for=soon",
+			wantCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, tt.url, bytes.NewReader([]byte(tt.body)))
+			request.Header.Set("X-Real-IP", "10.0.0.1")
+			writer := httptest.NewRecorder()
+			h.ServeHTTP(writer, request)
+			res := writer.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantCode, res.StatusCode)
+		})
+	}
+
+	list := func(url string) []fleet.Agent {
+		writer := httptest.NewRecorder()
+		h.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, url, nil))
+		res := writer.Result()
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		var agents []fleet.Agent
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&agents))
+		return agents
+	}
+
+	agents := list("http://localhost:8080/api/v1/agents")
+	if assert.Len(t, agents, 1) {
+		assert.Equal(t, "web-1", agents[0].Hostname)
+		assert.Equal(t, "v1.0.0", agents[0].Version)
+		assert.Equal(t, int64(42), agents[0].UptimeSeconds)
+		assert.Equal(t, "10.0.0.1", agents[0].Address)
+		assert.False(t, agents[0].LastSeen.IsZero())
+	}
+	assert.Empty(t, list("http://localhost:8080/api/v1/agents?silent_for=1h"))
+}
+
+func TestAgentHeartbeatHandler_Hash(t *testing.T) {
+	const key = "secret"
+	cfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{
+			Address: "localhost:8080",
+			Key:     key,
+		},
+		Repo:   storage.NewPersistenceRepo(storage.NewMemStorage()),
+		Agents: fleet.NewRegistry(),
+	}
+	h := Service(cfg)
+
+	signed := fleet.Heartbeat{Hostname: "web-1", Version: "v1.0.0", UptimeSeconds: 42}
+	signed.SetHash(key)
+	forged := signed
+	forged.Hostname = "web-2"
+	otherKey := signed
+	otherKey.SetHash("other")
+
+	tests := []struct {
+		name      string
+		heartbeat fleet.Heartbeat
+		wantCode  int
+	}{
+		{name: "should accept signed heartbeat", heartbeat: signed, wantCode: http.StatusOK},
+		{name: "should reject unsigned heartbeat", heartbeat: fleet.Heartbeat{Hostname: "web-1"}, wantCode: http.StatusBadRequest},
+		{name: "should reject changed heartbeat", heartbeat: forged, wantCode: http.StatusBadRequest},
+		{name: "should reject heartbeat signed with other key", heartbeat: otherKey, wantCode: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := json.Marshal(tt.heartbeat)
+			assert.NoError(t, err)
+			request := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/v1/agents/heartbeat", bytes.NewReader(body))
+			writer := httptest.NewRecorder()
+			h.ServeHTTP(writer, request)
+			res := writer.Result()
+			defer res.Body.Close()
+			assert.Equal(t, tt.wantCode, res.StatusCode)
+		})
+	}
+
+	agents := cfg.Agents.List()
+	if assert.Len(t, agents, 1) {
+		assert.Equal(t, "web-1", agents[0].Hostname)
+		assert.Empty(t, agents[0].Hash, "registry should not keep heartbeat hash")
+	}
+}
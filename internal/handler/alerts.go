@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+)
+
+// ListAlertsHandler godoc
+//
+//	@Tags			Alerts
+//	@Summary		Отдает состояние оповещений
+//	@Description	Отдает состояние всех правил оповещений на момент последней проверки.
+//	@Description	С параметром state отдает только оповещения в указанном состоянии.
+//	@Description	Для правил absent в last_update отдается время последней записи метрики с запуска сервера.
+//	@ID				listAlerts
+//	@Produce		json
+//	@Param			state	query		string	false	"inactive, pending, firing or resolved"
+//	@Success		200		{array}		alert.Alert
+//	@Failure		500		{string}	string	"Internal error"
+//	@Router			/api/v1/alerts [get]
+func ListAlertsHandler(c *config.ServerConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		alerts := c.Alerts.Alerts()
+		if state := r.URL.Query().Get("state"); state != "" {
+			filtered := alerts[:0]
+			for _, a := range alerts {
+				if a.State == state {
+					filtered = append(filtered, a)
+				}
+			}
+			alerts = filtered
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(alerts); err != nil {
+			c.Logger.Error().Err(err).Msg("handler: failed to write response body")
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
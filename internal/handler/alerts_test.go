@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/c0dered273/go-adv-metrics/internal/alert"
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListAlertsHandler(t *testing.T) {
+	repo := storage.NewPersistenceRepo(storage.NewMemStorage())
+	assert.NoError(t, repo.Save(context.Background(), metric.NewGaugeMetric("FreeMemory", 5e8)))
+
+	engine := alert.NewEngine(repo, nil, zerolog.Nop())
+	rules, err := alert.NewRuleSet([]alert.Rule{
+		{Name: "LowMemory", Expr: "FreeMemory < 1e9"},
+		{Name: "HighMemory", Expr: "FreeMemory > 1e10"},
+	})
+	assert.NoError(t, err)
+	engine.SetRules(rules)
+	assert.NoError(t, engine.Evaluate(context.Background()))
+
+	cfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{
+			Address: "localhost:8080",
+		},
+		Repo:   repo,
+		Alerts: engine,
+	}
+	h := Service(cfg)
+
+	tests := []struct {
+		name      string
+		url       string
+		wantRules []string
+	}{
+		{
+			name:      "should list all alerts",
+			url:       "http://localhost:8080/api/v1/alerts",
+			wantRules: []string{"HighMemory", "LowMemory"},
+		},
+		{
+			name:      "should list firing alerts",
+			url:       "http://localhost:8080/api/v1/alerts?state=firing",
+			wantRules: []string{"LowMemory"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := httptest.NewRecorder()
+			h.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			res := writer.Result()
+			defer res.Body.Close()
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+
+			var alerts []alert.Alert
+			assert.NoError(t, json.NewDecoder(res.Body).Decode(&alerts))
+			rules := make([]string, len(alerts))
+			for i, a := range alerts {
+				rules[i] = a.Rule
+			}
+			assert.Equal(t, tt.wantRules, rules)
+		})
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/c0dered273/go-adv-metrics/internal/config"
 	"github.com/c0dered273/go-adv-metrics/internal/metric"
 	middleware2 "github.com/c0dered273/go-adv-metrics/internal/middleware"
+	"github.com/c0dered273/go-adv-metrics/internal/selfmetrics"
+	"github.com/c0dered273/go-adv-metrics/internal/service"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
@@ -107,7 +109,11 @@ func StoreMetricFromURLRequestHandler(c *config.ServerConfig) http.HandlerFunc {
 			return
 		}
 
-		err := c.Repo.Save(r.Context(), newMetric)
+		if !allowMetrics(c, w, r, 1) {
+			return
+		}
+
+		err := service.StoreMetrics(r.Context(), c, middleware2.SourceKey(r), []metric.Metric{newMetric})
 		if err != nil {
 			c.Logger.Error().Err(err).Msg("handler: failed to save metric")
 			http.Error(w, "Internal error", http.StatusInternalServerError)
@@ -144,19 +150,24 @@ func StoreMetricFromJSONHandler(c *config.ServerConfig) http.HandlerFunc {
 			return
 		}
 
-		ok, err := newMetric.CheckHash(c.Key)
+		ok, err := newMetric.CheckHash(c.GetKey())
 		if err != nil {
 			c.Logger.Error().Err(err).Msg("handler: failed to check metric hash")
 			http.Error(w, "Internal error", http.StatusInternalServerError)
 			return
 		}
 		if !ok {
+			c.SelfMetrics.Inc(selfmetrics.HashFailures)
 			c.Logger.Error().Msg("handler: invalid metric hash")
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
 
-		err = c.Repo.Save(r.Context(), newMetric)
+		if !allowMetrics(c, w, r, 1) {
+			return
+		}
+
+		err = service.StoreMetrics(r.Context(), c, middleware2.SourceKey(r), []metric.Metric{newMetric})
 		if err != nil {
 			c.Logger.Error().Err(err).Msg("handler: failed to save metric")
 			http.Error(w, "Internal error", http.StatusInternalServerError)
@@ -194,19 +205,24 @@ func StoreAllMetricsFromJSONHandler(c *config.ServerConfig) http.HandlerFunc {
 			return
 		}
 
-		ok, err := newMetrics.CheckHash(c.Key)
+		ok, err := newMetrics.CheckHash(c.GetKey())
 		if err != nil {
 			c.Logger.Error().Err(err).Msg("handler: failed to check metric hash")
 			http.Error(w, "Internal error", http.StatusInternalServerError)
 			return
 		}
 		if !ok {
+			c.SelfMetrics.Inc(selfmetrics.HashFailures)
 			c.Logger.Error().Msg("handler: invalid metric hash")
 			http.Error(w, "Bad request", http.StatusBadRequest)
 			return
 		}
 
-		err = c.Repo.SaveAll(r.Context(), newMetrics.Metrics)
+		if !allowMetrics(c, w, r, len(newMetrics.Metrics)) {
+			return
+		}
+
+		err = service.StoreMetrics(r.Context(), c, middleware2.SourceKey(r), newMetrics.Metrics)
 		if err != nil {
 			c.Logger.Error().Err(err).Msg("handler: failed to save metric")
 			http.Error(w, "Internal error", http.StatusInternalServerError)
@@ -248,7 +264,7 @@ func LoadMetricByJSONHandler(c *config.ServerConfig) http.HandlerFunc {
 			return
 		}
 
-		resultMetric.SetHash(c.Key)
+		resultMetric.SetHash(c.GetKey())
 
 		resultBody, err := json.Marshal(resultMetric)
 		if err != nil {
@@ -317,27 +333,52 @@ func LoadMetricByURLRequestHandler(c *config.ServerConfig) http.HandlerFunc {
 	}
 }
 
+// allowMetrics проверяет квоту клиента на количество принимаемых метрик, при превышении отвечает 429
+func allowMetrics(c *config.ServerConfig, w http.ResponseWriter, r *http.Request, n int) bool {
+	ok, retryAfter := c.RateLimiter.AllowMetrics(middleware2.ClientKey(c, r), n)
+	if !ok {
+		c.SelfMetrics.Add(selfmetrics.RateLimitedMetrics, int64(n))
+		c.Logger.Warn().Str("client", middleware2.ClientKey(c, r)).Msg("handler: metrics quota exceeded")
+		middleware2.TooManyRequests(w, retryAfter)
+	}
+	return ok
+}
+
 func Service(config *config.ServerConfig) http.Handler {
 	r := chi.NewRouter()
 	r.Use(middleware.RealIP)
 	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
+	r.Use(middleware2.Metrics(config))
 	r.Use(middleware.Recoverer)
 	r.Use(middleware2.TrustedSubnet(config))
-	r.Use(middleware.Timeout(30 * time.Second))
-	r.Use(middleware2.GzipRequestDecoder)
-	r.Use(middleware.Compress(5))
-	r.Use(middleware2.RSADecrypt(config.PrivateKey))
-
-	r.Mount("/debug", middleware.Profiler())
-
-	r.Get("/", RootHandler(config))
-	r.Get("/ping", ConnectionPingHandler(config))
-	r.Post("/value/", LoadMetricByJSONHandler(config))
-	r.Get("/value/{type}/{name}", LoadMetricByURLRequestHandler(config))
-	r.Post("/update/", StoreMetricFromJSONHandler(config))
-	r.Post("/updates/", StoreAllMetricsFromJSONHandler(config))
-	r.Post("/update/{type}/{name}/{value}", StoreMetricFromURLRequestHandler(config))
+	r.Use(middleware2.RateLimit(config))
+
+	// Поток событий открыт, пока клиент не отключится, поэтому на него не действует таймаут запроса,
+	// а сжатие буферизовало бы события
+	r.Get("/api/v1/stream", StreamHandler(config))
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(30 * time.Second))
+		r.Use(middleware2.GzipRequestDecoder)
+		r.Use(middleware.Compress(5))
+		r.Use(middleware2.RSADecrypt(config))
+
+		r.Mount("/debug", middleware.Profiler())
+
+		r.Get("/", RootHandler(config))
+		r.Get("/ping", ConnectionPingHandler(config))
+		r.Post("/value/", LoadMetricByJSONHandler(config))
+		r.Get("/value/{type}/{name}", LoadMetricByURLRequestHandler(config))
+		r.Post("/update/", StoreMetricFromJSONHandler(config))
+		r.Post("/updates/", StoreAllMetricsFromJSONHandler(config))
+		r.Post("/update/{type}/{name}/{value}", StoreMetricFromURLRequestHandler(config))
+
+		r.Post("/api/v1/agents/heartbeat", AgentHeartbeatHandler(config))
+		r.Get("/api/v1/agents", ListAgentsHandler(config))
+		r.Get("/api/v1/alerts", ListAlertsHandler(config))
+		r.Get("/api/v1/query", QueryHandler(config))
+	})
 
 	return r
 }
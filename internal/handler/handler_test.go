@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
@@ -13,7 +14,9 @@ import (
 	"testing"
 
 	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/cumulative"
 	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/ratelimit"
 	"github.com/c0dered273/go-adv-metrics/internal/storage"
 	"github.com/go-resty/resty/v2"
 	"github.com/stretchr/testify/assert"
@@ -727,5 +730,111 @@ func ExampleLoadMetricByJSONHandler() {
 	fmt.Println(responseMetric)
 
 	// Output:
-	// { gauge <nil> <nil> }
+	// { gauge <nil> <nil>  false}
+}
+
+func TestRateLimit(t *testing.T) {
+	cfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{
+			Address:             "localhost:8080",
+			RateLimitByClientID: true,
+		},
+		Repo:        storage.NewPersistenceRepo(storage.NewMemStorage()),
+		RateLimiter: ratelimit.New(ratelimit.Limits{RequestsPerSecond: 0.5, Burst: 2, MetricsPerMinute: 3}),
+	}
+	h := Service(cfg)
+
+	send := func(clientID string, body string) *http.Response {
+		request := httptest.NewRequest("POST", "http://localhost:8080/updates/", bytes.NewReader(JSONtoByte(body)))
+		request.Header.Set("X-Real-IP", "10.0.0.11")
+		request.Header.Set("X-Client-ID", clientID)
+		writer := httptest.NewRecorder()
+		h.ServeHTTP(writer, request)
+		return writer.Result()
+	}
+	batch := `[{"id": "Alloc", "value": 1, "type": "gauge"}, {"id": "Frees", "value": 2, "type": "gauge"}]`
+
+	res := send("agent-1", batch)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = send("agent-1", batch)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode, "metrics quota should be exceeded")
+	assert.Equal(t, "20", res.Header.Get("Retry-After"))
+
+	res = send("agent-1", batch)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode, "request rate should be exceeded")
+	assert.Equal(t, "2", res.Header.Get("Retry-After"))
+
+	res = send("agent-2", batch)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "clients should be limited separately")
+}
+
+func TestCumulativeCounter(t *testing.T) {
+	cfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{
+			Address: "localhost:8080",
+		},
+		Repo:       storage.NewPersistenceRepo(storage.NewMemStorage()),
+		Cumulative: cumulative.NewTracker(),
+	}
+	h := Service(cfg)
+
+	send := func(instanceID string, value int) {
+		body := fmt.Sprintf(`{"id": "PollCount", "delta": %d, "type": "counter", "cumulative": true}`, value)
+		request := httptest.NewRequest("POST", "http://localhost:8080/update/", bytes.NewReader(JSONtoByte(body)))
+		request.Header.Set("X-Client-ID", "host")
+		request.Header.Set("X-Instance-ID", instanceID)
+		writer := httptest.NewRecorder()
+		h.ServeHTTP(writer, request)
+		res := writer.Result()
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+
+	// Два агента на одном хосте различаются по идентификатору процесса. Первое значение от процесса
+	// сохраняется целиком, затем приращения, после сброса agent-1 приращением считается все новое значение
+	send("agent-1", 10)
+	send("agent-2", 100)
+	send("agent-1", 15)
+	send("agent-2", 103)
+	send("agent-1", 4)
+
+	m, err := cfg.Repo.FindByID(context.Background(), metric.NewCounterMetric("PollCount", 0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(122), m.GetCounterValue())
+}
+
+func TestCumulativeCounterBatch(t *testing.T) {
+	cfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{
+			Address: "localhost:8080",
+		},
+		Repo:       storage.NewPersistenceRepo(storage.NewMemStorage()),
+		Cumulative: cumulative.NewTracker(),
+	}
+	h := Service(cfg)
+
+	send := func(value int) {
+		body := fmt.Sprintf(`[{"id": "PollCount", "delta": %d, "type": "counter", "cumulative": true}, {"id": "Alloc", "value": 1.5, "type": "gauge"}]`, value)
+		request := httptest.NewRequest("POST", "http://localhost:8080/updates/", bytes.NewReader(JSONtoByte(body)))
+		request.Header.Set("X-Instance-ID", "host-1")
+		writer := httptest.NewRecorder()
+		h.ServeHTTP(writer, request)
+		res := writer.Result()
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	}
+
+	// Приращения из корзин прибавляются к сохраненному значению
+	send(10)
+	send(15)
+	send(20)
+
+	m, err := cfg.Repo.FindByID(context.Background(), metric.NewCounterMetric("PollCount", 0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), m.GetCounterValue())
 }
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/query"
+)
+
+// QueryHandler godoc
+//
+//	@Tags			Query
+//	@Summary		Вычисляет выражение над метриками
+//	@Description	Вычисляет выражение над текущими значениями метрик, например HeapInuse / HeapSys,
+//	@Description	sum(~"CPUutilization.*") или rate(PollCount[1m]). Отдает одно число в scalar
+//	@Description	или значения выбранных метрик в series. Если метрик нет, series пустой.
+//	@ID				query
+//	@Produce		json
+//	@Param			expr	query		string	true	"Query expression"
+//	@Success		200		{object}	query.Result
+//	@Failure		400		{string}	string	"Invalid expression"
+//	@Failure		500		{string}	string	"Internal error"
+//	@Router			/api/v1/query [get]
+func QueryHandler(c *config.ServerConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		expr, err := query.Parse(r.URL.Query().Get("expr"))
+		if err != nil {
+			c.Logger.Error().Err(err).Msg("handler: failed to parse query")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		metrics, err := c.Repo.FindAll(r.Context())
+		if err != nil {
+			c.Logger.Error().Err(err).Msg("handler: failed to load metrics")
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+
+		result, err := expr.Query(&query.Env{Values: query.Values(metrics), History: c.History, Now: time.Now()})
+		if errors.Is(err, query.ErrNoData) {
+			result, err = query.Result{Series: []query.Series{}}, nil
+		}
+		if err != nil {
+			c.Logger.Error().Err(err).Msg("handler: failed to evaluate query")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			c.Logger.Error().Err(err).Msg("handler: failed to write response body")
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryHandler(t *testing.T) {
+	repo := storage.NewPersistenceRepo(storage.NewMemStorage())
+	assert.NoError(t, repo.SaveAll(context.Background(), []metric.Metric{
+		metric.NewGaugeMetric("HeapInuse", 50),
+		metric.NewGaugeMetric("HeapSys", 200),
+		metric.NewGaugeMetric("CPUutilization1", 10),
+		metric.NewGaugeMetric("CPUutilization2", 30),
+	}))
+
+	cfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{
+			Address: "localhost:8080",
+		},
+		Repo: repo,
+	}
+	h := Service(cfg)
+
+	tests := []struct {
+		name       string
+		expr       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "should return scalar",
+			expr:       "HeapInuse / HeapSys",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"scalar":0.25,"series":[]}`,
+		},
+		{
+			name:       "should return selected metrics",
+			expr:       `~"CPUutilization.*" * 2`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"series":[{"name":"CPUutilization1","value":20},{"name":"CPUutilization2","value":60}]}`,
+		},
+		{
+			name:       "should return aggregation",
+			expr:       `count(~"CPUutilization.*") + max(~"CPUutilization.*")`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"scalar":32,"series":[]}`,
+		},
+		{
+			name:       "should return empty result without data",
+			expr:       "avg(~\"Unknown.*\")",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"series":[]}`,
+		},
+		{
+			name:       "should fail on invalid expression",
+			expr:       "HeapInuse /",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := httptest.NewRecorder()
+			target := "http://localhost:8080/api/v1/query?expr=" + url.QueryEscape(tt.expr)
+			h.ServeHTTP(writer, httptest.NewRequest(http.MethodGet, target, nil))
+			res := writer.Result()
+			defer res.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, res.StatusCode)
+			if tt.wantBody != "" {
+				body, err := io.ReadAll(res.Body)
+				assert.NoError(t, err)
+				assert.JSONEq(t, tt.wantBody, string(body))
+			}
+		})
+	}
+}
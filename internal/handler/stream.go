@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/selfmetrics"
+	"github.com/c0dered273/go-adv-metrics/internal/stream"
+)
+
+// streamKeepAlive интервал комментариев, которые не дают прокси закрыть неактивное соединение
+const streamKeepAlive = 15 * time.Second
+
+// StreamHandler godoc
+//
+//	@Tags			Stream
+//	@Summary		Поток сохраненных метрик
+//	@Description	Отдает поток Server-Sent Events с каждой сохраненной сервером метрикой в событии metric.
+//	@Description	Счетчики приходят с сохраненным значением, а не с принятым приращением.
+//	@Description	Параметр match ограничивает поток метриками, ID которых соответствует регулярному выражению.
+//	@Description	Если клиент не успевает читать поток, часть метрик пропускается, количество пропущенных
+//	@Description	метрик приходит в событии dropped.
+//	@ID				stream
+//	@Produce		text/event-stream
+//	@Param			match	query		string	false	"Metric ID regex"
+//	@Success		200		{object}	metric.Metric
+//	@Failure		400		{string}	string	"Invalid match expression"
+//	@Failure		500		{string}	string	"Streaming unsupported"
+//	@Router			/api/v1/stream [get]
+func StreamHandler(c *config.ServerConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var match *regexp.Regexp
+		if expr := r.URL.Query().Get("match"); expr != "" {
+			var err error
+			match, err = regexp.Compile(expr)
+			if err != nil {
+				c.Logger.Error().Err(err).Msg("handler: invalid stream match expression")
+				http.Error(w, "Invalid match expression: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			c.Logger.Error().Msg("handler: response writer does not support streaming")
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		sub := c.Stream.Subscribe(stream.MatchID(match), stream.DefaultBuffer)
+		defer c.Stream.Unsubscribe(sub)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+
+		for {
+			select {
+			case m, ok := <-sub.C:
+				if !ok {
+					return
+				}
+				if err := writeStreamEvents(w, c, sub, m); err != nil {
+					c.Logger.Debug().Err(err).Msg("handler: failed to write stream event")
+					return
+				}
+			case <-keepAlive.C:
+				if err := writeDropped(w, c, sub); err != nil {
+					return
+				}
+				if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+					return
+				}
+			case <-r.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeStreamEvents пишет метрику и все уже накопленные в буфере подписки метрики
+func writeStreamEvents(w io.Writer, c *config.ServerConfig, sub *stream.Subscription, m metric.Metric) error {
+	for {
+		if err := writeDropped(w, c, sub); err != nil {
+			return err
+		}
+		if err := writeEvent(w, "metric", m); err != nil {
+			return err
+		}
+
+		var ok bool
+		select {
+		case m, ok = <-sub.C:
+			if !ok {
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// writeDropped сообщает клиенту о метриках, пропущенных с прошлого события
+func writeDropped(w io.Writer, c *config.ServerConfig, sub *stream.Subscription) error {
+	n := sub.Dropped()
+	if n == 0 {
+		return nil
+	}
+	c.SelfMetrics.Add(selfmetrics.StreamDropped, n)
+	return writeEvent(w, "dropped", struct {
+		Count int64 `json:"count"`
+	}{Count: n})
+}
+
+func writeEvent(w io.Writer, event string, data any) error {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+	return err
+}
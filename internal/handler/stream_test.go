@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/c0dered273/go-adv-metrics/internal/stream"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamHandler(t *testing.T) {
+	cfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{
+			Address: "localhost:8080",
+		},
+		Logger: zerolog.Nop(),
+		Repo:   storage.NewPersistenceRepo(storage.NewMemStorage()),
+		Stream: stream.NewHub(),
+	}
+	server := httptest.NewServer(Service(cfg))
+	defer server.Close()
+
+	t.Run("should reject invalid match expression", func(t *testing.T) {
+		res, err := http.Get(server.URL + "/api/v1/stream?match=(")
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	})
+
+	t.Run("should stream matching metrics", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/stream?match=^Poll", nil)
+		assert.NoError(t, err)
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+		for _, url := range []string{"/update/gauge/Alloc/1.5", "/update/counter/PollCount/5", "/update/counter/PollCount/7"} {
+			post, err := http.Post(server.URL+url, "text/plain", nil)
+			assert.NoError(t, err)
+			post.Body.Close()
+			assert.Equal(t, http.StatusOK, post.StatusCode)
+		}
+
+		reader := bufio.NewReader(res.Body)
+		var event []string
+		for len(event) < 4 {
+			line, err := reader.ReadString('\n')
+			if !assert.NoError(t, err) {
+				return
+			}
+			if line = strings.TrimSpace(line); line != "" {
+				event = append(event, line)
+			}
+		}
+		assert.Equal(t, []string{
+			"event: metric", `data: {"id":"PollCount","delta":5,"type":"counter"}`,
+			"event: metric", `data: {"id":"PollCount","delta":12,"type":"counter"}`,
+		}, event, "counters should be streamed with stored value")
+	})
+}
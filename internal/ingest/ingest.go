@@ -0,0 +1,174 @@
+// Package ingest содержит правила приема метрик сервером: списки разрешенных и запрещенных имен,
+// переименование и ограничение количества различных метрик в хранилище.
+// Правила применяются к принятым метрикам после проверки подписи и до сохранения
+package ingest
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/relabel"
+)
+
+// Rules настройки приема метрик.
+// Allow и Deny - регулярные выражения, которым имя метрики должно соответствовать целиком.
+// MaxSeries - максимальное количество различных метрик в хранилище, 0 - без ограничения
+type Rules struct {
+	Allow     []string       `mapstructure:"allow"`
+	Deny      []string       `mapstructure:"deny"`
+	Relabel   []relabel.Rule `mapstructure:"relabel"`
+	MaxSeries int            `mapstructure:"max_series"`
+}
+
+// Pipeline скомпилированные правила приема. Метрика последовательно проходит списки Deny и Allow по исходному имени,
+// правила переименования и ограничение количества метрик по итоговому имени.
+// Методы безопасно вызывать у nil, тогда принимаются все метрики
+type Pipeline struct {
+	allow     []*regexp.Regexp
+	deny      []*regexp.Regexp
+	relabel   *relabel.Pipeline
+	maxSeries int
+}
+
+// New проверяет и компилирует правила приема
+func New(rules Rules) (*Pipeline, error) {
+	if rules.MaxSeries < 0 {
+		return nil, fmt.Errorf("max_series must not be negative, got %d", rules.MaxSeries)
+	}
+
+	allow, err := compileAll(rules.Allow)
+	if err != nil {
+		return nil, fmt.Errorf("allow: %w", err)
+	}
+	deny, err := compileAll(rules.Deny)
+	if err != nil {
+		return nil, fmt.Errorf("deny: %w", err)
+	}
+	rl, err := relabel.New(rules.Relabel)
+	if err != nil {
+		return nil, fmt.Errorf("relabel: %w", err)
+	}
+
+	return &Pipeline{
+		allow:     allow,
+		deny:      deny,
+		relabel:   rl,
+		maxSeries: rules.MaxSeries,
+	}, nil
+}
+
+func compileAll(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		re, err := regexp.Compile("^(?:" + p + ")$")
+		if err != nil {
+			return nil, err
+		}
+		result[i] = re
+	}
+	return result, nil
+}
+
+func matchAny(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Result итог применения правил к пакету метрик
+type Result struct {
+	// Accepted метрики, которые нужно сохранить
+	Accepted []metric.Metric
+	// Filtered количество метрик, отброшенных списками имен и правилами переименования
+	Filtered int
+	// OverLimit количество новых метрик, отброшенных из-за ограничения количества метрик
+	OverLimit int
+}
+
+// Apply применяет правила к метрикам. Переименованные метрики подписываются заново ключом сервера,
+// чтобы подпись в хранилище соответствовала новому имени. Исходный слайс не меняется
+func (p *Pipeline) Apply(metrics []metric.Metric, series *Series, key string) Result {
+	if p == nil {
+		return Result{Accepted: metrics}
+	}
+
+	result := Result{Accepted: make([]metric.Metric, 0, len(metrics))}
+	for _, m := range metrics {
+		if matchAny(p.deny, m.ID) || (len(p.allow) > 0 && !matchAny(p.allow, m.ID)) {
+			result.Filtered++
+			continue
+		}
+
+		name, ok := p.relabel.Relabel(m.ID)
+		if !ok {
+			result.Filtered++
+			continue
+		}
+		if name != m.ID {
+			m.ID = name
+			m.SetHash(key)
+		}
+
+		if !series.admit(m, p.maxSeries) {
+			result.OverLimit++
+			continue
+		}
+		result.Accepted = append(result.Accepted, m)
+	}
+	return result
+}
+
+// Series множество метрик, известных серверу, используется для ограничения их количества.
+// Множество переживает перечитывание правил приема
+type Series struct {
+	mu   *sync.Mutex
+	seen map[string]struct{}
+}
+
+// NewSeries возвращает множество, заполненное уже сохраненными метриками
+func NewSeries(known []metric.Metric) *Series {
+	s := &Series{
+		mu:   new(sync.Mutex),
+		seen: make(map[string]struct{}, len(known)),
+	}
+	for _, m := range known {
+		s.seen[seriesID(m)] = struct{}{}
+	}
+	return s
+}
+
+// Len возвращает количество известных метрик
+func (s *Series) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.seen)
+}
+
+// admit запоминает метрику, если она уже известна или ограничение еще не достигнуто
+func (s *Series) admit(m metric.Metric, limit int) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := seriesID(m)
+	if _, ok := s.seen[id]; ok {
+		return true
+	}
+	if limit > 0 && len(s.seen) >= limit {
+		return false
+	}
+	s.seen[id] = struct{}{}
+	return true
+}
+
+func seriesID(m metric.Metric) string {
+	return m.GetName() + "/" + m.GetType().String()
+}
@@ -0,0 +1,131 @@
+package ingest
+
+import (
+	"testing"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/relabel"
+	"github.com/stretchr/testify/assert"
+)
+
+func names(metrics []metric.Metric) []string {
+	result := make([]string, len(metrics))
+	for i, m := range metrics {
+		result[i] = m.String()
+	}
+	return result
+}
+
+func TestPipeline_Apply(t *testing.T) {
+	incoming := []metric.Metric{
+		metric.NewGaugeMetric("Alloc", 1),
+		metric.NewGaugeMetric("MCacheSys", 2),
+		metric.NewGaugeMetric("CPUutilization1", 3),
+		metric.NewCounterMetric("PollCount", 4),
+	}
+
+	tests := []struct {
+		name          string
+		rules         Rules
+		known         []metric.Metric
+		want          []string
+		wantFiltered  int
+		wantOverLimit int
+	}{
+		{
+			name:  "should accept all metrics without rules",
+			rules: Rules{},
+			want:  []string{"/gauge/Alloc/1", "/gauge/MCacheSys/2", "/gauge/CPUutilization1/3", "/counter/PollCount/4"},
+		},
+		{
+			name:         "should drop denied metrics",
+			rules:        Rules{Deny: []string{"MCache.*"}},
+			want:         []string{"/gauge/Alloc/1", "/gauge/CPUutilization1/3", "/counter/PollCount/4"},
+			wantFiltered: 1,
+		},
+		{
+			name:         "should accept only allowed metrics",
+			rules:        Rules{Allow: []string{"CPU.*", "PollCount"}, Deny: []string{"PollCount"}},
+			want:         []string{"/gauge/CPUutilization1/3"},
+			wantFiltered: 3,
+		},
+		{
+			name: "should rename metrics",
+			rules: Rules{Relabel: []relabel.Rule{
+				{Action: relabel.ActionRename, Regex: "CPUutilization(\\d+)", Replacement: "cpu_${1}"},
+				{Action: relabel.ActionDrop, Regex: "Alloc|MCacheSys"},
+			}},
+			want:         []string{"/gauge/cpu_1/3", "/counter/PollCount/4"},
+			wantFiltered: 2,
+		},
+		{
+			name:          "should accept known metrics over limit",
+			rules:         Rules{MaxSeries: 2},
+			known:         []metric.Metric{metric.NewCounterMetric("PollCount", 1)},
+			want:          []string{"/gauge/Alloc/1", "/counter/PollCount/4"},
+			wantOverLimit: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.rules)
+			assert.NoError(t, err)
+
+			result := p.Apply(incoming, NewSeries(tt.known), "")
+			assert.Equal(t, tt.want, names(result.Accepted))
+			assert.Equal(t, tt.wantFiltered, result.Filtered)
+			assert.Equal(t, tt.wantOverLimit, result.OverLimit)
+		})
+	}
+}
+
+func TestPipeline_ApplyResignsRenamed(t *testing.T) {
+	key := "secret"
+	m := metric.NewGaugeMetric("CPUutilization1", 3)
+	m.SetHash(key)
+
+	p, err := New(Rules{Relabel: []relabel.Rule{
+		{Action: relabel.ActionAddPrefix, Prefix: "host_"},
+	}})
+	assert.NoError(t, err)
+
+	result := p.Apply([]metric.Metric{m}, nil, key)
+	assert.Len(t, result.Accepted, 1)
+	ok, err := result.Accepted[0].CheckHash(key)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "CPUutilization1", m.ID, "source metric should not change")
+
+	var disabled *Pipeline
+	assert.Equal(t, []metric.Metric{m}, disabled.Apply([]metric.Metric{m}, nil, key).Accepted)
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   Rules
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "should fail on invalid allow regex",
+			rules:   Rules{Allow: []string{"("}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on invalid relabel rule",
+			rules:   Rules{Relabel: []relabel.Rule{{Action: "unknown", Regex: ".*"}}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on negative limit",
+			rules:   Rules{MaxSeries: -1},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.rules)
+			tt.wantErr(t, err)
+		})
+	}
+}
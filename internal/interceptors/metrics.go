@@ -0,0 +1,56 @@
+package interceptors
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// MetricsUnaryServerInterceptor учитывает вызовы gRPC методов в метриках сервера: счетчик
+// ServerGRPCRequests_<сервис>_<метод>_<код ответа> и гистограмму длительности ServerGRPCLatency_<сервис>_<метод>
+func MetricsUnaryServerInterceptor(cfg *config.ServerConfig) func(context.Context, interface{}, *grpc.UnaryServerInfo, grpc.UnaryHandler) (resp interface{}, err error) {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		if cfg.SelfMetrics == nil {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		resp, err = handler(ctx, req)
+		observeCall(cfg, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// MetricsStreamServerInterceptor учитывает потоковые вызовы так же, как MetricsUnaryServerInterceptor,
+// длительность считается до закрытия потока
+func MetricsStreamServerInterceptor(cfg *config.ServerConfig) func(interface{}, grpc.ServerStream, *grpc.StreamServerInfo, grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if cfg.SelfMetrics == nil {
+			return handler(srv, ss)
+		}
+
+		start := time.Now()
+		err := handler(srv, ss)
+		observeCall(cfg, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func observeCall(cfg *config.ServerConfig, fullMethod string, start time.Time, err error) {
+	method := getMethodName(fullMethod)
+	cfg.SelfMetrics.Inc("ServerGRPCRequests_" + method + "_" + status.Code(err).String())
+	cfg.SelfMetrics.Observe("ServerGRPCLatency_"+method, time.Since(start))
+}
+
+// getMethodName отбрасывает имя пакета из полного имени метода вида /пакет.Сервис/Метод
+func getMethodName(fullMethod string) string {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "."); i >= 0 {
+		return fullMethod[i+1:]
+	}
+	return fullMethod
+}
@@ -0,0 +1,98 @@
+package interceptors
+
+import (
+	"context"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/cumulative"
+	"github.com/c0dered273/go-adv-metrics/internal/selfmetrics"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const (
+	// clientIDKey ключ метаданных, в котором клиент может передать свой идентификатор
+	clientIDKey = "x-client-id"
+	// instanceIDKey ключ метаданных с идентификатором процесса клиента, различает источники накопительных счетчиков
+	instanceIDKey = "x-instance-id"
+)
+
+// ClientKey возвращает ключ клиента для ограничения частоты запросов.
+// IP адрес берется из peer, поэтому RealIPUnaryServerInterceptor и RealIPStreamServerInterceptor должны стоять раньше
+func ClientKey(ctx context.Context, cfg *config.ServerConfig) string {
+	return cfg.RateLimitKey(clientAddr(ctx))
+}
+
+// SourceKey возвращает ключ источника метрик для накопительных счетчиков
+func SourceKey(ctx context.Context) string {
+	ip, clientID := clientAddr(ctx)
+	var instanceID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if id := md.Get(instanceIDKey); len(id) > 0 {
+			instanceID = id[0]
+		}
+	}
+	return cumulative.SourceKey(ip, clientID, instanceID)
+}
+
+// clientAddr возвращает IP адрес клиента и идентификатор из метаданных, если он передан
+func clientAddr(ctx context.Context) (string, string) {
+	var ip, clientID string
+	if p, ok := peer.FromContext(ctx); ok {
+		if addr := getIPFromPeer(p); addr != nil {
+			ip = addr.String()
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if id := md.Get(clientIDKey); len(id) > 0 {
+			clientID = id[0]
+		}
+	}
+	return ip, clientID
+}
+
+// ResourceExhausted возвращает ошибку превышения ограничения с подсказкой, через сколько можно повторить вызов
+func ResourceExhausted(msg string, retryAfter time.Duration) error {
+	st, err := status.New(codes.ResourceExhausted, msg).
+		WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, msg)
+	}
+	return st.Err()
+}
+
+// RateLimitUnaryServerInterceptor отклоняет вызовы клиента сверх допустимой частоты
+func RateLimitUnaryServerInterceptor(cfg *config.ServerConfig) func(context.Context, interface{}, *grpc.UnaryServerInfo, grpc.UnaryHandler) (resp interface{}, err error) {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		if err := allowRequest(ctx, cfg); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RateLimitStreamServerInterceptor учитывает открытие потока как один вызов
+func RateLimitStreamServerInterceptor(cfg *config.ServerConfig) func(interface{}, grpc.ServerStream, *grpc.StreamServerInfo, grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := allowRequest(ss.Context(), cfg); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func allowRequest(ctx context.Context, cfg *config.ServerConfig) error {
+	key := ClientKey(ctx, cfg)
+	if ok, retryAfter := cfg.RateLimiter.AllowRequest(key); !ok {
+		cfg.SelfMetrics.Inc(selfmetrics.RateLimitedRequests)
+		cfg.Logger.Warn().Str("client", key).Msg("rate_limit: request rate limit exceeded")
+		return ResourceExhausted("rate_limit: request rate limit exceeded", retryAfter)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"net"
 	"net/http"
 
+	"github.com/grpc-ecosystem/go-grpc-middleware/v2"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -18,21 +19,41 @@ var xRealIP = http.CanonicalHeaderKey("X-Real-IP")
 
 func RealIPUnaryServerInterceptor() func(context.Context, interface{}, *grpc.UnaryServerInfo, grpc.UnaryHandler) (resp interface{}, err error) {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		if md, ok := metadata.FromIncomingContext(ctx); ok {
-			ip := getRealIP(md)
-			if p, ok := peer.FromContext(ctx); ok && ip != "" {
-				p.Addr, err = net.ResolveIPAddr("", ip)
-				if err != nil {
-					return nil, status.Error(codes.InvalidArgument, "real_ip: failed to resolve ip address from metadata")
-				}
-
-				outCtx := peer.NewContext(ctx, p)
-				return handler(outCtx, req)
-			}
+		outCtx, err := realIPContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return handler(outCtx, req)
+	}
+}
+
+func RealIPStreamServerInterceptor() func(interface{}, grpc.ServerStream, *grpc.StreamServerInfo, grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		outCtx, err := realIPContext(ss.Context())
+		if err != nil {
+			return err
 		}
+		wrapped := middleware.WrapServerStream(ss)
+		wrapped.WrappedContext = outCtx
+		return handler(srv, wrapped)
+	}
+}
 
-		return handler(ctx, req)
+// realIPContext подменяет адрес клиента в peer адресом из метаданных, если он передан
+func realIPContext(ctx context.Context) (context.Context, error) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ip := getRealIP(md)
+		if p, ok := peer.FromContext(ctx); ok && ip != "" {
+			addr, err := net.ResolveIPAddr("", ip)
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, "real_ip: failed to resolve ip address from metadata")
+			}
+			p.Addr = addr
+			return peer.NewContext(ctx, p), nil
+		}
 	}
+
+	return ctx, nil
 }
 
 func getRealIP(md metadata.MD) string {
@@ -13,21 +13,42 @@ import (
 
 func TrustedSubnetUnaryServerInterceptor(cfg *config.ServerConfig) func(context.Context, interface{}, *grpc.UnaryServerInfo, grpc.UnaryHandler) (resp interface{}, err error) {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
-		if p, ok := peer.FromContext(ctx); ok {
-			ip := getIPFromPeer(p)
-			if cfg.TrustedSubnet.Contains(ip) {
-				return handler(ctx, req)
-			}
-
-			msg := "trusted_subnet: request ip does not belongs to trusted subnet"
-			cfg.Logger.Error().Msg(msg)
-			return nil, status.Error(codes.PermissionDenied, msg)
+		if err := checkTrustedSubnet(ctx, cfg); err != nil {
+			return nil, err
 		}
+		return handler(ctx, req)
+	}
+}
+
+func TrustedSubnetStreamServerInterceptor(cfg *config.ServerConfig) func(interface{}, grpc.ServerStream, *grpc.StreamServerInfo, grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkTrustedSubnet(ss.Context(), cfg); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
 
-		msg := "trusted_subnet: failed to resolve ip address from context"
+func checkTrustedSubnet(ctx context.Context, cfg *config.ServerConfig) error {
+	trustedSubnet := cfg.GetTrustedSubnet()
+	if trustedSubnet == nil {
+		return nil
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		ip := getIPFromPeer(p)
+		if trustedSubnet.Contains(ip) {
+			return nil
+		}
+
+		msg := "trusted_subnet: request ip does not belongs to trusted subnet"
 		cfg.Logger.Error().Msg(msg)
-		return nil, status.Error(codes.PermissionDenied, msg)
+		return status.Error(codes.PermissionDenied, msg)
 	}
+
+	msg := "trusted_subnet: failed to resolve ip address from context"
+	cfg.Logger.Error().Msg(msg)
+	return status.Error(codes.PermissionDenied, msg)
 }
 
 func getIPFromPeer(p *peer.Peer) net.IP {
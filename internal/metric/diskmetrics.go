@@ -0,0 +1,171 @@
+package metric
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/disk"
+)
+
+// Ограничение по частоте обновления метрик
+const cacheTimeDisk = 1 * time.Second
+
+// DiskFilter фильтры точек монтирования и типов файловых систем для сбора дисковых метрик.
+// Поддерживаются glob шаблоны, например "/mnt/*" или "ext*".
+// Пустой список включений означает, что подходит любое значение
+type DiskFilter struct {
+	MountPointsInclude []string
+	MountPointsExclude []string
+	FSTypesInclude     []string
+	FSTypesExclude     []string
+}
+
+func (f DiskFilter) match(p disk.PartitionStat) bool {
+	return matchFilter(f.MountPointsInclude, f.MountPointsExclude, p.Mountpoint) &&
+		matchFilter(f.FSTypesInclude, f.FSTypesExclude, p.Fstype)
+}
+
+type diskStats struct {
+	mu         *sync.RWMutex
+	partitions []disk.PartitionStat
+	devices    []string
+	usage      map[string]*disk.UsageStat
+	io         map[string]disk.IOCountersStat
+	lastUpdate ConcurrentTime
+}
+
+func (d *diskStats) refresh() {
+	usage := make(map[string]*disk.UsageStat, len(d.partitions))
+	for _, p := range d.partitions {
+		u, err := disk.Usage(p.Mountpoint)
+		if err != nil {
+			continue
+		}
+		usage[p.Mountpoint] = u
+	}
+
+	io := make(map[string]disk.IOCountersStat)
+	if len(d.devices) > 0 {
+		io, _ = disk.IOCounters(d.devices...)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.usage = usage
+	d.io = io
+}
+
+func (d *diskStats) update() {
+	if time.Since(d.lastUpdate.get()) > cacheTimeDisk {
+		d.refresh()
+		d.lastUpdate.set(time.Now())
+	}
+}
+
+func (d *diskStats) getUsage(mountPoint string) disk.UsageStat {
+	d.update()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if u, ok := d.usage[mountPoint]; ok {
+		return *u
+	}
+	return disk.UsageStat{}
+}
+
+func (d *diskStats) getIO(device string) disk.IOCountersStat {
+	d.update()
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.io[device]
+}
+
+// NewDiskStats возвращает обновляемые метрики файловых систем и дисков.
+// Значение метрик кэшируется, метрики обновляются не чаще одного раза в секунду.
+// Набор точек монтирования определяется при создании и ограничивается фильтром.
+// Для каждой точки монтирования:
+// DiskTotal, DiskFree, DiskUsed, DiskUsedPercent - объем файловой системы
+// DiskInodesTotal, DiskInodesFree, DiskInodesUsed - количество inode
+// Для каждого устройства (счетчики):
+// DiskReadBytes, DiskWriteBytes - прочитано и записано байт
+// DiskReadCount, DiskWriteCount - количество операций чтения и записи
+func NewDiskStats(filter DiskFilter) []UpdatableMetric {
+	// Если явно заданы типы файловых систем, учитываем и виртуальные разделы (tmpfs и т.п.)
+	partitions, _ := disk.Partitions(len(filter.FSTypesInclude) > 0)
+
+	stats := &diskStats{
+		mu: new(sync.RWMutex),
+		lastUpdate: ConcurrentTime{
+			time: time.Now(),
+			mu:   new(sync.RWMutex),
+		},
+	}
+
+	seenDevices := make(map[string]bool)
+	for _, p := range partitions {
+		if !filter.match(p) {
+			continue
+		}
+		stats.partitions = append(stats.partitions, p)
+
+		device := filepath.Base(p.Device)
+		if strings.HasPrefix(p.Device, "/dev/") && !seenDevices[device] {
+			seenDevices[device] = true
+			stats.devices = append(stats.devices, device)
+		}
+	}
+	stats.refresh()
+
+	result := make([]UpdatableMetric, 0)
+	for _, p := range stats.partitions {
+		func(mountPoint string) {
+			suffix := "_" + sanitizeName(mountPoint)
+			result = append(result,
+				NewUpdatableGauge("DiskTotal"+suffix, func() float64 {
+					return float64(stats.getUsage(mountPoint).Total)
+				}),
+				NewUpdatableGauge("DiskFree"+suffix, func() float64 {
+					return float64(stats.getUsage(mountPoint).Free)
+				}),
+				NewUpdatableGauge("DiskUsed"+suffix, func() float64 {
+					return float64(stats.getUsage(mountPoint).Used)
+				}),
+				NewUpdatableGauge("DiskUsedPercent"+suffix, func() float64 {
+					return stats.getUsage(mountPoint).UsedPercent
+				}),
+				NewUpdatableGauge("DiskInodesTotal"+suffix, func() float64 {
+					return float64(stats.getUsage(mountPoint).InodesTotal)
+				}),
+				NewUpdatableGauge("DiskInodesFree"+suffix, func() float64 {
+					return float64(stats.getUsage(mountPoint).InodesFree)
+				}),
+				NewUpdatableGauge("DiskInodesUsed"+suffix, func() float64 {
+					return float64(stats.getUsage(mountPoint).InodesUsed)
+				}),
+			)
+		}(p.Mountpoint)
+	}
+
+	for _, d := range stats.devices {
+		func(device string) {
+			suffix := "_" + sanitizeName(device)
+			result = append(result,
+				NewUpdatableCumulativeCounter("DiskReadBytes"+suffix, func() int64 {
+					return int64(stats.getIO(device).ReadBytes)
+				}),
+				NewUpdatableCumulativeCounter("DiskWriteBytes"+suffix, func() int64 {
+					return int64(stats.getIO(device).WriteBytes)
+				}),
+				NewUpdatableCumulativeCounter("DiskReadCount"+suffix, func() int64 {
+					return int64(stats.getIO(device).ReadCount)
+				}),
+				NewUpdatableCumulativeCounter("DiskWriteCount"+suffix, func() int64 {
+					return int64(stats.getIO(device).WriteCount)
+				}),
+			)
+		}(d)
+	}
+
+	return result
+}
@@ -0,0 +1,31 @@
+package metric
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewDiskStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter DiskFilter
+		want   int
+	}{
+		{
+			name:   "should return empty slice when all fs types excluded",
+			filter: DiskFilter{FSTypesExclude: []string{"*"}},
+			want:   0,
+		},
+		{
+			name:   "should return empty slice when no fs type matches",
+			filter: DiskFilter{FSTypesInclude: []string{"unknownfs"}},
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, len(NewDiskStats(tt.filter)), "NewDiskStats(%v)", tt.filter)
+		})
+	}
+}
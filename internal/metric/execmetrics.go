@@ -0,0 +1,233 @@
+package metric
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExecCollector запускает исполняемые файлы из каталога при каждом опросе и собирает метрики из их вывода.
+// Поддерживаются два формата вывода:
+// текстовый - по одной метрике на строку в виде "type name value", например "gauge QueueLen 12";
+// json - объект или массив объектов метрик, как в запросах к серверу.
+// Ошибки и таймауты скриптов не прерывают работу агента, а учитываются в метриках агента:
+// ExecFailures_<script> - количество неудачных запусков, включая ошибки разбора вывода
+// ExecTimeouts_<script> - количество запусков, прерванных по таймауту
+type ExecCollector struct {
+	mu       *sync.Mutex
+	dir      string
+	timeout  time.Duration
+	failures map[string]int64
+	timeouts map[string]int64
+}
+
+// NewExecCollector возвращает сборщик метрик из внешних скриптов в каталоге dir
+func NewExecCollector(dir string, timeout time.Duration) *ExecCollector {
+	return &ExecCollector{
+		mu:       new(sync.Mutex),
+		dir:      dir,
+		timeout:  timeout,
+		failures: make(map[string]int64),
+		timeouts: make(map[string]int64),
+	}
+}
+
+type execResult struct {
+	script  string
+	metrics []UpdatableMetric
+	err     error
+}
+
+func (ec *ExecCollector) Name() string {
+	return "exec"
+}
+
+// Collect запускает все исполняемые файлы каталога параллельно и возвращает полученные метрики
+func (ec *ExecCollector) Collect() []UpdatableMetric {
+	scripts := ec.scripts()
+
+	results := make([]execResult, len(scripts))
+	var wg sync.WaitGroup
+	wg.Add(len(scripts))
+	for i, script := range scripts {
+		go func(i int, script string) {
+			defer wg.Done()
+			metrics, err := ec.run(script)
+			results[i] = execResult{script: script, metrics: metrics, err: err}
+		}(i, script)
+	}
+	wg.Wait()
+
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	result := make([]UpdatableMetric, 0)
+	for _, r := range results {
+		name := sanitizeName(r.script)
+		switch {
+		case errors.Is(r.err, context.DeadlineExceeded):
+			ec.timeouts[name]++
+		case r.err != nil:
+			ec.failures[name]++
+		default:
+			result = append(result, r.metrics...)
+		}
+		ec.touch(name)
+	}
+
+	names := make([]string, 0, len(ec.failures))
+	for name := range ec.failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result = append(result,
+			newConstCumulativeCounter("ExecFailures_"+name, ec.failures[name]),
+			newConstCumulativeCounter("ExecTimeouts_"+name, ec.timeouts[name]),
+		)
+	}
+
+	return result
+}
+
+// touch заводит счетчики ошибок для скрипта, чтобы они отправлялись и при нулевых значениях
+func (ec *ExecCollector) touch(name string) {
+	if _, ok := ec.failures[name]; !ok {
+		ec.failures[name] = 0
+	}
+	if _, ok := ec.timeouts[name]; !ok {
+		ec.timeouts[name] = 0
+	}
+}
+
+func (ec *ExecCollector) scripts() []string {
+	entries, err := os.ReadDir(ec.dir)
+	if err != nil {
+		return nil
+	}
+
+	result := make([]string, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		result = append(result, e.Name())
+	}
+	return result
+}
+
+func (ec *ExecCollector) run(script string) ([]UpdatableMetric, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ec.timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.Command(filepath.Join(ec.dir, script))
+	cmd.Stdout = &out
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+		case <-done:
+		}
+	}()
+	err := cmd.Wait()
+	close(done)
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseExecOutput(out.Bytes())
+}
+
+// ParseExecOutput разбирает вывод скрипта в текстовом или json формате
+func ParseExecOutput(out []byte) ([]UpdatableMetric, error) {
+	trimmed := bytes.TrimSpace(out)
+	if len(trimmed) == 0 {
+		return nil, nil
+	}
+
+	switch trimmed[0] {
+	case '[':
+		var metrics []Metric
+		if err := json.Unmarshal(trimmed, &metrics); err != nil {
+			return nil, err
+		}
+		return toUpdatable(metrics)
+	case '{':
+		var m Metric
+		if err := json.Unmarshal(trimmed, &m); err != nil {
+			return nil, err
+		}
+		return toUpdatable([]Metric{m})
+	default:
+		return parseExecText(trimmed)
+	}
+}
+
+func parseExecText(out []byte) ([]UpdatableMetric, error) {
+	result := make([]UpdatableMetric, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("exec: line %d: expected \"type name value\", got %q", line, text)
+		}
+
+		m, nmErr := NewMetric(fields[1], fields[0], fields[2], "")
+		if nmErr.Error != nil {
+			return nil, fmt.Errorf("exec: line %d: %w", line, nmErr.Error)
+		}
+		result = append(result, fromMetric(m))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func toUpdatable(metrics []Metric) ([]UpdatableMetric, error) {
+	result := make([]UpdatableMetric, 0, len(metrics))
+	for _, m := range metrics {
+		if m.ID == "" || !IsValid(m) {
+			return nil, fmt.Errorf("exec: invalid metric: %v", m.ID)
+		}
+		result = append(result, fromMetric(m))
+	}
+	return result, nil
+}
+
+func fromMetric(m Metric) UpdatableMetric {
+	if m.MType == Counter && m.Cumulative {
+		return newConstCumulativeCounter(m.ID, m.GetCounterValue())
+	}
+	if m.MType == Counter {
+		return newConstCounter(m.ID, m.GetCounterValue())
+	}
+	return newConstGauge(m.ID, m.GetGaugeValue())
+}
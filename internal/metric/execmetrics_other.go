@@ -0,0 +1,12 @@
+//go:build !unix
+
+package metric
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup завершает только сам скрипт, группы процессов на этой платформе не поддерживаются
+func killProcessGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
@@ -0,0 +1,112 @@
+package metric
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExecOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		out     string
+		want    []string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "should parse text format",
+			out:  "# comment\ngauge QueueLen 12.5\n\ncounter Processed 42\n",
+			want: []string{
+				"/gauge/QueueLen/12.5",
+				"/counter/Processed/42",
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "should parse json array",
+			out:  `[{"id": "QueueLen", "type": "gauge", "value": 3}, {"id": "Processed", "type": "counter", "delta": 7}]`,
+			want: []string{
+				"/gauge/QueueLen/3",
+				"/counter/Processed/7",
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "should parse json object",
+			out:     `{"id": "QueueLen", "type": "gauge", "value": 1}`,
+			want:    []string{"/gauge/QueueLen/1"},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "should fail on malformed line",
+			out:     "gauge QueueLen",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on invalid value",
+			out:     "counter Processed 1.5",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on json metric without value",
+			out:     `{"id": "QueueLen", "type": "gauge"}`,
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseExecOutput([]byte(tt.out))
+			if !tt.wantErr(t, err) || err != nil {
+				return
+			}
+
+			var actual []string
+			for _, m := range got {
+				actual = append(actual, m.String())
+			}
+			assert.Equal(t, tt.want, actual)
+		})
+	}
+}
+
+func TestExecCollector_Collect(t *testing.T) {
+	dir := t.TempDir()
+	scripts := map[string]string{
+		"ok.sh":      "#!/bin/sh\necho 'gauge ScriptGauge 1'\n",
+		"fail.sh":    "#!/bin/sh\nexit 1\n",
+		"timeout.sh": "#!/bin/sh\nsleep 5\n",
+		"child.sh":   "#!/bin/sh\nsleep 5 &\nsleep 5\n",
+	}
+	for name, body := range scripts {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0755); err != nil {
+			panic(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("not executable"), 0644); err != nil {
+		panic(err)
+	}
+
+	timeout := 500 * time.Millisecond
+	start := time.Now()
+	got := NewExecCollector(dir, timeout).Collect()
+	assert.Less(t, time.Since(start), timeout+time.Second, "collect should not wait for child processes after timeout")
+
+	var actual []string
+	for _, m := range got {
+		actual = append(actual, m.String())
+	}
+	assert.ElementsMatch(t, []string{
+		"/gauge/ScriptGauge/1",
+		"/counter/ExecFailures_fail_sh/1",
+		"/counter/ExecTimeouts_fail_sh/0",
+		"/counter/ExecFailures_ok_sh/0",
+		"/counter/ExecTimeouts_ok_sh/0",
+		"/counter/ExecFailures_timeout_sh/0",
+		"/counter/ExecTimeouts_timeout_sh/1",
+		"/counter/ExecFailures_child_sh/0",
+		"/counter/ExecTimeouts_child_sh/1",
+	}, actual)
+}
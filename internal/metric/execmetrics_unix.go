@@ -0,0 +1,19 @@
+//go:build unix
+
+package metric
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup запускает скрипт в отдельной группе процессов, чтобы по таймауту завершить и его дочерние процессы
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup завершает скрипт вместе с запущенными им процессами.
+// Иначе дочерние процессы держат открытым вывод скрипта, и ожидание завершения не прерывается
+func killProcessGroup(cmd *exec.Cmd) {
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
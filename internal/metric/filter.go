@@ -0,0 +1,40 @@
+package metric
+
+import (
+	"path"
+	"strings"
+)
+
+// matchFilter проверяет значение по спискам glob шаблонов включения и исключения.
+// Исключение имеет приоритет, пустой список включений пропускает любое значение
+func matchFilter(include []string, exclude []string, value string) bool {
+	if matchAny(exclude, value) {
+		return false
+	}
+	return len(include) == 0 || matchAny(include, value)
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, p := range patterns {
+		if ok, err := path.Match(p, value); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeName приводит путь или имя устройства к виду, пригодному для имени метрики
+func sanitizeName(name string) string {
+	name = strings.Trim(name, "/")
+	if name == "" {
+		return "root"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,64 @@
+package metric
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		value   string
+		want    bool
+	}{
+		{
+			name:  "should match any value when filters are empty",
+			value: "/home",
+			want:  true,
+		},
+		{
+			name:    "should match value by include glob",
+			include: []string{"/mnt/*"},
+			value:   "/mnt/data",
+			want:    true,
+		},
+		{
+			name:    "should not match value outside include list",
+			include: []string{"/mnt/*"},
+			value:   "/home",
+			want:    false,
+		},
+		{
+			name:    "should prefer exclude over include",
+			include: []string{"ext*"},
+			exclude: []string{"ext2"},
+			value:   "ext2",
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchFilter(tt.include, tt.exclude, tt.value))
+		})
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "should name root mount point", value: "/", want: "root"},
+		{name: "should replace separators", value: "/var/lib/docker", want: "var_lib_docker"},
+		{name: "should keep device name", value: "nvme0n1", want: "nvme0n1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, sanitizeName(tt.value))
+		})
+	}
+}
@@ -149,7 +149,7 @@ func NewMemStats() []UpdatableMetric {
 			r := rand.New(seed)
 			return r.Float64() * 1000000
 		}),
-		NewUpdatableCounter("PollCount", func() int64 {
+		NewUpdatableCumulativeCounter("PollCount", func() int64 {
 			atomic.AddInt64(&pollCounter, 1)
 			return pollCounter
 		}),
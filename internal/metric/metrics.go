@@ -95,6 +95,9 @@ type Metric struct {
 	Delta *int64   `json:"delta,omitempty"`
 	Val   *float64 `json:"value,omitempty"`
 	Hash  string   `json:"hash,omitempty"`
+	// Cumulative у счетчика в Delta передается накопленное значение, а не приращение.
+	// Приращение вычисляет сервер по прошлому значению от того же источника
+	Cumulative bool `json:"cumulative,omitempty"`
 }
 
 func (m *Metric) GetName() string {
@@ -203,6 +206,9 @@ func (m *Metric) getHashSrc() []byte {
 	case Gauge:
 		return []byte(fmt.Sprintf("%s:gauge:%f", m.ID, *m.Val))
 	case Counter:
+		if m.Cumulative {
+			return []byte(fmt.Sprintf("%s:counter:%d:cumulative", m.ID, *m.Delta))
+		}
 		return []byte(fmt.Sprintf("%s:counter:%d", m.ID, *m.Delta))
 	}
 	return []byte{}
@@ -248,10 +254,17 @@ func NewCounterMetric(ID string, value int64) Metric {
 	return m
 }
 
+// NewCumulativeCounterMetric создает счетчик, значение которого накопленное с момента запуска источника
+func NewCumulativeCounterMetric(ID string, value int64) Metric {
+	m := NewCounterMetric(ID, value)
+	m.Cumulative = true
+	return m
+}
+
 func IsValid(m Metric) bool {
 	switch m.MType {
 	case Gauge:
-		return m.Val != nil
+		return m.Val != nil && !m.Cumulative
 	case Counter:
 		return m.Delta != nil
 	default:
@@ -292,6 +305,67 @@ func NewUpdatableCounter(ID string, source func() int64) UpdatableMetric {
 	}
 }
 
+// NewUpdatableCumulativeCounter создает счетчик, источник которого отдает накопленное значение,
+// например количество опросов или переданных байт с момента запуска
+func NewUpdatableCumulativeCounter(ID string, source func() int64) UpdatableMetric {
+	return UpdatableMetric{
+		NewCumulativeCounterMetric(ID, source()),
+		nil,
+		source,
+	}
+}
+
+// Collector источник метрик, набор которых может меняться от опроса к опросу.
+// Агент вызывает Collect при каждом опросе и отправляет полученные метрики вместе с остальными
+type Collector interface {
+	// Name имя сборщика, используется в метриках самого агента
+	Name() string
+	Collect() []UpdatableMetric
+}
+
+// SourceCollector сборщик с постоянным набором метрик, которые обновляются при каждом опросе
+type SourceCollector struct {
+	name    string
+	sources []UpdatableMetric
+}
+
+// NewSourceCollector возвращает сборщик для набора метрик, например полученного из NewMemStats
+func NewSourceCollector(name string, sources []UpdatableMetric) *SourceCollector {
+	return &SourceCollector{
+		name:    name,
+		sources: sources,
+	}
+}
+
+func (sc *SourceCollector) Name() string {
+	return sc.name
+}
+
+func (sc *SourceCollector) Collect() []UpdatableMetric {
+	for i := range sc.sources {
+		sc.sources[i].Update()
+	}
+	return sc.sources
+}
+
+func newConstGauge(ID string, value float64) UpdatableMetric {
+	return NewUpdatableGauge(ID, func() float64 {
+		return value
+	})
+}
+
+func newConstCounter(ID string, value int64) UpdatableMetric {
+	return NewUpdatableCounter(ID, func() int64 {
+		return value
+	})
+}
+
+func newConstCumulativeCounter(ID string, value int64) UpdatableMetric {
+	return NewUpdatableCumulativeCounter(ID, func() int64 {
+		return value
+	})
+}
+
 type NewMetricError struct {
 	Error      error
 	TypeError  bool
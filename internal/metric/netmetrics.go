@@ -0,0 +1,182 @@
+package metric
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/net"
+)
+
+// Ограничение по частоте обновления метрик
+const cacheTimeNet = 1 * time.Second
+
+// tcpStates коды состояний TCP соединений из /proc/net/tcp, по которым считаются метрики, и суффиксы имен метрик
+var tcpStates = [...][2]string{
+	{"01", "Established"},
+	{"02", "SynSent"},
+	{"03", "SynRecv"},
+	{"04", "FinWait1"},
+	{"05", "FinWait2"},
+	{"06", "TimeWait"},
+	{"07", "Close"},
+	{"08", "CloseWait"},
+	{"09", "LastAck"},
+	{"0A", "Listen"},
+	{"0B", "Closing"},
+}
+
+// tcpTables таблицы TCP соединений ядра. Чтение таблиц не требует обхода /proc/<pid>/fd всех процессов
+var tcpTables = []string{"/proc/net/tcp", "/proc/net/tcp6"}
+
+// NetFilter фильтры сетевых интерфейсов в виде glob шаблонов, например "eth*".
+// Пустой список включений означает, что подходит любой интерфейс
+type NetFilter struct {
+	InterfacesInclude []string
+	InterfacesExclude []string
+}
+
+type netStats struct {
+	mu         *sync.RWMutex
+	io         map[string]net.IOCountersStat
+	tcp        map[string]int
+	lastUpdate ConcurrentTime
+}
+
+func (n *netStats) refresh() {
+	io := make(map[string]net.IOCountersStat)
+	if counters, err := net.IOCounters(true); err == nil {
+		for _, c := range counters {
+			io[c.Name] = c
+		}
+	}
+
+	tcp := make(map[string]int, len(tcpStates))
+	for _, table := range tcpTables {
+		readTCPTable(table, tcp)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.io = io
+	n.tcp = tcp
+}
+
+// readTCPTable добавляет к счетчикам количество соединений из таблицы, отсутствующая таблица пропускается
+func readTCPTable(path string, counts map[string]int) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	countTCPStates(f, counts)
+}
+
+// countTCPStates считает соединения по кодам состояний в формате /proc/net/tcp, первая строка - заголовок
+func countTCPStates(r io.Reader, counts map[string]int) {
+	scanner := bufio.NewScanner(r)
+	scanner.Scan()
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 3 {
+			counts[strings.ToUpper(fields[3])]++
+		}
+	}
+}
+
+func (n *netStats) update() {
+	if time.Since(n.lastUpdate.get()) > cacheTimeNet {
+		n.refresh()
+		n.lastUpdate.set(time.Now())
+	}
+}
+
+func (n *netStats) getIO(iface string) net.IOCountersStat {
+	n.update()
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.io[iface]
+}
+
+func (n *netStats) getTCP(state string) int {
+	n.update()
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.tcp[state]
+}
+
+// NewNetStats возвращает обновляемые метрики сетевых интерфейсов и TCP соединений.
+// Значение метрик кэшируется, метрики обновляются не чаще одного раза в секунду.
+// Набор интерфейсов определяется при создании и ограничивается фильтром.
+// Для каждого интерфейса (счетчики):
+// NetBytesSent, NetBytesRecv - отправлено и получено байт
+// NetPacketsSent, NetPacketsRecv - отправлено и получено пакетов
+// NetErrIn, NetErrOut - ошибки приема и передачи
+// NetDropIn, NetDropOut - отброшенные пакеты
+// Для каждого состояния TCP соединения - TCPConn<State>, количество соединений в этом состоянии.
+// Состояния читаются из /proc/net/tcp и /proc/net/tcp6, на системах без procfs метрики равны нулю
+func NewNetStats(filter NetFilter) []UpdatableMetric {
+	stats := &netStats{
+		mu: new(sync.RWMutex),
+		lastUpdate: ConcurrentTime{
+			time: time.Now(),
+			mu:   new(sync.RWMutex),
+		},
+	}
+	stats.refresh()
+
+	interfaces := make([]string, 0, len(stats.io))
+	for iface := range stats.io {
+		if matchFilter(filter.InterfacesInclude, filter.InterfacesExclude, iface) {
+			interfaces = append(interfaces, iface)
+		}
+	}
+	sort.Strings(interfaces)
+
+	result := make([]UpdatableMetric, 0)
+	for _, iface := range interfaces {
+		func(iface string) {
+			suffix := "_" + sanitizeName(iface)
+			result = append(result,
+				NewUpdatableCumulativeCounter("NetBytesSent"+suffix, func() int64 {
+					return int64(stats.getIO(iface).BytesSent)
+				}),
+				NewUpdatableCumulativeCounter("NetBytesRecv"+suffix, func() int64 {
+					return int64(stats.getIO(iface).BytesRecv)
+				}),
+				NewUpdatableCumulativeCounter("NetPacketsSent"+suffix, func() int64 {
+					return int64(stats.getIO(iface).PacketsSent)
+				}),
+				NewUpdatableCumulativeCounter("NetPacketsRecv"+suffix, func() int64 {
+					return int64(stats.getIO(iface).PacketsRecv)
+				}),
+				NewUpdatableCumulativeCounter("NetErrIn"+suffix, func() int64 {
+					return int64(stats.getIO(iface).Errin)
+				}),
+				NewUpdatableCumulativeCounter("NetErrOut"+suffix, func() int64 {
+					return int64(stats.getIO(iface).Errout)
+				}),
+				NewUpdatableCumulativeCounter("NetDropIn"+suffix, func() int64 {
+					return int64(stats.getIO(iface).Dropin)
+				}),
+				NewUpdatableCumulativeCounter("NetDropOut"+suffix, func() int64 {
+					return int64(stats.getIO(iface).Dropout)
+				}),
+			)
+		}(iface)
+	}
+
+	for _, s := range tcpStates {
+		func(state string, name string) {
+			result = append(result, NewUpdatableGauge("TCPConn"+name, func() float64 {
+				return float64(stats.getTCP(state))
+			}))
+		}(s[0], s[1])
+	}
+
+	return result
+}
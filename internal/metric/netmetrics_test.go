@@ -0,0 +1,39 @@
+package metric
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNetStats(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter NetFilter
+		want   int
+	}{
+		{
+			name:   "should return only tcp state gauges when all interfaces excluded",
+			filter: NetFilter{InterfacesExclude: []string{"*"}},
+			want:   len(tcpStates),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equalf(t, tt.want, len(NewNetStats(tt.filter)), "NewNetStats(%v)", tt.filter)
+		})
+	}
+}
+
+func TestCountTCPStates(t *testing.T) {
+	table := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:07E8 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 662 1
+   1: 0100007F:8167 0100007F:EC42 06 00000000:00000000 03:00000BEA 00000000     0        0 0 3
+   2: 0100007F:8C75 0100007F:B744 01 00000000:00000000 00:00000000 00000000     0        0 0 3
+   3: 0100007F:8C76 0100007F:B745 01 00000000:00000000 00:00000000 00000000     0        0 0 3
+`
+	counts := map[string]int{"01": 1}
+	countTCPStates(strings.NewReader(table), counts)
+	assert.Equal(t, map[string]int{"01": 3, "06": 1, "0A": 1}, counts)
+}
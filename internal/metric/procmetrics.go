@@ -0,0 +1,322 @@
+package metric
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// defaultMaxProcesses сколько процессов правила получают собственные метрики, если MaxProcesses не задан
+const defaultMaxProcesses = 10
+
+// ProcessRule правило поиска процессов для сбора метрик.
+// Процесс подходит под правило, если совпадает хотя бы с одним из заданных условий:
+// NameRegex - регулярное выражение для имени процесса
+// CmdlineRegex - регулярное выражение для командной строки процесса
+// PidFile - файл, содержащий PID процесса
+// MaxProcesses ограничивает количество процессов с собственными метриками, 0 - значение по умолчанию
+type ProcessRule struct {
+	Name         string `mapstructure:"name"`
+	NameRegex    string `mapstructure:"name_regex"`
+	CmdlineRegex string `mapstructure:"cmdline_regex"`
+	PidFile      string `mapstructure:"pidfile"`
+	MaxProcesses int    `mapstructure:"max_processes"`
+}
+
+type processMatcher struct {
+	name         string
+	nameRe       *regexp.Regexp
+	cmdRe        *regexp.Regexp
+	pidFile      string
+	maxProcesses int
+	// slots номера, под которыми процессы отдают метрики. Процесс сохраняет номер, пока существует,
+	// номер завершившегося процесса переходит к новому, поэтому количество метрик не растет с перезапусками
+	slots map[int32]processSlot
+}
+
+type processSlot struct {
+	createTime int64
+	slot       int
+}
+
+// assignSlots возвращает номера для найденных процессов. Процессы, которым не хватило номера, не получают метрик
+func (m *processMatcher) assignSlots(matched map[int32]trackedProcess) map[int32]int {
+	pids := make([]int32, 0, len(matched))
+	for pid := range matched {
+		pids = append(pids, pid)
+	}
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+
+	slots := make(map[int32]processSlot, len(pids))
+	used := make(map[int]bool, len(pids))
+	for _, pid := range pids {
+		if s, ok := m.slots[pid]; ok && s.createTime == matched[pid].createTime {
+			slots[pid] = s
+			used[s.slot] = true
+		}
+	}
+	free := 0
+	for _, pid := range pids {
+		if _, ok := slots[pid]; ok {
+			continue
+		}
+		for used[free] {
+			free++
+		}
+		if free >= m.maxProcesses {
+			break
+		}
+		slots[pid] = processSlot{createTime: matched[pid].createTime, slot: free}
+		used[free] = true
+	}
+	m.slots = slots
+
+	result := make(map[int32]int, len(slots))
+	for pid, s := range slots {
+		result[pid] = s.slot
+	}
+	return result
+}
+
+func (m *processMatcher) matchByName(p *process.Process) bool {
+	if m.nameRe != nil {
+		if name, err := p.Name(); err == nil && m.nameRe.MatchString(name) {
+			return true
+		}
+	}
+	if m.cmdRe != nil {
+		if cmd, err := p.Cmdline(); err == nil && m.cmdRe.MatchString(cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *processMatcher) pidFromFile() (int32, bool) {
+	if m.pidFile == "" {
+		return 0, false
+	}
+	b, err := os.ReadFile(m.pidFile)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int32(pid), true
+}
+
+type trackedProcess struct {
+	proc       *process.Process
+	createTime int64
+}
+
+// scannedProcess результат проверки процесса правилами по имени и командной строке
+type scannedProcess struct {
+	createTime int64
+	matched    []bool
+}
+
+// ProcessCollector собирает метрики процессов, найденных по правилам.
+// Процессы определяются заново при каждом опросе, поэтому перезапущенные процессы
+// подхватываются автоматически, а завершившиеся перестают учитываться.
+// Имя и командная строка проверяются только у новых PID, для известных PID используется результат прошлых опросов
+type ProcessCollector struct {
+	mu           *sync.Mutex
+	matchers     []processMatcher
+	hasNameRules bool
+	tracked      map[int32]trackedProcess
+	scanned      map[int32]scannedProcess
+}
+
+// NewProcessCollector возвращает сборщик метрик процессов по заданным правилам
+func NewProcessCollector(rules []ProcessRule) (*ProcessCollector, error) {
+	matchers := make([]processMatcher, 0, len(rules))
+	hasNameRules := false
+	for _, r := range rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("process collector: rule name is required")
+		}
+		if r.MaxProcesses < 0 {
+			return nil, fmt.Errorf("process collector: rule %s: max_processes must not be negative", r.Name)
+		}
+		m := processMatcher{
+			name:         sanitizeName(r.Name),
+			pidFile:      r.PidFile,
+			maxProcesses: r.MaxProcesses,
+			slots:        make(map[int32]processSlot),
+		}
+		if m.maxProcesses == 0 {
+			m.maxProcesses = defaultMaxProcesses
+		}
+		var err error
+		if r.NameRegex != "" {
+			if m.nameRe, err = regexp.Compile(r.NameRegex); err != nil {
+				return nil, fmt.Errorf("process collector: rule %s: %w", r.Name, err)
+			}
+		}
+		if r.CmdlineRegex != "" {
+			if m.cmdRe, err = regexp.Compile(r.CmdlineRegex); err != nil {
+				return nil, fmt.Errorf("process collector: rule %s: %w", r.Name, err)
+			}
+		}
+		hasNameRules = hasNameRules || m.nameRe != nil || m.cmdRe != nil
+		matchers = append(matchers, m)
+	}
+
+	return &ProcessCollector{
+		mu:           new(sync.Mutex),
+		matchers:     matchers,
+		hasNameRules: hasNameRules,
+		tracked:      make(map[int32]trackedProcess),
+		scanned:      make(map[int32]scannedProcess),
+	}, nil
+}
+
+// getProcess возвращает отслеживаемый процесс по PID.
+// Объект процесса переиспользуется между опросами, это нужно для расчета загрузки CPU.
+// Если PID занят другим процессом, отслеживание начинается заново
+func (pc *ProcessCollector) getProcess(pid int32, seen map[int32]trackedProcess) (trackedProcess, bool) {
+	if tp, ok := seen[pid]; ok {
+		return tp, true
+	}
+
+	p, err := process.NewProcess(pid)
+	if err != nil {
+		return trackedProcess{}, false
+	}
+	createTime, err := p.CreateTime()
+	if err != nil {
+		return trackedProcess{}, false
+	}
+
+	if tp, ok := pc.tracked[pid]; ok && tp.createTime == createTime {
+		p = tp.proc
+	}
+	tp := trackedProcess{proc: p, createTime: createTime}
+	seen[pid] = tp
+	return tp, true
+}
+
+// scan проверяет правилами по имени и командной строке новые PID.
+// Для PID, известных с прошлого опроса, используется сохраненный результат
+func (pc *ProcessCollector) scan() {
+	if !pc.hasNameRules {
+		return
+	}
+	pids, err := process.Pids()
+	if err != nil {
+		return
+	}
+
+	scanned := make(map[int32]scannedProcess, len(pids))
+	for _, pid := range pids {
+		if sp, ok := pc.scanned[pid]; ok {
+			scanned[pid] = sp
+			continue
+		}
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			continue
+		}
+		createTime, err := p.CreateTime()
+		if err != nil {
+			continue
+		}
+		sp := scannedProcess{createTime: createTime, matched: make([]bool, len(pc.matchers))}
+		for i := range pc.matchers {
+			sp.matched[i] = pc.matchers[i].matchByName(p)
+		}
+		scanned[pid] = sp
+	}
+	pc.scanned = scanned
+}
+
+func (pc *ProcessCollector) Name() string {
+	return "process"
+}
+
+// Collect находит процессы по правилам и возвращает их метрики. Для правила, под которое попал хотя бы один процесс:
+// ProcCount_<правило> - количество найденных процессов
+// и для каждого из первых MaxProcesses процессов под номером <n>:
+// ProcPID_<правило>_<n> - PID процесса
+// ProcCPUPercent_<правило>_<n> - загрузка CPU в процентах с момента предыдущего опроса
+// ProcRSS_<правило>_<n> - размер резидентной памяти
+// ProcOpenFDs_<правило>_<n> - количество открытых файловых дескрипторов
+// ProcThreads_<правило>_<n> - количество потоков
+// Метрики завершившихся процессов и правил без процессов не отправляются
+func (pc *ProcessCollector) Collect() []UpdatableMetric {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	pc.scan()
+
+	seen := make(map[int32]trackedProcess)
+	result := make([]UpdatableMetric, 0)
+	for i := range pc.matchers {
+		m := &pc.matchers[i]
+
+		matched := make(map[int32]trackedProcess)
+		if pid, ok := m.pidFromFile(); ok {
+			if tp, ok := pc.getProcess(pid, seen); ok {
+				matched[pid] = tp
+			}
+		}
+		for pid, sp := range pc.scanned {
+			if !sp.matched[i] {
+				continue
+			}
+			tp, ok := pc.getProcess(pid, seen)
+			if !ok || tp.createTime != sp.createTime {
+				// PID занят другим процессом, он будет проверен заново при следующем опросе
+				delete(pc.scanned, pid)
+				continue
+			}
+			matched[pid] = tp
+		}
+
+		slots := m.assignSlots(matched)
+		if len(matched) == 0 {
+			continue
+		}
+		result = append(result, newConstGauge("ProcCount_"+m.name, float64(len(matched))))
+
+		pids := make([]int32, 0, len(slots))
+		for pid := range slots {
+			pids = append(pids, pid)
+		}
+		sort.Slice(pids, func(i, j int) bool { return slots[pids[i]] < slots[pids[j]] })
+		for _, pid := range pids {
+			suffix := fmt.Sprintf("_%s_%d", m.name, slots[pid])
+			result = append(result, newConstGauge("ProcPID"+suffix, float64(pid)))
+			result = append(result, collectProcess(matched[pid].proc, suffix)...)
+		}
+	}
+
+	pc.tracked = seen
+	return result
+}
+
+func collectProcess(p *process.Process, suffix string) []UpdatableMetric {
+	result := make([]UpdatableMetric, 0, 4)
+	if cpuPercent, err := p.Percent(0); err == nil {
+		result = append(result, newConstGauge("ProcCPUPercent"+suffix, cpuPercent))
+	}
+	if memInfo, err := p.MemoryInfo(); err == nil {
+		result = append(result, newConstGauge("ProcRSS"+suffix, float64(memInfo.RSS)))
+	}
+	if fds, err := p.NumFDs(); err == nil {
+		result = append(result, newConstGauge("ProcOpenFDs"+suffix, float64(fds)))
+	}
+	if threads, err := p.NumThreads(); err == nil {
+		result = append(result, newConstGauge("ProcThreads"+suffix, float64(threads)))
+	}
+	return result
+}
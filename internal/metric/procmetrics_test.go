@@ -0,0 +1,126 @@
+package metric
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessCollector_Collect(t *testing.T) {
+	dir := t.TempDir()
+	selfPidFile := filepath.Join(dir, "self.pid")
+	gonePidFile := filepath.Join(dir, "gone.pid")
+	require.NoError(t, os.WriteFile(selfPidFile, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644))
+	require.NoError(t, os.WriteFile(gonePidFile, []byte("2147483646"), 0644))
+
+	self, err := os.Executable()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		rule ProcessRule
+		want []string
+	}{
+		{
+			name: "should collect metrics of process from pidfile",
+			rule: ProcessRule{Name: "self", PidFile: selfPidFile},
+			want: []string{"ProcCount_self", "ProcPID_self_0", "ProcCPUPercent_self_0", "ProcRSS_self_0", "ProcOpenFDs_self_0", "ProcThreads_self_0"},
+		},
+		{
+			name: "should collect metrics of processes matched by name",
+			rule: ProcessRule{Name: "self", NameRegex: "^" + regexp.QuoteMeta(filepath.Base(self)) + "$"},
+			want: []string{"ProcCount_self", "ProcPID_self_0", "ProcCPUPercent_self_0", "ProcRSS_self_0", "ProcOpenFDs_self_0", "ProcThreads_self_0"},
+		},
+		{
+			name: "should not report metrics when process is gone",
+			rule: ProcessRule{Name: "gone", PidFile: gonePidFile},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc, err := NewProcessCollector([]ProcessRule{tt.rule})
+			require.NoError(t, err)
+
+			// Второй опрос использует результаты проверки PID из первого
+			for i := 0; i < 2; i++ {
+				metrics := pc.Collect()
+				var got []string
+				for _, m := range metrics {
+					got = append(got, m.ID)
+				}
+				assert.Equal(t, tt.want, got)
+				if len(metrics) > 1 {
+					assert.Equal(t, "/gauge/ProcCount_self/1", metrics[0].String())
+					assert.Equal(t, float64(os.Getpid()), metrics[1].GetGaugeValue())
+				}
+			}
+		})
+	}
+}
+
+func TestProcessMatcher_AssignSlots(t *testing.T) {
+	m := &processMatcher{maxProcesses: 2, slots: make(map[int32]processSlot)}
+	procs := func(createTimes map[int32]int64) map[int32]trackedProcess {
+		result := make(map[int32]trackedProcess, len(createTimes))
+		for pid, ct := range createTimes {
+			result[pid] = trackedProcess{createTime: ct}
+		}
+		return result
+	}
+
+	steps := []struct {
+		name    string
+		matched map[int32]int64
+		want    map[int32]int
+	}{
+		{
+			name:    "should limit processes with own metrics",
+			matched: map[int32]int64{10: 1, 11: 1, 12: 1},
+			want:    map[int32]int{10: 0, 11: 1},
+		},
+		{
+			name:    "should keep slot of running process and reuse slot of exited one",
+			matched: map[int32]int64{11: 1, 12: 1, 13: 1},
+			want:    map[int32]int{11: 1, 12: 0},
+		},
+		{
+			name:    "should reassign slot when pid is reused by another process",
+			matched: map[int32]int64{11: 2, 13: 1},
+			want:    map[int32]int{11: 0, 13: 1},
+		},
+	}
+	for _, s := range steps {
+		assert.Equal(t, s.want, m.assignSlots(procs(s.matched)), s.name)
+	}
+}
+
+func TestNewProcessCollectorErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule ProcessRule
+	}{
+		{
+			name: "should fail when rule name is empty",
+			rule: ProcessRule{NameRegex: "nginx"},
+		},
+		{
+			name: "should fail when regex is invalid",
+			rule: ProcessRule{Name: "broken", NameRegex: "("},
+		},
+		{
+			name: "should fail when max processes is negative",
+			rule: ProcessRule{Name: "nginx", NameRegex: "nginx", MaxProcesses: -1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewProcessCollector([]ProcessRule{tt.rule})
+			assert.Error(t, err)
+		})
+	}
+}
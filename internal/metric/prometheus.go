@@ -0,0 +1,291 @@
+package metric
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrometheusCollector опрашивает список целей, отдающих метрики в текстовом формате Prometheus.
+// Метрики типа counter превращаются в счетчики, gauge и untyped - в gauge, остальные типы пропускаются.
+// Счетчики сервера целочисленные, поэтому counter с дробным значением, например process_cpu_seconds_total,
+// отдается как gauge с тем же значением. Сборщик запоминает такие метрики цели и дальше отдает их как gauge,
+// даже если очередное значение целое, чтобы тип метрики не менялся между опросами.
+// Метки метрики добавляются к имени: http_requests_total{code="200"} -> http_requests_total_code_200.
+// Для каждой цели дополнительно отдаются метрики агента:
+// PromScrapeUp_<target> - 1, если последний опрос успешен, иначе 0
+// PromScrapeFailures_<target> - количество неудачных опросов
+type PrometheusCollector struct {
+	mu       *sync.Mutex
+	targets  []string
+	client   *http.Client
+	failures map[string]int64
+	// fractional метрики типа counter каждой цели, которые уже отдавались как gauge
+	fractional map[string]map[string]struct{}
+}
+
+// NewPrometheusCollector возвращает сборщик метрик с указанных адресов
+func NewPrometheusCollector(targets []string, timeout time.Duration) *PrometheusCollector {
+	failures := make(map[string]int64, len(targets))
+	fractional := make(map[string]map[string]struct{}, len(targets))
+	for _, t := range targets {
+		failures[t] = 0
+		fractional[t] = make(map[string]struct{})
+	}
+
+	return &PrometheusCollector{
+		mu:         new(sync.Mutex),
+		targets:    targets,
+		client:     &http.Client{Timeout: timeout},
+		failures:   failures,
+		fractional: fractional,
+	}
+}
+
+func (pc *PrometheusCollector) Name() string {
+	return "prometheus"
+}
+
+// Collect опрашивает все цели параллельно и возвращает полученные метрики
+func (pc *PrometheusCollector) Collect() []UpdatableMetric {
+	results := make([][]UpdatableMetric, len(pc.targets))
+	errs := make([]error, len(pc.targets))
+
+	var wg sync.WaitGroup
+	wg.Add(len(pc.targets))
+	for i, target := range pc.targets {
+		go func(i int, target string) {
+			defer wg.Done()
+			results[i], errs[i] = pc.scrape(target)
+		}(i, target)
+	}
+	wg.Wait()
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	result := make([]UpdatableMetric, 0)
+	for i, target := range pc.targets {
+		up := 1.0
+		if errs[i] != nil {
+			up = 0
+			pc.failures[target]++
+		} else {
+			result = append(result, results[i]...)
+		}
+
+		name := targetName(target)
+		result = append(result,
+			newConstGauge("PromScrapeUp_"+name, up),
+			newConstCumulativeCounter("PromScrapeFailures_"+name, pc.failures[target]),
+		)
+	}
+	return result
+}
+
+func (pc *PrometheusCollector) scrape(target string) ([]UpdatableMetric, error) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+
+	resp, err := pc.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prometheus: unexpected status code %d from %s", resp.StatusCode, target)
+	}
+
+	// Каждая цель опрашивается в своей горутине, поэтому набор дробных счетчиков цели не требует блокировки
+	return parsePrometheusText(resp.Body, pc.fractional[target])
+}
+
+func targetName(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return sanitizeName(target)
+	}
+	return sanitizeName(u.Host + u.Path)
+}
+
+// ParsePrometheusText разбирает метрики в текстовом формате Prometheus.
+// Значения counter с дробной частью отдаются как gauge, дробная часть не отбрасывается
+func ParsePrometheusText(r io.Reader) ([]UpdatableMetric, error) {
+	return parsePrometheusText(r, make(map[string]struct{}))
+}
+
+// parsePrometheusText разбирает метрики и добавляет в fractional счетчики с дробным значением.
+// Счетчики из fractional отдаются как gauge
+func parsePrometheusText(r io.Reader, fractional map[string]struct{}) ([]UpdatableMetric, error) {
+	types := make(map[string]string)
+	result := make([]UpdatableMetric, 0)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "#") {
+			fields := strings.Fields(text)
+			if len(fields) >= 4 && fields[1] == "TYPE" {
+				types[fields[2]] = fields[3]
+			}
+			continue
+		}
+
+		name, labels, value, err := parsePrometheusSample(text)
+		if err != nil {
+			return nil, fmt.Errorf("prometheus: line %d: %w", line, err)
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+
+		ID := prometheusID(name, labels)
+		switch familyType(types, name) {
+		case "counter":
+			if value != math.Trunc(value) {
+				fractional[ID] = struct{}{}
+			}
+			if _, ok := fractional[ID]; ok {
+				result = append(result, newConstGauge(ID, value))
+				continue
+			}
+			result = append(result, newConstCumulativeCounter(ID, int64(value)))
+		case "gauge", "untyped", "":
+			result = append(result, newConstGauge(ID, value))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// parsePrometheusSample разбирает строку вида name{label="value",...} value [timestamp]
+func parsePrometheusSample(text string) (string, map[string]string, float64, error) {
+	labels := make(map[string]string)
+
+	nameEnd := strings.IndexAny(text, "{ \t")
+	if nameEnd <= 0 {
+		return "", nil, 0, fmt.Errorf("invalid sample %q", text)
+	}
+	name := text[:nameEnd]
+	rest := text[nameEnd:]
+
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		rest, err = parsePrometheusLabels(rest[1:], labels)
+		if err != nil {
+			return "", nil, 0, err
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 || len(fields) > 2 {
+		return "", nil, 0, fmt.Errorf("invalid sample value %q", text)
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	return name, labels, value, nil
+}
+
+// parsePrometheusLabels разбирает метки до закрывающей скобки и возвращает остаток строки
+func parsePrometheusLabels(text string, labels map[string]string) (string, error) {
+	for {
+		text = strings.TrimLeft(text, " \t,")
+		if strings.HasPrefix(text, "}") {
+			return text[1:], nil
+		}
+
+		eq := strings.Index(text, "=")
+		if eq <= 0 || len(text) < eq+2 || text[eq+1] != '"' {
+			return "", fmt.Errorf("invalid labels %q", text)
+		}
+		key := strings.TrimSpace(text[:eq])
+		text = text[eq+2:]
+
+		var value strings.Builder
+		closed := false
+		for i := 0; i < len(text); i++ {
+			c := text[i]
+			if c == '\\' && i+1 < len(text) {
+				i++
+				switch text[i] {
+				case 'n':
+					value.WriteByte('\n')
+				default:
+					value.WriteByte(text[i])
+				}
+				continue
+			}
+			if c == '"' {
+				text = text[i+1:]
+				closed = true
+				break
+			}
+			value.WriteByte(c)
+		}
+		if !closed {
+			return "", fmt.Errorf("unterminated label value for %q", key)
+		}
+		labels[key] = value.String()
+	}
+}
+
+// familyType возвращает тип семейства метрик, к которому относится образец.
+// Образцы гистограмм и сводок (name_bucket, name_sum, name_count) относятся к семейству name
+func familyType(types map[string]string, name string) string {
+	if t, ok := types[name]; ok {
+		return t
+	}
+	for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+		if t, ok := types[strings.TrimSuffix(name, suffix)]; ok && strings.HasSuffix(name, suffix) {
+			return t
+		}
+	}
+	return ""
+}
+
+func prometheusID(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		if labels[k] == "" {
+			continue
+		}
+		b.WriteString("_")
+		b.WriteString(k)
+		b.WriteString("_")
+		b.WriteString(sanitizeName(labels[k]))
+	}
+	return b.String()
+}
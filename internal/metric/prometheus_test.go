@@ -0,0 +1,126 @@
+package metric
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const promExposition = `# HELP http_requests_total Total requests.
+# TYPE http_requests_total counter
+http_requests_total{method="get",code="200"} 1027 1395066363000
+http_requests_total{method="post",code="400"} 3
+# TYPE queue_length gauge
+queue_length 12.5
+# TYPE request_duration_seconds histogram
+request_duration_seconds_bucket{le="0.1"} 5
+request_duration_seconds_sum 0.42
+request_duration_seconds_count 5
+# TYPE rpc_latency summary
+rpc_latency{quantile="0.5"} 0.01
+untyped_value{path="/var/lib"} 7
+`
+
+func TestParsePrometheusText(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []string
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "should parse counters and gauges and skip other types",
+			text: promExposition,
+			want: []string{
+				"/counter/http_requests_total_code_200_method_get/1027",
+				"/counter/http_requests_total_code_400_method_post/3",
+				"/gauge/queue_length/12.5",
+				"/gauge/untyped_value_path_var_lib/7",
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "should report fractional counters as gauges",
+			text: "# TYPE process_cpu_seconds_total counter\nprocess_cpu_seconds_total 12.6\nprocess_cpu_seconds_total{cpu=\"1\"} 12\n",
+			want: []string{
+				"/gauge/process_cpu_seconds_total/12.6",
+				"/counter/process_cpu_seconds_total_cpu_1/12",
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "should fail on unterminated label",
+			text:    `broken{label="value 1`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on invalid value",
+			text:    `broken{label="value"} abc`,
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePrometheusText(strings.NewReader(tt.text))
+			if !tt.wantErr(t, err) || err != nil {
+				return
+			}
+
+			var actual []string
+			for _, m := range got {
+				actual = append(actual, m.String())
+			}
+			assert.Equal(t, tt.want, actual)
+		})
+	}
+}
+
+func TestPrometheusCollector_Collect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("# TYPE queue_length gauge\nqueue_length 3\n"))
+	}))
+	defer srv.Close()
+
+	okTarget := srv.URL + "/metrics"
+	badTarget := srv.URL + "/missing"
+	collector := NewPrometheusCollector([]string{okTarget, badTarget}, time.Second)
+
+	var actual []string
+	for _, m := range collector.Collect() {
+		actual = append(actual, m.String())
+	}
+
+	okName := targetName(okTarget)
+	badName := targetName(badTarget)
+	assert.Equal(t, []string{
+		"/gauge/queue_length/3",
+		"/gauge/PromScrapeUp_" + okName + "/1",
+		"/counter/PromScrapeFailures_" + okName + "/0",
+		"/gauge/PromScrapeUp_" + badName + "/0",
+		"/counter/PromScrapeFailures_" + badName + "/1",
+	}, actual)
+}
+
+func TestPrometheusCollector_CollectFractionalCounter(t *testing.T) {
+	values := []string{"12.6", "13", "14.1"}
+	scrape := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("# TYPE process_cpu_seconds_total counter\nprocess_cpu_seconds_total " + values[scrape] + "\n"))
+		scrape++
+	}))
+	defer srv.Close()
+
+	collector := NewPrometheusCollector([]string{srv.URL}, time.Second)
+	for _, want := range []string{"/gauge/process_cpu_seconds_total/12.6", "/gauge/process_cpu_seconds_total/13", "/gauge/process_cpu_seconds_total/14.1"} {
+		metrics := collector.Collect()
+		assert.Equal(t, want, metrics[0].String(), "counter reported as gauge once should stay gauge")
+	}
+}
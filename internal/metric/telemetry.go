@@ -0,0 +1,113 @@
+package metric
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Telemetry собирает метрики о работе самого агента: результаты отправки по адресатам, размеры корзин,
+// глубину очереди неотправленных метрик, длительность опроса сборщиков и количество потерянных метрик.
+// Telemetry сама является сборщиком, поэтому эти метрики отправляются на сервер вместе с остальными.
+// Методы безопасно вызывать у nil, тогда телеметрия не собирается
+type Telemetry struct {
+	mu              *sync.Mutex
+	sendSuccess     map[string]int64
+	sendFailures    map[string]int64
+	sentMetrics     map[string]int64
+	batchSize       map[string]float64
+	collectDuration map[string]float64
+	dropped         int64
+	queueDepth      float64
+}
+
+// NewTelemetry возвращает пустую телеметрию агента
+func NewTelemetry() *Telemetry {
+	return &Telemetry{
+		mu:              new(sync.Mutex),
+		sendSuccess:     make(map[string]int64),
+		sendFailures:    make(map[string]int64),
+		sentMetrics:     make(map[string]int64),
+		batchSize:       make(map[string]float64),
+		collectDuration: make(map[string]float64),
+	}
+}
+
+// ObserveSend учитывает отправку корзины метрик адресату. Метрики из неотправленной корзины считаются потерянными
+func (t *Telemetry) ObserveSend(destination string, batchSize int, err error) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	destination = sanitizeName(destination)
+	t.batchSize[destination] = float64(batchSize)
+	if err != nil {
+		t.sendFailures[destination]++
+		t.dropped += int64(batchSize)
+		return
+	}
+	t.sendSuccess[destination]++
+	t.sentMetrics[destination] += int64(batchSize)
+}
+
+// ObserveCollect учитывает длительность опроса сборщика
+func (t *Telemetry) ObserveCollect(collector string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.collectDuration[sanitizeName(collector)] = d.Seconds()
+}
+
+// SetQueueDepth сохраняет количество метрик, ожидающих отправки
+func (t *Telemetry) SetQueueDepth(n int) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.queueDepth = float64(n)
+}
+
+func (t *Telemetry) Name() string {
+	return "agent"
+}
+
+func (t *Telemetry) Collect() []UpdatableMetric {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := []UpdatableMetric{
+		newConstGauge("AgentQueueDepth", t.queueDepth),
+		newConstCumulativeCounter("AgentDroppedMetrics", t.dropped),
+	}
+	for _, dest := range sortedTelemetryKeys(t.batchSize) {
+		result = append(result,
+			newConstCumulativeCounter("AgentSendSuccess_"+dest, t.sendSuccess[dest]),
+			newConstCumulativeCounter("AgentSendFailures_"+dest, t.sendFailures[dest]),
+			newConstCumulativeCounter("AgentSentMetrics_"+dest, t.sentMetrics[dest]),
+			newConstGauge("AgentBatchSize_"+dest, t.batchSize[dest]),
+		)
+	}
+	for _, c := range sortedTelemetryKeys(t.collectDuration) {
+		result = append(result, newConstGauge("AgentCollectDuration_"+c, t.collectDuration[c]))
+	}
+	return result
+}
+
+func sortedTelemetryKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,36 @@
+package metric
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTelemetry_Collect(t *testing.T) {
+	telemetry := NewTelemetry()
+	telemetry.ObserveSend("http_localhost:8080", 3, nil)
+	telemetry.ObserveSend("http_localhost:8080", 2, errors.New("connection refused"))
+	telemetry.ObserveSend("http_localhost:8080", 3, nil)
+	telemetry.ObserveCollect("mem", 1500*time.Millisecond)
+	telemetry.SetQueueDepth(2)
+
+	var actual []string
+	for _, m := range telemetry.Collect() {
+		actual = append(actual, m.String())
+	}
+	assert.Equal(t, []string{
+		"/gauge/AgentQueueDepth/2",
+		"/counter/AgentDroppedMetrics/2",
+		"/counter/AgentSendSuccess_http_localhost_8080/2",
+		"/counter/AgentSendFailures_http_localhost_8080/1",
+		"/counter/AgentSentMetrics_http_localhost_8080/6",
+		"/gauge/AgentBatchSize_http_localhost_8080/3",
+		"/gauge/AgentCollectDuration_mem/1.5",
+	}, actual)
+
+	var disabled *Telemetry
+	disabled.ObserveSend("http_localhost:8080", 3, nil)
+	assert.Empty(t, disabled.Collect())
+}
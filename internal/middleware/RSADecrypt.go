@@ -7,13 +7,17 @@ import (
 	"crypto/sha256"
 	"io"
 	"net/http"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/selfmetrics"
 )
 
-// RSADecrypt это middleware которое, если передан приватный ключ, пытается расшифровать тело запроса алгоритмом RSA
-func RSADecrypt(key *rsa.PrivateKey) func(next http.Handler) http.Handler {
+// RSADecrypt это middleware которое, если в конфигурации задан приватный ключ, пытается расшифровать тело запроса алгоритмом RSA.
+// Ключ берется из конфигурации при каждом запросе, поэтому его замена не требует перезапуска сервера
+func RSADecrypt(cfg *config.ServerConfig) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			if key != nil {
+			if key := cfg.GetPrivateKey(); key != nil {
 				encryptedBody, err := io.ReadAll(r.Body)
 				if err != nil {
 					return
@@ -25,6 +29,7 @@ func RSADecrypt(key *rsa.PrivateKey) func(next http.Handler) http.Handler {
 				if len(encryptedBody) != 0 {
 					plainText, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, encryptedBody, nil)
 					if err != nil {
+						cfg.SelfMetrics.Inc(selfmetrics.DecryptFailures)
 						return
 					}
 
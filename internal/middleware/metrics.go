@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Metrics это middleware которое учитывает запросы к серверу в метриках сервера: счетчик
+// ServerHTTPRequests_<метод>_<маршрут>_<статус> и гистограмму длительности ServerHTTPLatency_<метод>_<маршрут>.
+// В имени используется шаблон маршрута, а не путь запроса, чтобы количество метрик не зависело от имен метрик в запросах
+func Metrics(cfg *config.ServerConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if cfg.SelfMetrics == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			route := "unmatched"
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			code := ww.Status()
+			if code == 0 {
+				code = http.StatusOK
+			}
+
+			name := r.Method + "_" + route
+			cfg.SelfMetrics.Inc("ServerHTTPRequests_" + name + "_" + strconv.Itoa(code))
+			cfg.SelfMetrics.Observe("ServerHTTPLatency_"+name, time.Since(start))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/cumulative"
+	"github.com/c0dered273/go-adv-metrics/internal/selfmetrics"
+)
+
+const (
+	// ClientIDHeader заголовок, в котором клиент может передать свой идентификатор
+	ClientIDHeader = "X-Client-ID"
+	// InstanceIDHeader заголовок с идентификатором процесса клиента, различает источники накопительных счетчиков
+	InstanceIDHeader = "X-Instance-ID"
+)
+
+// ClientKey возвращает ключ клиента для ограничения частоты запросов.
+// IP адрес берется из RemoteAddr, поэтому middleware RealIP должно стоять раньше
+func ClientKey(cfg *config.ServerConfig, r *http.Request) string {
+	return cfg.RateLimitKey(remoteIP(r), r.Header.Get(ClientIDHeader))
+}
+
+// SourceKey возвращает ключ источника метрик для накопительных счетчиков
+func SourceKey(r *http.Request) string {
+	return cumulative.SourceKey(remoteIP(r), r.Header.Get(ClientIDHeader), r.Header.Get(InstanceIDHeader))
+}
+
+func remoteIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return ip
+}
+
+// TooManyRequests отвечает клиенту, превысившему ограничение, и сообщает, через сколько секунд можно повторить запрос
+func TooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(w, "Too many requests", http.StatusTooManyRequests)
+}
+
+// RateLimit это middleware которое, если заданы ограничения, отклоняет запросы клиента сверх допустимой частоты
+func RateLimit(cfg *config.ServerConfig) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if ok, retryAfter := cfg.RateLimiter.AllowRequest(ClientKey(cfg, r)); !ok {
+				cfg.SelfMetrics.Inc(selfmetrics.RateLimitedRequests)
+				cfg.Logger.Warn().Str("client", ClientKey(cfg, r)).Msg("rate_limit_middleware: request rate limit exceeded")
+				TooManyRequests(w, retryAfter)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -11,7 +11,7 @@ import (
 func TrustedSubnet(cfg *config.ServerConfig) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			if cfg.TrustedSubnet != nil {
+			if trustedSubnet := cfg.GetTrustedSubnet(); trustedSubnet != nil {
 				realIP := net.ParseIP(r.RemoteAddr)
 				if realIP == nil {
 					cfg.Logger.Error().Msg("trusted_subnet_middleware: failed to parse real ip")
@@ -19,7 +19,7 @@ func TrustedSubnet(cfg *config.ServerConfig) func(next http.Handler) http.Handle
 					return
 				}
 
-				if !cfg.TrustedSubnet.Contains(realIP) {
+				if !trustedSubnet.Contains(realIP) {
 					cfg.Logger.Error().Msg("trusted_subnet_middleware: request ip does not belongs to trusted subnet")
 					http.Error(w, "Forbidden", http.StatusForbidden)
 					return
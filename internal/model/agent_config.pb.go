@@ -0,0 +1,443 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.30.0
+// 	protoc        v3.21.12
+// source: agent_config.proto
+
+package model
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AgentConfigRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Group    string `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Hostname string `protobuf:"bytes,2,opt,name=hostname,proto3" json:"hostname,omitempty"`
+}
+
+func (x *AgentConfigRequest) Reset() {
+	*x = AgentConfigRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_agent_config_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentConfigRequest) ProtoMessage() {}
+
+func (x *AgentConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_agent_config_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentConfigRequest.ProtoReflect.Descriptor instead.
+func (*AgentConfigRequest) Descriptor() ([]byte, []int) {
+	return file_agent_config_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AgentConfigRequest) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *AgentConfigRequest) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+type RelabelRule struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Action      string `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"`
+	Regex       string `protobuf:"bytes,2,opt,name=regex,proto3" json:"regex,omitempty"`
+	Replacement string `protobuf:"bytes,3,opt,name=replacement,proto3" json:"replacement,omitempty"`
+	Prefix      string `protobuf:"bytes,4,opt,name=prefix,proto3" json:"prefix,omitempty"`
+}
+
+func (x *RelabelRule) Reset() {
+	*x = RelabelRule{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_agent_config_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RelabelRule) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RelabelRule) ProtoMessage() {}
+
+func (x *RelabelRule) ProtoReflect() protoreflect.Message {
+	mi := &file_agent_config_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RelabelRule.ProtoReflect.Descriptor instead.
+func (*RelabelRule) Descriptor() ([]byte, []int) {
+	return file_agent_config_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RelabelRule) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *RelabelRule) GetRegex() string {
+	if x != nil {
+		return x.Regex
+	}
+	return ""
+}
+
+func (x *RelabelRule) GetReplacement() string {
+	if x != nil {
+		return x.Replacement
+	}
+	return ""
+}
+
+func (x *RelabelRule) GetPrefix() string {
+	if x != nil {
+		return x.Prefix
+	}
+	return ""
+}
+
+// AgentRemoteConfig настройки группы агентов. Незаданные поля агент берет из локальной конфигурации.
+// Адрес сервера, ключи, сертификаты, транспорт, правила сборщиков process, exec и prometheus
+// и адрес statsd только локальные: они зависят от хоста или защищают соединение с сервером
+type AgentRemoteConfig struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Group                  string               `protobuf:"bytes,1,opt,name=group,proto3" json:"group,omitempty"`
+	Version                string               `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	PollInterval           *durationpb.Duration `protobuf:"bytes,3,opt,name=poll_interval,json=pollInterval,proto3" json:"poll_interval,omitempty"`
+	ReportInterval         *durationpb.Duration `protobuf:"bytes,4,opt,name=report_interval,json=reportInterval,proto3" json:"report_interval,omitempty"`
+	Collectors             []string             `protobuf:"bytes,5,rep,name=collectors,proto3" json:"collectors,omitempty"`
+	DiskMountPointsInclude []string             `protobuf:"bytes,6,rep,name=disk_mount_points_include,json=diskMountPointsInclude,proto3" json:"disk_mount_points_include,omitempty"`
+	DiskMountPointsExclude []string             `protobuf:"bytes,7,rep,name=disk_mount_points_exclude,json=diskMountPointsExclude,proto3" json:"disk_mount_points_exclude,omitempty"`
+	DiskFsTypesInclude     []string             `protobuf:"bytes,8,rep,name=disk_fs_types_include,json=diskFsTypesInclude,proto3" json:"disk_fs_types_include,omitempty"`
+	DiskFsTypesExclude     []string             `protobuf:"bytes,9,rep,name=disk_fs_types_exclude,json=diskFsTypesExclude,proto3" json:"disk_fs_types_exclude,omitempty"`
+	NetInterfacesInclude   []string             `protobuf:"bytes,10,rep,name=net_interfaces_include,json=netInterfacesInclude,proto3" json:"net_interfaces_include,omitempty"`
+	NetInterfacesExclude   []string             `protobuf:"bytes,11,rep,name=net_interfaces_exclude,json=netInterfacesExclude,proto3" json:"net_interfaces_exclude,omitempty"`
+	RelabelRules           []*RelabelRule       `protobuf:"bytes,12,rep,name=relabel_rules,json=relabelRules,proto3" json:"relabel_rules,omitempty"`
+}
+
+func (x *AgentRemoteConfig) Reset() {
+	*x = AgentRemoteConfig{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_agent_config_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AgentRemoteConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AgentRemoteConfig) ProtoMessage() {}
+
+func (x *AgentRemoteConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_agent_config_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AgentRemoteConfig.ProtoReflect.Descriptor instead.
+func (*AgentRemoteConfig) Descriptor() ([]byte, []int) {
+	return file_agent_config_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *AgentRemoteConfig) GetGroup() string {
+	if x != nil {
+		return x.Group
+	}
+	return ""
+}
+
+func (x *AgentRemoteConfig) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *AgentRemoteConfig) GetPollInterval() *durationpb.Duration {
+	if x != nil {
+		return x.PollInterval
+	}
+	return nil
+}
+
+func (x *AgentRemoteConfig) GetReportInterval() *durationpb.Duration {
+	if x != nil {
+		return x.ReportInterval
+	}
+	return nil
+}
+
+func (x *AgentRemoteConfig) GetCollectors() []string {
+	if x != nil {
+		return x.Collectors
+	}
+	return nil
+}
+
+func (x *AgentRemoteConfig) GetDiskMountPointsInclude() []string {
+	if x != nil {
+		return x.DiskMountPointsInclude
+	}
+	return nil
+}
+
+func (x *AgentRemoteConfig) GetDiskMountPointsExclude() []string {
+	if x != nil {
+		return x.DiskMountPointsExclude
+	}
+	return nil
+}
+
+func (x *AgentRemoteConfig) GetDiskFsTypesInclude() []string {
+	if x != nil {
+		return x.DiskFsTypesInclude
+	}
+	return nil
+}
+
+func (x *AgentRemoteConfig) GetDiskFsTypesExclude() []string {
+	if x != nil {
+		return x.DiskFsTypesExclude
+	}
+	return nil
+}
+
+func (x *AgentRemoteConfig) GetNetInterfacesInclude() []string {
+	if x != nil {
+		return x.NetInterfacesInclude
+	}
+	return nil
+}
+
+func (x *AgentRemoteConfig) GetNetInterfacesExclude() []string {
+	if x != nil {
+		return x.NetInterfacesExclude
+	}
+	return nil
+}
+
+func (x *AgentRemoteConfig) GetRelabelRules() []*RelabelRule {
+	if x != nil {
+		return x.RelabelRules
+	}
+	return nil
+}
+
+var File_agent_config_proto protoreflect.FileDescriptor
+
+var file_agent_config_proto_rawDesc = []byte{
+	0x0a, 0x12, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x5f, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x05, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1e, 0x67, 0x6f, 0x6f,
+	0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64, 0x75, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x46, 0x0a, 0x12, 0x41,
+	0x67, 0x65, 0x6e, 0x74, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x14, 0x0a, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x1a, 0x0a, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e,
+	0x61, 0x6d, 0x65, 0x22, 0x75, 0x0a, 0x0b, 0x52, 0x65, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x52, 0x75,
+	0x6c, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x61, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x0a, 0x05, 0x72, 0x65,
+	0x67, 0x65, 0x78, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x72, 0x65, 0x67, 0x65, 0x78,
+	0x12, 0x20, 0x0a, 0x0b, 0x72, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x72, 0x65, 0x70, 0x6c, 0x61, 0x63, 0x65, 0x6d, 0x65,
+	0x6e, 0x74, 0x12, 0x16, 0x0a, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x22, 0xe8, 0x04, 0x0a, 0x11, 0x41,
+	0x67, 0x65, 0x6e, 0x74, 0x52, 0x65, 0x6d, 0x6f, 0x74, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67,
+	0x12, 0x14, 0x0a, 0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x12, 0x3e, 0x0a, 0x0d, 0x70, 0x6f, 0x6c, 0x6c, 0x5f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61,
+	0x6c, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x52, 0x0c, 0x70, 0x6f, 0x6c, 0x6c, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c,
+	0x12, 0x42, 0x0a, 0x0f, 0x72, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x5f, 0x69, 0x6e, 0x74, 0x65, 0x72,
+	0x76, 0x61, 0x6c, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x44, 0x75, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0e, 0x72, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x49, 0x6e, 0x74, 0x65,
+	0x72, 0x76, 0x61, 0x6c, 0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63, 0x74, 0x6f,
+	0x72, 0x73, 0x18, 0x05, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x63, 0x6f, 0x6c, 0x6c, 0x65, 0x63,
+	0x74, 0x6f, 0x72, 0x73, 0x12, 0x39, 0x0a, 0x19, 0x64, 0x69, 0x73, 0x6b, 0x5f, 0x6d, 0x6f, 0x75,
+	0x6e, 0x74, 0x5f, 0x70, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x5f, 0x69, 0x6e, 0x63, 0x6c, 0x75, 0x64,
+	0x65, 0x18, 0x06, 0x20, 0x03, 0x28, 0x09, 0x52, 0x16, 0x64, 0x69, 0x73, 0x6b, 0x4d, 0x6f, 0x75,
+	0x6e, 0x74, 0x50, 0x6f, 0x69, 0x6e, 0x74, 0x73, 0x49, 0x6e, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x12,
+	0x39, 0x0a, 0x19, 0x64, 0x69, 0x73, 0x6b, 0x5f, 0x6d, 0x6f, 0x75, 0x6e, 0x74, 0x5f, 0x70, 0x6f,
+	0x69, 0x6e, 0x74, 0x73, 0x5f, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x18, 0x07, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x16, 0x64, 0x69, 0x73, 0x6b, 0x4d, 0x6f, 0x75, 0x6e, 0x74, 0x50, 0x6f, 0x69,
+	0x6e, 0x74, 0x73, 0x45, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x12, 0x31, 0x0a, 0x15, 0x64, 0x69,
+	0x73, 0x6b, 0x5f, 0x66, 0x73, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x73, 0x5f, 0x69, 0x6e, 0x63, 0x6c,
+	0x75, 0x64, 0x65, 0x18, 0x08, 0x20, 0x03, 0x28, 0x09, 0x52, 0x12, 0x64, 0x69, 0x73, 0x6b, 0x46,
+	0x73, 0x54, 0x79, 0x70, 0x65, 0x73, 0x49, 0x6e, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x12, 0x31, 0x0a,
+	0x15, 0x64, 0x69, 0x73, 0x6b, 0x5f, 0x66, 0x73, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x73, 0x5f, 0x65,
+	0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x18, 0x09, 0x20, 0x03, 0x28, 0x09, 0x52, 0x12, 0x64, 0x69,
+	0x73, 0x6b, 0x46, 0x73, 0x54, 0x79, 0x70, 0x65, 0x73, 0x45, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65,
+	0x12, 0x34, 0x0a, 0x16, 0x6e, 0x65, 0x74, 0x5f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63,
+	0x65, 0x73, 0x5f, 0x69, 0x6e, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x18, 0x0a, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x14, 0x6e, 0x65, 0x74, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x49,
+	0x6e, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x12, 0x34, 0x0a, 0x16, 0x6e, 0x65, 0x74, 0x5f, 0x69, 0x6e,
+	0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x5f, 0x65, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65,
+	0x18, 0x0b, 0x20, 0x03, 0x28, 0x09, 0x52, 0x14, 0x6e, 0x65, 0x74, 0x49, 0x6e, 0x74, 0x65, 0x72,
+	0x66, 0x61, 0x63, 0x65, 0x73, 0x45, 0x78, 0x63, 0x6c, 0x75, 0x64, 0x65, 0x12, 0x37, 0x0a, 0x0d,
+	0x72, 0x65, 0x6c, 0x61, 0x62, 0x65, 0x6c, 0x5f, 0x72, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x0c, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x52, 0x65, 0x6c, 0x61,
+	0x62, 0x65, 0x6c, 0x52, 0x75, 0x6c, 0x65, 0x52, 0x0c, 0x72, 0x65, 0x6c, 0x61, 0x62, 0x65, 0x6c,
+	0x52, 0x75, 0x6c, 0x65, 0x73, 0x42, 0x35, 0x5a, 0x33, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x30, 0x64, 0x65, 0x72, 0x65, 0x64, 0x32, 0x37, 0x33, 0x2f, 0x67,
+	0x6f, 0x2d, 0x61, 0x64, 0x76, 0x2d, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x2f, 0x69, 0x6e,
+	0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x62, 0x06, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_agent_config_proto_rawDescOnce sync.Once
+	file_agent_config_proto_rawDescData = file_agent_config_proto_rawDesc
+)
+
+func file_agent_config_proto_rawDescGZIP() []byte {
+	file_agent_config_proto_rawDescOnce.Do(func() {
+		file_agent_config_proto_rawDescData = protoimpl.X.CompressGZIP(file_agent_config_proto_rawDescData)
+	})
+	return file_agent_config_proto_rawDescData
+}
+
+var file_agent_config_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_agent_config_proto_goTypes = []interface{}{
+	(*AgentConfigRequest)(nil),  // 0: proto.AgentConfigRequest
+	(*RelabelRule)(nil),         // 1: proto.RelabelRule
+	(*AgentRemoteConfig)(nil),   // 2: proto.AgentRemoteConfig
+	(*durationpb.Duration)(nil), // 3: google.protobuf.Duration
+}
+var file_agent_config_proto_depIdxs = []int32{
+	3, // 0: proto.AgentRemoteConfig.poll_interval:type_name -> google.protobuf.Duration
+	3, // 1: proto.AgentRemoteConfig.report_interval:type_name -> google.protobuf.Duration
+	1, // 2: proto.AgentRemoteConfig.relabel_rules:type_name -> proto.RelabelRule
+	3, // [3:3] is the sub-list for method output_type
+	3, // [3:3] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_agent_config_proto_init() }
+func file_agent_config_proto_init() {
+	if File_agent_config_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_agent_config_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentConfigRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_agent_config_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RelabelRule); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_agent_config_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AgentRemoteConfig); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_agent_config_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_agent_config_proto_goTypes,
+		DependencyIndexes: file_agent_config_proto_depIdxs,
+		MessageInfos:      file_agent_config_proto_msgTypes,
+	}.Build()
+	File_agent_config_proto = out.File
+	file_agent_config_proto_rawDesc = nil
+	file_agent_config_proto_goTypes = nil
+	file_agent_config_proto_depIdxs = nil
+}
@@ -0,0 +1,363 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+)
+
+// ErrNoData выражение не дает значения, например ни одна метрика не найдена
+var ErrNoData = errors.New("no data")
+
+// Env данные для вычисления выражения
+type Env struct {
+	// Values текущие значения метрик по имени
+	Values map[string]float64
+	// History прошлые значения метрик для функций над окном, может быть nil
+	History *History
+	// Derived имена производных метрик, которые выбираются только по точному имени,
+	// чтобы сумма по ~"CPUutilization.*" не включала записанную ей же метрику CPUutilizationTotal
+	Derived map[string]struct{}
+	Now     time.Time
+}
+
+// Eval вычисляет выражение. Если значения нет, возвращает ErrNoData
+func (e *Expr) Eval(env *Env) (float64, error) {
+	v, err := e.root.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	return v.scalar()
+}
+
+// Series значение метрики в результате запроса
+type Series struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// Result результат запроса: одно число или значения выбранных метрик, отсортированные по имени
+type Result struct {
+	Scalar *float64 `json:"scalar,omitempty"`
+	Series []Series `json:"series"`
+}
+
+// Query вычисляет выражение, в отличие от Eval не требует, чтобы результат был одним числом
+func (e *Expr) Query(env *Env) (Result, error) {
+	v, err := e.root.eval(env)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Series: make([]Series, 0, len(v.series))}
+	if v.isNumber {
+		result.Scalar = &v.number
+		return result, nil
+	}
+	for name, value := range v.series {
+		result.Series = append(result.Series, Series{Name: name, Value: value})
+	}
+	sort.Slice(result.Series, func(i, j int) bool {
+		return result.Series[i].Name < result.Series[j].Name
+	})
+	return result, nil
+}
+
+// Values возвращает значения метрик по имени, при совпадении имен gauge имеет приоритет
+func Values(metrics []metric.Metric) map[string]float64 {
+	values := make(map[string]float64, len(metrics))
+	for _, m := range metrics {
+		switch m.GetType() {
+		case metric.Gauge:
+			values[m.GetName()] = m.GetGaugeValue()
+		case metric.Counter:
+			if _, ok := values[m.GetName()]; !ok {
+				values[m.GetName()] = float64(m.GetCounterValue())
+			}
+		}
+	}
+	return values
+}
+
+// value результат вычисления: число или набор значений метрик по имени
+type value struct {
+	number   float64
+	isNumber bool
+	series   map[string]float64
+}
+
+// single возвращает число или значение единственной метрики набора
+func (v value) single() (float64, bool) {
+	if v.isNumber {
+		return v.number, true
+	}
+	if len(v.series) == 1 {
+		for _, s := range v.series {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+func (v value) scalar() (float64, error) {
+	if v.isNumber {
+		return v.number, nil
+	}
+	switch len(v.series) {
+	case 0:
+		return 0, ErrNoData
+	case 1:
+		for _, s := range v.series {
+			return s, nil
+		}
+	}
+	names := make([]string, 0, len(v.series))
+	for name := range v.series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("expression matches %d metrics %v, use an aggregation function", len(names), names)
+}
+
+type node interface {
+	eval(env *Env) (value, error)
+}
+
+type number float64
+
+func (n number) eval(*Env) (value, error) {
+	return value{number: float64(n), isNumber: true}, nil
+}
+
+// selector выбирает метрики по имени или регулярному выражению
+type selector struct {
+	name   string
+	regex  *regexp.Regexp
+	window time.Duration
+}
+
+func (s *selector) match(env *Env, name string) bool {
+	if s.regex == nil {
+		return name == s.name
+	}
+	if _, ok := env.Derived[name]; ok {
+		return false
+	}
+	return s.regex.MatchString(name)
+}
+
+func (s *selector) eval(env *Env) (value, error) {
+	series := make(map[string]float64)
+	if s.regex == nil {
+		if v, ok := env.Values[s.name]; ok {
+			series[s.name] = v
+		}
+		return value{series: series}, nil
+	}
+	for name, v := range env.Values {
+		if s.match(env, name) {
+			series[name] = v
+		}
+	}
+	return value{series: series}, nil
+}
+
+type binary struct {
+	op       string
+	lhs, rhs node
+}
+
+// eval применяет операцию к числам. Если один операнд дает набор метрик, а другой одно значение,
+// операция применяется к каждой метрике набора. Два набора сопоставляются по имени метрики
+func (b *binary) eval(env *Env) (value, error) {
+	lhs, err := b.lhs.eval(env)
+	if err != nil {
+		return value{}, err
+	}
+	rhs, err := b.rhs.eval(env)
+	if err != nil {
+		return value{}, err
+	}
+
+	if x, ok := lhs.single(); ok {
+		if y, ok := rhs.single(); ok {
+			result, err := apply(b.op, x, y)
+			return value{number: result, isNumber: true}, err
+		}
+	}
+
+	series := make(map[string]float64)
+	switch {
+	case lhs.isNumber || len(lhs.series) == 1:
+		x, _ := lhs.single()
+		for name, y := range rhs.series {
+			if series[name], err = apply(b.op, x, y); err != nil {
+				return value{}, err
+			}
+		}
+	case rhs.isNumber || len(rhs.series) == 1:
+		y, _ := rhs.single()
+		for name, x := range lhs.series {
+			if series[name], err = apply(b.op, x, y); err != nil {
+				return value{}, err
+			}
+		}
+	default:
+		for name, x := range lhs.series {
+			if y, ok := rhs.series[name]; ok {
+				if series[name], err = apply(b.op, x, y); err != nil {
+					return value{}, err
+				}
+			}
+		}
+	}
+	return value{series: series}, nil
+}
+
+func apply(op string, x, y float64) (float64, error) {
+	switch op {
+	case "+":
+		return x + y, nil
+	case "-":
+		return x - y, nil
+	case "*":
+		return x * y, nil
+	default:
+		if y == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return x / y, nil
+	}
+}
+
+func evalScalar(n node, env *Env) (float64, error) {
+	v, err := n.eval(env)
+	if err != nil {
+		return 0, err
+	}
+	return v.scalar()
+}
+
+type function struct {
+	// window функция принимает метрику с окном, например rate(PollCount[1m])
+	window bool
+	apply  func(env *Env, arg node) (value, error)
+}
+
+var functions = map[string]function{
+	"sum":   {apply: aggregate(sumValues)},
+	"avg":   {apply: aggregate(avgValues)},
+	"min":   {apply: aggregate(minValues)},
+	"max":   {apply: aggregate(maxValues)},
+	"count": {apply: count},
+	"rate":  {window: true, apply: rate},
+}
+
+type call struct {
+	name string
+	fn   function
+	arg  node
+}
+
+func (c *call) eval(env *Env) (value, error) {
+	return c.fn.apply(env, c.arg)
+}
+
+// aggregate возвращает функцию, которая сводит набор метрик к одному значению.
+// Для пустого набора функция возвращает ErrNoData
+func aggregate(reduce func(values []float64) float64) func(env *Env, arg node) (value, error) {
+	return func(env *Env, arg node) (value, error) {
+		v, err := arg.eval(env)
+		if err != nil {
+			return value{}, err
+		}
+
+		var values []float64
+		if v.isNumber {
+			values = []float64{v.number}
+		}
+		for _, s := range v.series {
+			values = append(values, s)
+		}
+		if len(values) == 0 {
+			return value{}, ErrNoData
+		}
+		return value{number: reduce(values), isNumber: true}, nil
+	}
+}
+
+func sumValues(values []float64) float64 {
+	var result float64
+	for _, v := range values {
+		result += v
+	}
+	return result
+}
+
+func avgValues(values []float64) float64 {
+	return sumValues(values) / float64(len(values))
+}
+
+func minValues(values []float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = math.Min(result, v)
+	}
+	return result
+}
+
+func maxValues(values []float64) float64 {
+	result := values[0]
+	for _, v := range values[1:] {
+		result = math.Max(result, v)
+	}
+	return result
+}
+
+// count количество выбранных метрик, для пустого набора 0
+func count(env *Env, arg node) (value, error) {
+	v, err := arg.eval(env)
+	if err != nil {
+		return value{}, err
+	}
+	n := len(v.series)
+	if v.isNumber {
+		n = 1
+	}
+	return value{number: float64(n), isNumber: true}, nil
+}
+
+// rate скорость роста значения в секунду за окно. Уменьшение значения считается сбросом счетчика,
+// тогда рост после сброса отсчитывается от нуля
+func rate(env *Env, arg node) (value, error) {
+	s := arg.(*selector)
+	series := make(map[string]float64)
+	for _, name := range env.History.names() {
+		if !s.match(env, name) {
+			continue
+		}
+		samples := env.History.since(name, env.Now.Add(-s.window))
+		if len(samples) < 2 {
+			continue
+		}
+
+		var increase float64
+		for i := 1; i < len(samples); i++ {
+			if delta := samples[i].Value - samples[i-1].Value; delta >= 0 {
+				increase += delta
+			} else {
+				increase += samples[i].Value
+			}
+		}
+		elapsed := samples[len(samples)-1].Time.Sub(samples[0].Time).Seconds()
+		if elapsed > 0 {
+			series[name] = increase / elapsed
+		}
+	}
+	return value{series: series}, nil
+}
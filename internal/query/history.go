@@ -0,0 +1,122 @@
+package query
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+// Sample значение метрики в момент времени
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// History хранит значения метрик за последнее время для функций над окном.
+// Значения добавляются периодически через Run или вызовом Add. Методы безопасно вызывать у nil
+type History struct {
+	mu        *sync.RWMutex
+	retention time.Duration
+	samples   map[string][]Sample
+}
+
+// NewHistory возвращает историю, которая хранит значения не дольше retention
+func NewHistory(retention time.Duration) *History {
+	return &History{
+		mu:        new(sync.RWMutex),
+		retention: retention,
+		samples:   make(map[string][]Sample),
+	}
+}
+
+// Add добавляет текущие значения метрик и удаляет устаревшие
+func (h *History) Add(now time.Time, values map[string]float64) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for name, v := range values {
+		h.samples[name] = append(h.samples[name], Sample{Time: now, Value: v})
+	}
+
+	from := now.Add(-h.retention)
+	for name, samples := range h.samples {
+		i := 0
+		for i < len(samples) && samples[i].Time.Before(from) {
+			i++
+		}
+		if i == len(samples) {
+			delete(h.samples, name)
+			continue
+		}
+		h.samples[name] = samples[i:]
+	}
+}
+
+func (h *History) names() []string {
+	if h == nil {
+		return nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	names := make([]string, 0, len(h.samples))
+	for name := range h.samples {
+		names = append(names, name)
+	}
+	return names
+}
+
+// since возвращает значения метрики начиная с момента from
+func (h *History) since(name string, from time.Time) []Sample {
+	if h == nil {
+		return nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	samples := h.samples[name]
+	i := 0
+	for i < len(samples) && samples[i].Time.Before(from) {
+		i++
+	}
+	return append([]Sample(nil), samples[i:]...)
+}
+
+// Sample добавляет в историю текущие значения метрик из хранилища
+func (h *History) Sample(ctx context.Context, repo storage.Repository, now time.Time) error {
+	if h == nil {
+		return nil
+	}
+	metrics, err := repo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	h.Add(now, Values(metrics))
+	return nil
+}
+
+// Run сохраняет значения метрик с заданным интервалом до отмены контекста
+func (h *History) Run(ctx context.Context, repo storage.Repository, interval time.Duration, logger zerolog.Logger) {
+	if h == nil || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			if err := h.Sample(ctx, repo, now); err != nil {
+				logger.Error().Err(err).Msg("query: failed to sample metrics")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,314 @@
+// Package query разбирает и вычисляет выражения над значениями метрик.
+// Выражение состоит из чисел, имен метрик, выборок по регулярному выражению ~"CPUutilization.*",
+// арифметических операций + - * / со скобками и функций:
+//
+//	sum(~"CPUutilization.*")    сумма значений выбранных метрик, аналогично avg, min и max
+//	count(~"CPUutilization.*")  количество выбранных метрик
+//	rate(PollCount[1m])         скорость роста метрики в секунду за окно
+//
+// Арифметическая операция над выборкой и числом применяется к каждой метрике выборки,
+// две выборки сопоставляются по имени метрики
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Expr разобранное выражение
+type Expr struct {
+	root node
+	// window наибольшее окно функций над историей значений
+	window time.Duration
+	source string
+}
+
+// Parse разбирает выражение
+func Parse(source string) (*Expr, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return &Expr{root: root, window: p.window, source: source}, nil
+}
+
+// Window возвращает наибольшее окно функций выражения, сколько истории значений нужно для вычисления
+func (e *Expr) Window() time.Duration {
+	return e.window
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenString
+	tokenOp
+	tokenDuration
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lex(source string) ([]token, error) {
+	var tokens []token
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' ||
+				runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '+' || runes[i] == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: string(runes[start:i]), pos: start})
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+		case r == '"':
+			i++
+			for i < len(runes) && runes[i] != '"' {
+				if runes[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated string at %d", start)
+			}
+			i++
+			text, err := strconv.Unquote(string(runes[start:i]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at %d: %w", start, err)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: start})
+		case r == '[':
+			for i < len(runes) && runes[i] != ']' {
+				i++
+			}
+			if i >= len(runes) {
+				return nil, fmt.Errorf("unterminated window at %d", start)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenDuration, text: strings.TrimSpace(string(runes[start+1 : i-1])), pos: start})
+		case strings.ContainsRune("+-*/()~", r):
+			i++
+			tokens = append(tokens, token{kind: tokenOp, text: string(r), pos: start})
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", r, start)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// parser разбирает выражение рекурсивным спуском:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = "-" unary | primary
+//	primary = number | "(" expr ")" | ident "(" expr ")" | selector
+//	selector = (ident | "~" string) [ "[" duration "]" ]
+type parser struct {
+	tokens []token
+	pos    int
+	window time.Duration
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.peek()
+	if t.kind != tokenOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		t := p.peek()
+		return fmt.Errorf("expected %q at %d", op, t.pos)
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parseExpr() (node, error) {
+	lhs, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+", "-") {
+		op := p.next().text
+		rhs, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &binary{op: op, lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseTerm() (node, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*", "/") {
+		op := p.next().text
+		rhs, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &binary{op: op, lhs: lhs, rhs: rhs}
+	}
+	return lhs, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.isOp("-") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &binary{op: "*", lhs: number(-1), rhs: operand}, nil
+	}
+
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := n.(*selector); ok && s.window > 0 {
+		return nil, fmt.Errorf("window %s is allowed only as a function argument", s.window)
+	}
+	return n, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokenNumber:
+		p.next()
+		v, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at %d", t.text, t.pos)
+		}
+		return number(v), nil
+	case p.isOp("("):
+		p.next()
+		n, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	case t.kind == tokenIdent && p.tokens[p.pos+1].kind == tokenOp && p.tokens[p.pos+1].text == "(":
+		return p.parseCall()
+	case t.kind == tokenIdent || p.isOp("~"):
+		return p.parseSelector()
+	case t.kind == tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+}
+
+func (p *parser) parseSelector() (node, error) {
+	s := &selector{}
+	if p.isOp("~") {
+		p.next()
+		t := p.next()
+		if t.kind != tokenString {
+			return nil, fmt.Errorf("expected regular expression string at %d", t.pos)
+		}
+		re, err := regexp.Compile("^(?:" + t.text + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression at %d: %w", t.pos, err)
+		}
+		s.regex = re
+	} else {
+		s.name = p.next().text
+	}
+
+	if t := p.peek(); t.kind == tokenDuration {
+		p.next()
+		window, err := time.ParseDuration(t.text)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid window %q at %d", t.text, t.pos)
+		}
+		s.window = window
+		if window > p.window {
+			p.window = window
+		}
+	}
+	return s, nil
+}
+
+func (p *parser) parseCall() (node, error) {
+	t := p.next()
+	fn, ok := functions[t.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q at %d", t.text, t.pos)
+	}
+	p.next()
+
+	var arg node
+	var err error
+	if fn.window {
+		arg, err = p.parsePrimary()
+		if s, ok := arg.(*selector); err == nil && (!ok || s.window == 0) {
+			err = fmt.Errorf("%s expects a metric with window, e.g. %s(PollCount[1m])", t.text, t.text)
+		}
+	} else {
+		arg, err = p.parseExpr()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &call{name: t.text, fn: fn, arg: arg}, nil
+}
@@ -0,0 +1,139 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpr_Eval(t *testing.T) {
+	values := map[string]float64{
+		"HeapInuse":       50,
+		"HeapSys":         200,
+		"CPUutilization1": 10,
+		"CPUutilization2": 30,
+		"PollCount":       100,
+	}
+
+	now := time.Now()
+	history := NewHistory(time.Minute)
+	history.Add(now.Add(-90*time.Second), map[string]float64{"PollCount": 0})
+	history.Add(now.Add(-time.Minute), map[string]float64{"PollCount": 40})
+	history.Add(now.Add(-30*time.Second), map[string]float64{"PollCount": 70})
+	// Сброс счетчика: рост после сброса отсчитывается от нуля
+	history.Add(now, map[string]float64{"PollCount": 30})
+
+	tests := []struct {
+		name    string
+		expr    string
+		want    float64
+		wantErr error
+	}{
+		{
+			name: "should divide metrics",
+			expr: "HeapInuse / HeapSys",
+			want: 0.25,
+		},
+		{
+			name: "should respect precedence and parentheses",
+			expr: "-(HeapSys - HeapInuse) * 2 + 1e2",
+			want: -200,
+		},
+		{
+			name: "should sum metrics selected by regex",
+			expr: `sum(~"CPUutilization.*")`,
+			want: 40,
+		},
+		{
+			name: "should compute rate over window with counter reset",
+			expr: "rate(PollCount[1m])",
+			want: 1,
+		},
+		{
+			name:    "should return no data for unknown metric",
+			expr:    "Unknown * 2",
+			wantErr: ErrNoData,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.expr)
+			assert.NoError(t, err)
+
+			got, err := expr.Eval(&Env{Values: values, History: history, Now: now})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func TestExpr_EvalErrors(t *testing.T) {
+	values := map[string]float64{"CPUutilization1": 10, "CPUutilization2": 30, "HeapSys": 0}
+	for _, source := range []string{`~"CPUutilization.*" * 2`, "CPUutilization1 / HeapSys"} {
+		expr, err := Parse(source)
+		assert.NoError(t, err)
+		_, err = expr.Eval(&Env{Values: values, Now: time.Now()})
+		assert.Error(t, err, source)
+		assert.NotErrorIs(t, err, ErrNoData, source)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name       string
+		expr       string
+		wantWindow time.Duration
+		wantErr    assert.ErrorAssertionFunc
+	}{
+		{
+			name:       "should parse nested functions with window",
+			expr:       `sum(rate(~"CPU.*"[5m])) / rate(PollCount[1m])`,
+			wantWindow: 5 * time.Minute,
+			wantErr:    assert.NoError,
+		},
+		{
+			name:    "should fail on unknown function",
+			expr:    "median(Alloc)",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on window outside function",
+			expr:    "PollCount[1m] * 2",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on rate without window",
+			expr:    "rate(PollCount)",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on invalid regex",
+			expr:    `sum(~"(")`,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on unbalanced parentheses",
+			expr:    "(HeapInuse / HeapSys",
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on trailing tokens",
+			expr:    "HeapInuse HeapSys",
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := Parse(tt.expr)
+			tt.wantErr(t, err)
+			if err == nil {
+				assert.Equal(t, tt.wantWindow, expr.Window())
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+// Package ratelimit ограничивает частоту запросов и количество принимаемых метрик для каждого клиента сервера.
+// Ограничения реализованы алгоритмом token bucket: запас клиента пополняется с постоянной скоростью
+// до емкости корзины, каждый запрос или метрика расходует единицу запаса
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// idleTimeout время, после которого корзины неактивного клиента удаляются
+	idleTimeout = 10 * time.Minute
+	// sweepInterval как часто удаляются корзины неактивных клиентов
+	sweepInterval = time.Minute
+)
+
+// bucket корзина токенов. Запас может уйти в минус, если за раз расходуется больше емкости корзины,
+// тогда клиент ждет, пока долг не будет погашен
+type bucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newBucket(rate float64, capacity float64, now time.Time) *bucket {
+	return &bucket{
+		rate:     rate,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     now,
+	}
+}
+
+// take расходует n токенов, если их достаточно. Иначе возвращает время, через которое запрос можно повторить
+func (b *bucket) take(n float64, now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	// Запрос больше емкости корзины принимается только при полной корзине
+	need := math.Min(n, b.capacity)
+	if b.tokens >= need {
+		b.tokens -= n
+		return true, 0
+	}
+	return false, time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+// Limits ограничения для одного клиента, нулевое значение отключает соответствующее ограничение
+type Limits struct {
+	// RequestsPerSecond допустимое среднее количество запросов в секунду
+	RequestsPerSecond float64
+	// Burst количество запросов, которое можно выполнить сразу, по умолчанию равно RequestsPerSecond
+	Burst int
+	// MetricsPerMinute допустимое количество принятых метрик в минуту
+	MetricsPerMinute int
+}
+
+type client struct {
+	requests *bucket
+	metrics  *bucket
+	lastSeen time.Time
+}
+
+// Limiter хранит корзины клиентов. Методы безопасно вызывать у nil, тогда ограничения не действуют
+type Limiter struct {
+	mu        *sync.Mutex
+	limits    Limits
+	clients   map[string]*client
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// New возвращает ограничитель, для нулевых ограничений возвращает nil
+func New(limits Limits) *Limiter {
+	if limits.RequestsPerSecond <= 0 && limits.MetricsPerMinute <= 0 {
+		return nil
+	}
+	if limits.Burst <= 0 {
+		limits.Burst = int(math.Max(1, math.Ceil(limits.RequestsPerSecond)))
+	}
+
+	return &Limiter{
+		mu:        new(sync.Mutex),
+		limits:    limits,
+		clients:   make(map[string]*client),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (l *Limiter) client(key string, now time.Time) *client {
+	if now.Sub(l.lastSweep) > sweepInterval {
+		for k, c := range l.clients {
+			if now.Sub(c.lastSeen) > idleTimeout {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	c, ok := l.clients[key]
+	if !ok {
+		c = &client{}
+		if l.limits.RequestsPerSecond > 0 {
+			c.requests = newBucket(l.limits.RequestsPerSecond, float64(l.limits.Burst), now)
+		}
+		if l.limits.MetricsPerMinute > 0 {
+			c.metrics = newBucket(float64(l.limits.MetricsPerMinute)/60, float64(l.limits.MetricsPerMinute), now)
+		}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+	return c
+}
+
+// AllowRequest учитывает запрос клиента. Если лимит исчерпан, возвращает время, через которое запрос можно повторить
+func (l *Limiter) AllowRequest(key string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if c := l.client(key, now); c.requests != nil {
+		return c.requests.take(1, now)
+	}
+	return true, 0
+}
+
+// AllowMetrics учитывает n метрик, принятых от клиента. Если квота исчерпана, метрики не учитываются
+// и возвращается время, через которое их можно отправить повторно
+func (l *Limiter) AllowMetrics(key string, n int) (bool, time.Duration) {
+	if l == nil || n == 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if c := l.client(key, now); c.metrics != nil {
+		return c.metrics.take(float64(n), now)
+	}
+	return true, 0
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_AllowRequest(t *testing.T) {
+	limiter := New(Limits{RequestsPerSecond: 2, Burst: 2})
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		ok, _ := limiter.AllowRequest("ip:10.0.0.1")
+		assert.True(t, ok)
+	}
+	ok, retryAfter := limiter.AllowRequest("ip:10.0.0.1")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	ok, _ = limiter.AllowRequest("ip:10.0.0.2")
+	assert.True(t, ok, "other clients should have own limit")
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = limiter.AllowRequest("ip:10.0.0.1")
+	assert.True(t, ok, "bucket should refill over time")
+}
+
+func TestLimiter_AllowMetrics(t *testing.T) {
+	tests := []struct {
+		name      string
+		sent      []int
+		wait      time.Duration
+		n         int
+		want      bool
+		wantRetry time.Duration
+	}{
+		{
+			name: "should reject metrics over quota",
+			sent: []int{30, 30},
+			n:    60,
+			want: false,
+			// 60 метрик в минуту - одна метрика в секунду
+			wantRetry: 60 * time.Second,
+		},
+		{
+			name: "should allow metrics after refill",
+			sent: []int{60},
+			wait: 30 * time.Second,
+			n:    30,
+			want: true,
+		},
+		{
+			name: "should allow batch larger than quota on full bucket",
+			n:    100,
+			want: true,
+		},
+		{
+			name:      "should wait for debt after large batch",
+			sent:      []int{100},
+			wait:      30 * time.Second,
+			n:         1,
+			want:      false,
+			wantRetry: 11 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := New(Limits{MetricsPerMinute: 60})
+			now := time.Now()
+			limiter.now = func() time.Time { return now }
+
+			for _, n := range tt.sent {
+				limiter.AllowMetrics("ip:10.0.0.1", n)
+			}
+			now = now.Add(tt.wait)
+
+			ok, retryAfter := limiter.AllowMetrics("ip:10.0.0.1", tt.n)
+			assert.Equal(t, tt.want, ok)
+			assert.Equal(t, tt.wantRetry, retryAfter)
+
+			ok, _ = limiter.AllowRequest("ip:10.0.0.1")
+			assert.True(t, ok, "requests should not be limited")
+		})
+	}
+}
+
+func TestLimiter_Disabled(t *testing.T) {
+	limiter := New(Limits{})
+	assert.Nil(t, limiter)
+
+	ok, _ := limiter.AllowRequest("ip:10.0.0.1")
+	assert.True(t, ok)
+	ok, _ = limiter.AllowMetrics("ip:10.0.0.1", 1000)
+	assert.True(t, ok)
+}
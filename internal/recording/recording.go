@@ -0,0 +1,185 @@
+// Package recording периодически вычисляет производные метрики по правилам записи и сохраняет их в хранилище
+// как обычные gauge, чтобы клиентам не приходилось вычислять их самостоятельно.
+// Правила вычисляются по порядку, результат правила доступен следующим правилам той же проверки.
+// Записанные метрики не попадают в выборки правил по регулярному выражению, только по точному имени
+package recording
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/query"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+var recordNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Rule правило записи: значение выражения Expr сохраняется как gauge с именем Record
+type Rule struct {
+	Record string `mapstructure:"record" json:"record"`
+	Expr   string `mapstructure:"expr" json:"expr"`
+}
+
+type compiledRule struct {
+	Rule
+	expr *query.Expr
+}
+
+// RuleSet проверенный набор правил
+type RuleSet struct {
+	rules []compiledRule
+	// records имена записываемых метрик
+	records map[string]struct{}
+	window  time.Duration
+}
+
+// NewRuleSet разбирает выражения правил, имена записываемых метрик должны быть уникальны
+func NewRuleSet(rules []Rule) (*RuleSet, error) {
+	rs := &RuleSet{rules: make([]compiledRule, 0, len(rules)), records: make(map[string]struct{}, len(rules))}
+	for i, r := range rules {
+		if !recordNameRe.MatchString(r.Record) {
+			return nil, fmt.Errorf("rule %d: invalid record name %q", i, r.Record)
+		}
+		if _, ok := rs.records[r.Record]; ok {
+			return nil, fmt.Errorf("rule %s: duplicate record name", r.Record)
+		}
+		rs.records[r.Record] = struct{}{}
+
+		expr, err := query.Parse(r.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.Record, err)
+		}
+		if expr.Window() > rs.window {
+			rs.window = expr.Window()
+		}
+		rs.rules = append(rs.rules, compiledRule{Rule: r, expr: expr})
+	}
+	return rs, nil
+}
+
+// Window возвращает наибольшее окно выражений, сколько истории значений нужно для вычисления правил
+func (rs *RuleSet) Window() time.Duration {
+	return rs.window
+}
+
+// StoreFunc сохраняет вычисленные метрики
+type StoreFunc func(ctx context.Context, metrics []metric.Metric) error
+
+// Engine вычисляет правила записи. Методы безопасно вызывать у nil
+type Engine struct {
+	mu   *sync.Mutex
+	repo storage.Repository
+	// store сохраняет результаты правил, по умолчанию напрямую в repo
+	store   StoreFunc
+	logger  zerolog.Logger
+	rules   []compiledRule
+	records map[string]struct{}
+	history *query.History
+	now     func() time.Time
+}
+
+// NewEngine возвращает движок без правил, правила задаются SetRules.
+// Функции над окном, например rate, вычисляются по истории значений history
+func NewEngine(repo storage.Repository, history *query.History, logger zerolog.Logger) *Engine {
+	return &Engine{
+		mu:      new(sync.Mutex),
+		repo:    repo,
+		store:   repo.SaveAll,
+		logger:  logger,
+		history: history,
+		now:     time.Now,
+	}
+}
+
+// SetRules заменяет набор правил
+func (e *Engine) SetRules(rs *RuleSet) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rules = rs.rules
+	e.records = rs.records
+}
+
+// SetStore задает, как сохранять результаты правил, например через общий путь приема метрик сервера
+func (e *Engine) SetStore(store StoreFunc) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.store = store
+}
+
+// Evaluate вычисляет все правила и сохраняет результаты. Правило без данных пропускается,
+// ошибка вычисления правила логируется и не мешает остальным правилам
+func (e *Engine) Evaluate(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if len(e.rules) == 0 {
+		return nil
+	}
+
+	metrics, err := e.repo.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+	values := query.Values(metrics)
+	env := &query.Env{Values: values, History: e.history, Derived: e.records, Now: e.now()}
+	recorded := make([]metric.Metric, 0, len(e.rules))
+	for _, r := range e.rules {
+		v, err := r.expr.Eval(env)
+		if errors.Is(err, query.ErrNoData) {
+			continue
+		}
+		if err == nil && (math.IsNaN(v) || math.IsInf(v, 0)) {
+			err = errors.New("result is not a finite number")
+		}
+		if err != nil {
+			e.logger.Warn().Err(err).Str("record", r.Record).Msg("recording: failed to evaluate rule")
+			continue
+		}
+
+		values[r.Record] = v
+		recorded = append(recorded, metric.NewGaugeMetric(r.Record, v))
+	}
+
+	if len(recorded) == 0 {
+		return nil
+	}
+	return e.store(ctx, recorded)
+}
+
+// Run вычисляет правила с заданным интервалом до отмены контекста, нулевой интервал отключает вычисление
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	if e == nil || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.Evaluate(ctx); err != nil {
+				e.logger.Error().Err(err).Msg("recording: failed to evaluate rules")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
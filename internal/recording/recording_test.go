@@ -0,0 +1,97 @@
+package recording
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/query"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngine_Evaluate(t *testing.T) {
+	ctx := context.Background()
+	repo := storage.NewPersistenceRepo(storage.NewMemStorage())
+	assert.NoError(t, repo.SaveAll(ctx, []metric.Metric{
+		metric.NewGaugeMetric("HeapInuse", 50),
+		metric.NewGaugeMetric("HeapSys", 200),
+		metric.NewGaugeMetric("CPUutilization1", 10),
+		metric.NewGaugeMetric("CPUutilization2", 30),
+		metric.NewCounterMetric("PollCount", 10),
+	}))
+
+	history := query.NewHistory(time.Minute)
+	engine := NewEngine(repo, history, zerolog.Nop())
+	now := time.Now()
+	engine.now = func() time.Time { return now }
+
+	rules, err := NewRuleSet([]Rule{
+		{Record: "HeapUsage", Expr: "HeapInuse / HeapSys"},
+		{Record: "HeapUsagePercent", Expr: "HeapUsage * 100"},
+		{Record: "CPUutilizationTotal", Expr: `sum(~"CPUutilization.*")`},
+		{Record: "PollRate", Expr: "rate(PollCount[1m])"},
+		{Record: "Missing", Expr: "Unknown + 1"},
+	})
+	assert.NoError(t, err)
+	engine.SetRules(rules)
+
+	assert.NoError(t, history.Sample(ctx, repo, now))
+	assert.NoError(t, engine.Evaluate(ctx))
+	now = now.Add(30 * time.Second)
+	assert.NoError(t, repo.Save(ctx, metric.NewCounterMetric("PollCount", 60)))
+	assert.NoError(t, history.Sample(ctx, repo, now))
+	assert.NoError(t, engine.Evaluate(ctx))
+
+	want := map[string]float64{
+		"HeapUsage":           0.25,
+		"HeapUsagePercent":    25,
+		"CPUutilizationTotal": 40,
+		"PollRate":            2,
+	}
+	for name, value := range want {
+		m, err := repo.FindByID(ctx, metric.NewGaugeMetric(name, 0))
+		assert.NoError(t, err, name)
+		assert.Equal(t, metric.Gauge, m.GetType(), name)
+		assert.InDelta(t, value, m.GetGaugeValue(), 1e-9, name)
+	}
+	_, err = repo.FindByID(ctx, metric.NewGaugeMetric("Missing", 0))
+	assert.Error(t, err, "rule without data should not be recorded")
+}
+
+func TestNewRuleSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "should accept valid rules",
+			rules:   []Rule{{Record: "HeapUsage", Expr: "HeapInuse / HeapSys"}},
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "should fail on invalid record name",
+			rules:   []Rule{{Record: "heap usage", Expr: "HeapInuse / HeapSys"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on duplicate record name",
+			rules:   []Rule{{Record: "HeapUsage", Expr: "HeapInuse"}, {Record: "HeapUsage", Expr: "HeapSys"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on invalid expression",
+			rules:   []Rule{{Record: "HeapUsage", Expr: "HeapInuse /"}},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRuleSet(tt.rules)
+			tt.wantErr(t, err)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Package relabel содержит правила, по которым метрики отбрасываются или переименовываются перед отправкой или сохранением.
+// Правила применяются к имени метрики по порядку, каждое следующее правило видит результат предыдущих.
+// В модели метрик нет меток, поэтому постоянные метки, например имя хоста, задаются префиксом имени
+package relabel
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+)
+
+// Действия правил
+const (
+	// ActionKeep оставляет только метрики, имя которых соответствует regex
+	ActionKeep = "keep"
+	// ActionDrop отбрасывает метрики, имя которых соответствует regex
+	ActionDrop = "drop"
+	// ActionRename заменяет имя, соответствующее regex, на replacement. В replacement доступны группы $1, ${name}
+	ActionRename = "rename"
+	// ActionAddPrefix добавляет prefix к имени, соответствующему regex, пустой regex соответствует любому имени
+	ActionAddPrefix = "add_prefix"
+)
+
+// HostnameVar подставляется в replacement и prefix вместо имени хоста
+const HostnameVar = "${hostname}"
+
+// Rule правило обработки имени метрики. Regex должен соответствовать имени целиком
+type Rule struct {
+	Action      string `mapstructure:"action" json:"action"`
+	Regex       string `mapstructure:"regex" json:"regex"`
+	Replacement string `mapstructure:"replacement" json:"replacement"`
+	Prefix      string `mapstructure:"prefix" json:"prefix"`
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// Pipeline последовательность правил. Методы безопасно вызывать у nil, тогда метрики не меняются
+type Pipeline struct {
+	rules []compiledRule
+}
+
+// New проверяет и компилирует правила, для пустого списка правил возвращает nil
+func New(rules []Rule) (*Pipeline, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	hostname, _ := os.Hostname()
+	hostname = sanitizeName(hostname)
+
+	p := &Pipeline{rules: make([]compiledRule, 0, len(rules))}
+	for i, r := range rules {
+		if r.Regex == "" && r.Action != ActionAddPrefix {
+			return nil, fmt.Errorf("rule %d: regex is required for %s action", i, r.Action)
+		}
+		switch r.Action {
+		case ActionKeep, ActionDrop:
+		case ActionRename:
+			if r.Replacement == "" {
+				return nil, fmt.Errorf("rule %d: replacement is required for %s action", i, r.Action)
+			}
+		case ActionAddPrefix:
+			if r.Prefix == "" {
+				return nil, fmt.Errorf("rule %d: prefix is required for %s action", i, r.Action)
+			}
+		default:
+			return nil, fmt.Errorf("rule %d: unknown action %q", i, r.Action)
+		}
+
+		regex := r.Regex
+		if regex == "" {
+			regex = ".*"
+		}
+		re, err := regexp.Compile("^(?:" + regex + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		r.Replacement = strings.ReplaceAll(r.Replacement, HostnameVar, hostname)
+		r.Prefix = strings.ReplaceAll(r.Prefix, HostnameVar, hostname)
+		p.rules = append(p.rules, compiledRule{Rule: r, re: re})
+	}
+	return p, nil
+}
+
+// Relabel возвращает новое имя метрики и false, если метрику нужно отбросить
+func (p *Pipeline) Relabel(name string) (string, bool) {
+	if p == nil {
+		return name, true
+	}
+
+	for _, r := range p.rules {
+		switch r.Action {
+		case ActionKeep:
+			if !r.re.MatchString(name) {
+				return "", false
+			}
+		case ActionDrop:
+			if r.re.MatchString(name) {
+				return "", false
+			}
+		case ActionRename:
+			if r.re.MatchString(name) {
+				name = r.re.ReplaceAllString(name, r.Replacement)
+			}
+		case ActionAddPrefix:
+			if r.re.MatchString(name) {
+				name = r.Prefix + name
+			}
+		}
+	}
+	return name, name != ""
+}
+
+// Apply возвращает новый слайс с оставшимися после обработки метриками, исходный слайс не меняется
+func (p *Pipeline) Apply(metrics []metric.Metric) []metric.Metric {
+	if p == nil {
+		return metrics
+	}
+
+	result := make([]metric.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		if name, ok := p.Relabel(m.ID); ok {
+			m.ID = name
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// ApplyUpdatable то же, что Apply, для обновляемых метрик агента
+func (p *Pipeline) ApplyUpdatable(metrics []metric.UpdatableMetric) []metric.UpdatableMetric {
+	if p == nil {
+		return metrics
+	}
+
+	result := make([]metric.UpdatableMetric, 0, len(metrics))
+	for _, m := range metrics {
+		if name, ok := p.Relabel(m.ID); ok {
+			m.ID = name
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// sanitizeName приводит имя хоста к виду, пригодному для имени метрики
+func sanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+}
@@ -0,0 +1,145 @@
+package relabel
+
+import (
+	"os"
+	"testing"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipeline_Relabel(t *testing.T) {
+	hostname, _ := os.Hostname()
+
+	tests := []struct {
+		name     string
+		rules    []Rule
+		metric   string
+		want     string
+		wantKeep bool
+	}{
+		{
+			name:     "should drop matching metric",
+			rules:    []Rule{{Action: ActionDrop, Regex: "MCache.*"}},
+			metric:   "MCacheSys",
+			wantKeep: false,
+		},
+		{
+			name:     "should match whole name only",
+			rules:    []Rule{{Action: ActionDrop, Regex: "MCache"}},
+			metric:   "MCacheSys",
+			want:     "MCacheSys",
+			wantKeep: true,
+		},
+		{
+			name:     "should drop not matching metric on keep",
+			rules:    []Rule{{Action: ActionKeep, Regex: "CPU.*|Alloc"}},
+			metric:   "Frees",
+			wantKeep: false,
+		},
+		{
+			name:     "should keep matching metric",
+			rules:    []Rule{{Action: ActionKeep, Regex: "CPU.*|Alloc"}},
+			metric:   "Alloc",
+			want:     "Alloc",
+			wantKeep: true,
+		},
+		{
+			name:     "should rename with capture groups",
+			rules:    []Rule{{Action: ActionRename, Regex: "CPUutilization(\\d+)", Replacement: "cpu_${1}_utilization"}},
+			metric:   "CPUutilization3",
+			want:     "cpu_3_utilization",
+			wantKeep: true,
+		},
+		{
+			name:     "should prefix every metric with hostname",
+			rules:    []Rule{{Action: ActionAddPrefix, Prefix: HostnameVar + "_"}},
+			metric:   "Alloc",
+			want:     sanitizeName(hostname) + "_Alloc",
+			wantKeep: true,
+		},
+		{
+			name: "should apply rules in order",
+			rules: []Rule{
+				{Action: ActionAddPrefix, Regex: "Heap.*", Prefix: "go_"},
+				{Action: ActionDrop, Regex: "go_HeapReleased"},
+			},
+			metric:   "HeapReleased",
+			wantKeep: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.rules)
+			assert.NoError(t, err)
+
+			got, keep := p.Relabel(tt.metric)
+			assert.Equal(t, tt.wantKeep, keep)
+			if tt.wantKeep {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "should accept empty rules",
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "should fail on unknown action",
+			rules:   []Rule{{Action: "replace", Regex: ".*"}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on invalid regex",
+			rules:   []Rule{{Action: ActionDrop, Regex: "("}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail without regex",
+			rules:   []Rule{{Action: ActionKeep}},
+			wantErr: assert.Error,
+		},
+		{
+			name:    "should fail on rename without replacement",
+			rules:   []Rule{{Action: ActionRename, Regex: "Alloc"}},
+			wantErr: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.rules)
+			tt.wantErr(t, err)
+		})
+	}
+}
+
+func TestPipeline_Apply(t *testing.T) {
+	p, err := New([]Rule{
+		{Action: ActionDrop, Regex: "MCacheSys"},
+		{Action: ActionAddPrefix, Prefix: "host_"},
+	})
+	assert.NoError(t, err)
+
+	metrics := []metric.UpdatableMetric{
+		metric.NewUpdatableGauge("Alloc", func() float64 { return 1 }),
+		metric.NewUpdatableGauge("MCacheSys", func() float64 { return 2 }),
+	}
+	applied := p.ApplyUpdatable(metrics)
+
+	assert.Len(t, applied, 1)
+	assert.Equal(t, "host_Alloc", applied[0].ID)
+	assert.Equal(t, "Alloc", metrics[0].ID, "source metrics should not change")
+
+	var disabled *Pipeline
+	assert.Equal(t, metrics, disabled.ApplyUpdatable(metrics))
+	assert.Equal(t, []metric.Metric{metric.NewGaugeMetric("Alloc", 1)},
+		disabled.Apply([]metric.Metric{metric.NewGaugeMetric("Alloc", 1)}))
+}
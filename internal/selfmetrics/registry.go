@@ -0,0 +1,211 @@
+// Package selfmetrics собирает метрики о работе самого сервера: количество и длительность запросов,
+// скорость приема метрик, длительность обращений к хранилищу, ошибки проверки подписи и расшифровки.
+// Метрики периодически сохраняются в хранилище сервера наравне с метриками агентов,
+// поэтому отображаются на главной странице и доступны через API
+package selfmetrics
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// HashFailures количество метрик, отклоненных из-за неверной подписи
+	HashFailures = "ServerHashFailures"
+	// DecryptFailures количество запросов, тело которых не удалось расшифровать
+	DecryptFailures = "ServerDecryptFailures"
+	// IngestedMetrics количество метрик, сохраненных в хранилище
+	IngestedMetrics = "ServerIngestedMetrics"
+	// FilteredMetrics количество метрик, отброшенных правилами приема
+	FilteredMetrics = "ServerFilteredMetrics"
+	// OverLimitMetrics количество новых метрик, отброшенных из-за ограничения количества метрик в хранилище
+	OverLimitMetrics = "ServerOverLimitMetrics"
+	// RateLimitedRequests количество запросов, отклоненных из-за превышения частоты запросов
+	RateLimitedRequests = "ServerRateLimitedRequests"
+	// RateLimitedMetrics количество метрик, отклоненных из-за превышения квоты клиента
+	RateLimitedMetrics = "ServerRateLimitedMetrics"
+	// CounterResets количество сбросов накопительных счетчиков, например после перезапуска агента
+	CounterResets = "ServerCounterResets"
+	// StreamDropped количество метрик, не отправленных в поток событий из-за медленного подписчика
+	StreamDropped = "ServerStreamDropped"
+	// IngestionRate количество сохраненных метрик в секунду за последний интервал сброса
+	IngestionRate = "ServerIngestionRate"
+)
+
+// latencyBuckets верхние границы корзин гистограмм длительности в секундах
+var latencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// Registry накапливает метрики сервера между сохранениями в хранилище.
+// Счетчики хранят приращение с прошлого сохранения, так как хранилище суммирует значения счетчиков.
+// Методы безопасно вызывать у nil, тогда метрики не собираются
+type Registry struct {
+	mu        *sync.Mutex
+	repo      storage.Repository
+	logger    zerolog.Logger
+	counters  map[string]int64
+	gauges    map[string]float64
+	lastFlush time.Time
+	now       func() time.Time
+}
+
+// NewRegistry возвращает пустой реестр, метрики которого сохраняются в repo.
+// В repo не должны учитываться обращения к хранилищу, иначе каждое сохранение порождает новые метрики
+func NewRegistry(repo storage.Repository, logger zerolog.Logger) *Registry {
+	return &Registry{
+		mu:        new(sync.Mutex),
+		repo:      repo,
+		logger:    logger,
+		counters:  make(map[string]int64),
+		gauges:    make(map[string]float64),
+		lastFlush: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Inc увеличивает счетчик на единицу
+func (r *Registry) Inc(name string) {
+	r.Add(name, 1)
+}
+
+// Add увеличивает счетчик на n
+func (r *Registry) Add(name string, n int64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counters[sanitizeName(name)] += n
+}
+
+// Observe учитывает длительность в гистограмме. Гистограмма состоит из счетчиков <name>_bucket_le_<граница>
+// с количеством наблюдений не длиннее границы, счетчика <name>_count и суммы длительностей <name>_sum в секундах
+func (r *Registry) Observe(name string, d time.Duration) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name = sanitizeName(name)
+	seconds := d.Seconds()
+	for _, b := range latencyBuckets {
+		bucket := name + "_bucket_le_" + sanitizeName(strconv.FormatFloat(b, 'f', -1, 64))
+		if seconds <= b {
+			r.counters[bucket]++
+		} else if _, ok := r.counters[bucket]; !ok {
+			r.counters[bucket] = 0
+		}
+	}
+	r.counters[name+"_count"]++
+	r.gauges[name+"_sum"] += seconds
+}
+
+// snapshot возвращает накопленные метрики и обнуляет приращения счетчиков
+func (r *Registry) snapshot() []metric.Metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	if elapsed := now.Sub(r.lastFlush).Seconds(); elapsed > 0 {
+		r.gauges[IngestionRate] = float64(r.counters[IngestedMetrics]) / elapsed
+	}
+	r.lastFlush = now
+
+	result := make([]metric.Metric, 0, len(r.counters)+len(r.gauges))
+	for _, name := range sortedKeys(r.counters) {
+		result = append(result, metric.NewCounterMetric(name, r.counters[name]))
+		r.counters[name] = 0
+	}
+	for _, name := range sortedKeys(r.gauges) {
+		result = append(result, metric.NewGaugeMetric(name, r.gauges[name]))
+	}
+	return result
+}
+
+// restore возвращает приращение несохраненного счетчика, чтобы оно попало в следующее сохранение
+func (r *Registry) restore(m metric.Metric) {
+	if m.GetType() != metric.Counter {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counters[m.GetName()] += m.GetCounterValue()
+}
+
+// Flush сохраняет накопленные метрики в хранилище.
+// Метрики сохраняются по одной, чтобы при ошибке вернуть в реестр только несохраненные
+func (r *Registry) Flush(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+
+	var firstErr error
+	for _, m := range r.snapshot() {
+		if err := r.repo.Save(ctx, m); err != nil {
+			r.restore(m)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// Run сохраняет метрики в хранилище с заданным интервалом до отмены контекста
+func (r *Registry) Run(ctx context.Context, interval time.Duration) {
+	if r == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Flush(ctx); err != nil {
+				r.logger.Error().Err(err).Msg("selfmetrics: failed to save server metrics")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sanitizeName оставляет в имени метрики только латинские буквы, цифры и одиночные подчеркивания
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, name)
+	for strings.Contains(name, "__") {
+		name = strings.ReplaceAll(name, "__", "_")
+	}
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return "root"
+	}
+	return name
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
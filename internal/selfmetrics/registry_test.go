@@ -0,0 +1,136 @@
+package selfmetrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/c0dered273/go-adv-metrics/internal/storage/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func findValue(t *testing.T, repo storage.Repository, key metric.Metric) string {
+	m, err := repo.FindByID(context.Background(), key)
+	assert.NoError(t, err)
+	return m.GetStringValue()
+}
+
+func TestRegistry_Flush(t *testing.T) {
+	repo := storage.NewPersistenceRepo(storage.NewMemStorage())
+	reg := NewRegistry(repo, zerolog.Nop())
+	start := time.Now()
+	reg.now = func() time.Time { return start }
+	reg.lastFlush = start.Add(-2 * time.Second)
+
+	instrumented := InstrumentRepository(repo, reg)
+	err := instrumented.SaveAll(context.Background(), []metric.Metric{
+		metric.NewGaugeMetric("Alloc", 1),
+		metric.NewGaugeMetric("Frees", 2),
+	})
+	assert.NoError(t, err)
+	_, err = instrumented.FindByID(context.Background(), metric.NewGaugeMetric("Unknown", 0))
+	assert.Error(t, err)
+	reg.Inc(HashFailures)
+	reg.Observe("ServerHTTPLatency_POST_/update/", 30*time.Millisecond)
+
+	assert.NoError(t, reg.Flush(context.Background()))
+	// Счетчики сохраняются приращениями, поэтому повторное сохранение не меняет их значений
+	assert.NoError(t, reg.Flush(context.Background()))
+
+	tests := []struct {
+		name string
+		key  metric.Metric
+		want string
+	}{
+		{
+			name: "should count ingested metrics",
+			key:  metric.NewCounterMetric(IngestedMetrics, 0),
+			want: "2",
+		},
+		{
+			name: "should compute ingestion rate over flush interval",
+			key:  metric.NewGaugeMetric(IngestionRate, 0),
+			want: "1",
+		},
+		{
+			name: "should count storage errors by operation",
+			key:  metric.NewCounterMetric("ServerStorageErrors_FindByID", 0),
+			want: "1",
+		},
+		{
+			name: "should count storage calls by operation",
+			key:  metric.NewCounterMetric("ServerStorageLatency_SaveAll_count", 0),
+			want: "1",
+		},
+		{
+			name: "should count hash failures",
+			key:  metric.NewCounterMetric(HashFailures, 0),
+			want: "1",
+		},
+		{
+			name: "should not count observation in smaller bucket",
+			key:  metric.NewCounterMetric("ServerHTTPLatency_POST_update_bucket_le_0_01", 0),
+			want: "0",
+		},
+		{
+			name: "should count observation in bucket",
+			key:  metric.NewCounterMetric("ServerHTTPLatency_POST_update_bucket_le_0_05", 0),
+			want: "1",
+		},
+		{
+			name: "should sum observations",
+			key:  metric.NewGaugeMetric("ServerHTTPLatency_POST_update_sum", 0),
+			want: "0.03",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, findValue(t, repo, tt.key))
+		})
+	}
+}
+
+func TestRegistry_IngestionRate(t *testing.T) {
+	reg := NewRegistry(storage.NewPersistenceRepo(storage.NewMemStorage()), zerolog.Nop())
+	start := time.Now()
+	reg.now = func() time.Time { return start }
+	reg.lastFlush = start.Add(-2 * time.Second)
+	reg.Add(IngestedMetrics, 10)
+
+	assert.NoError(t, reg.Flush(context.Background()))
+	assert.Equal(t, "5", findValue(t, reg.repo, metric.NewGaugeMetric(IngestionRate, 0)))
+}
+
+func TestRegistry_FlushError(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	failing := mocks.NewMockRepository(ctrl)
+	failing.EXPECT().Save(gomock.Any(), gomock.Any()).Return(errors.New("connection refused")).AnyTimes()
+
+	reg := NewRegistry(failing, zerolog.Nop())
+	reg.Add(HashFailures, 3)
+	assert.Error(t, reg.Flush(context.Background()))
+
+	// Несохраненное приращение попадает в следующее сохранение
+	repo := storage.NewPersistenceRepo(storage.NewMemStorage())
+	reg.repo = repo
+	reg.Inc(HashFailures)
+	assert.NoError(t, reg.Flush(context.Background()))
+	assert.Equal(t, "4", findValue(t, repo, metric.NewCounterMetric(HashFailures, 0)))
+}
+
+func TestRegistry_Disabled(t *testing.T) {
+	var reg *Registry
+	reg.Inc(HashFailures)
+	reg.Observe("ServerHTTPLatency", time.Second)
+	assert.NoError(t, reg.Flush(context.Background()))
+
+	repo := storage.NewMemStorage()
+	assert.Same(t, repo, InstrumentRepository(repo, nil))
+}
@@ -0,0 +1,73 @@
+package selfmetrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+)
+
+// instrumentedRepository учитывает длительность и ошибки обращений к хранилищу.
+// Для каждой операции ведутся гистограмма ServerStorageLatency_<операция> и счетчик ServerStorageErrors_<операция>,
+// ненайденная метрика тоже считается ошибкой FindByID
+type instrumentedRepository struct {
+	storage.Repository
+	registry *Registry
+}
+
+// InstrumentRepository возвращает хранилище, обращения к которому учитываются в реестре метрик сервера
+func InstrumentRepository(repo storage.Repository, registry *Registry) storage.Repository {
+	if registry == nil {
+		return repo
+	}
+	return &instrumentedRepository{Repository: repo, registry: registry}
+}
+
+func (i *instrumentedRepository) Save(ctx context.Context, m metric.Metric) error {
+	start := time.Now()
+	err := i.Repository.Save(ctx, m)
+	i.observe("Save", start, err)
+	if err == nil {
+		i.registry.Inc(IngestedMetrics)
+	}
+	return err
+}
+
+func (i *instrumentedRepository) SaveAll(ctx context.Context, metrics []metric.Metric) error {
+	start := time.Now()
+	err := i.Repository.SaveAll(ctx, metrics)
+	i.observe("SaveAll", start, err)
+	if err == nil {
+		i.registry.Add(IngestedMetrics, int64(len(metrics)))
+	}
+	return err
+}
+
+func (i *instrumentedRepository) FindByID(ctx context.Context, key metric.Metric) (metric.Metric, error) {
+	start := time.Now()
+	m, err := i.Repository.FindByID(ctx, key)
+	i.observe("FindByID", start, err)
+	return m, err
+}
+
+func (i *instrumentedRepository) FindAll(ctx context.Context) ([]metric.Metric, error) {
+	start := time.Now()
+	m, err := i.Repository.FindAll(ctx)
+	i.observe("FindAll", start, err)
+	return m, err
+}
+
+func (i *instrumentedRepository) Ping() error {
+	start := time.Now()
+	err := i.Repository.Ping()
+	i.observe("Ping", start, err)
+	return err
+}
+
+func (i *instrumentedRepository) observe(op string, start time.Time, err error) {
+	i.registry.Observe("ServerStorageLatency_"+op, time.Since(start))
+	if err != nil {
+		i.registry.Inc("ServerStorageErrors_" + op)
+	}
+}
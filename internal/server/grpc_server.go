@@ -1,9 +1,7 @@
 package server
 
 import (
-	"crypto/tls"
-	"crypto/x509"
-	"os"
+	"errors"
 
 	"github.com/c0dered273/go-adv-metrics/internal/config"
 	"github.com/c0dered273/go-adv-metrics/internal/interceptors"
@@ -14,43 +12,34 @@ import (
 	"google.golang.org/grpc/credentials"
 )
 
+// newServerCredentials возвращает TLS credentials, сертификаты в которых обновляются без перезапуска сервера
 func newServerCredentials(cfg *config.ServerConfig) (credentials.TransportCredentials, error) {
-	caPem, err := os.ReadFile(cfg.CACertFile)
-	if err != nil {
-		cfg.Logger.Error().Err(err).Msg("server: error reading CA certificate")
-		return nil, err
-	}
-
-	certPool := x509.NewCertPool()
-	if !certPool.AppendCertsFromPEM(caPem) {
-		cfg.Logger.Error().Msg("server: error loading CA to cert pool")
-		return nil, err
+	certs := cfg.GetCertStore()
+	if certs == nil {
+		return nil, errors.New("server: certificates are not loaded")
 	}
 
-	serverCert, err := tls.LoadX509KeyPair(cfg.ServerCertFile, cfg.ServerKeyFile)
-	if err != nil {
-		cfg.Logger.Error().Err(err).Msg("server: error reading server key pair")
-		return nil, err
-	}
-
-	tlsConf := &tls.Config{
-		Certificates: []tls.Certificate{serverCert},
-		ClientAuth:   tls.VerifyClientCertIfGiven,
-		ClientCAs:    certPool,
-		MinVersion:   tls.VersionTLS13,
-	}
-
-	return credentials.NewTLS(tlsConf), nil
+	return credentials.NewTLS(certs.TLSConfig()), nil
 }
 
 func newGRPCServerOptions(cfg *config.ServerConfig) ([]grpc.ServerOption, error) {
 	opts := []grpc.ServerOption{
 		grpc.ChainUnaryInterceptor(
+			interceptors.MetricsUnaryServerInterceptor(cfg),
 			interceptors.RealIPUnaryServerInterceptor(),
 			interceptors.TrustedSubnetUnaryServerInterceptor(cfg),
+			interceptors.RateLimitUnaryServerInterceptor(cfg),
 			logging.UnaryServerInterceptor(interceptors.InterceptorLogger(cfg.Logger), interceptors.GetLoggerOpts()...),
 			recovery.UnaryServerInterceptor(interceptors.GetRecoveryOpts()...),
 		),
+		grpc.ChainStreamInterceptor(
+			interceptors.MetricsStreamServerInterceptor(cfg),
+			interceptors.RealIPStreamServerInterceptor(),
+			interceptors.TrustedSubnetStreamServerInterceptor(cfg),
+			interceptors.RateLimitStreamServerInterceptor(cfg),
+			logging.StreamServerInterceptor(interceptors.InterceptorLogger(cfg.Logger), interceptors.GetLoggerOpts()...),
+			recovery.StreamServerInterceptor(interceptors.GetRecoveryOpts()...),
+		),
 	}
 
 	if cfg.IsTLSEnabled {
@@ -75,6 +64,12 @@ func NewGRPCServer(cfg *config.ServerConfig) (*grpc.Server, error) {
 	service.RegisterMetricsServiceServer(grpcServer, &service.MetricsService{
 		Config: cfg,
 	})
+	service.RegisterAgentConfigServiceServer(grpcServer, &service.AgentConfigService{
+		Config: cfg,
+	})
+	service.RegisterAgentRegistryServiceServer(grpcServer, &service.AgentRegistryService{
+		Config: cfg,
+	})
 
 	return grpcServer, err
 }
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var (
+	_ AgentConfigServiceServer = (*AgentConfigService)(nil)
+)
+
+// AgentConfigService раздает агентам настройки их группы из файла конфигурации сервера
+type AgentConfigService struct {
+	UnimplementedAgentConfigServiceServer
+	Config *config.ServerConfig
+}
+
+func (s *AgentConfigService) GetConfig(ctx context.Context, in *model.AgentConfigRequest) (*model.AgentRemoteConfig, error) {
+	group, groupCfg, ok := s.Config.GetAgentGroups().Find(in.GetGroup())
+	if !ok {
+		msg := fmt.Sprintf("agent_config_service: configuration for group %s not found", in.GetGroup())
+		s.Config.Logger.Error().Str("hostname", in.GetHostname()).Msg(msg)
+		return nil, status.Error(codes.NotFound, msg)
+	}
+
+	return ToRemoteConfig(group, groupCfg), nil
+}
+
+// ToRemoteConfig преобразует настройки группы агентов в ответ сервиса
+func ToRemoteConfig(group string, g config.AgentGroupConfig) *model.AgentRemoteConfig {
+	remote := &model.AgentRemoteConfig{
+		Group:                  group,
+		Version:                g.Version(),
+		Collectors:             g.Collectors,
+		DiskMountPointsInclude: g.DiskMountPointsInclude,
+		DiskMountPointsExclude: g.DiskMountPointsExclude,
+		DiskFsTypesInclude:     g.DiskFSTypesInclude,
+		DiskFsTypesExclude:     g.DiskFSTypesExclude,
+		NetInterfacesInclude:   g.NetInterfacesInclude,
+		NetInterfacesExclude:   g.NetInterfacesExclude,
+	}
+	for _, r := range g.RelabelRules {
+		remote.RelabelRules = append(remote.RelabelRules, &model.RelabelRule{
+			Action:      r.Action,
+			Regex:       r.Regex,
+			Replacement: r.Replacement,
+			Prefix:      r.Prefix,
+		})
+	}
+	if g.PollInterval > 0 {
+		remote.PollInterval = durationpb.New(g.PollInterval)
+	}
+	if g.ReportInterval > 0 {
+		remote.ReportInterval = durationpb.New(g.ReportInterval)
+	}
+	return remote
+}
@@ -0,0 +1,110 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.12
+// source: agent_config_service.proto
+
+package service
+
+import (
+	context "context"
+	model "github.com/c0dered273/go-adv-metrics/internal/model"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	AgentConfigService_GetConfig_FullMethodName = "/proto.AgentConfigService/GetConfig"
+)
+
+// AgentConfigServiceClient is the client API for AgentConfigService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AgentConfigServiceClient interface {
+	GetConfig(ctx context.Context, in *model.AgentConfigRequest, opts ...grpc.CallOption) (*model.AgentRemoteConfig, error)
+}
+
+type agentConfigServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAgentConfigServiceClient(cc grpc.ClientConnInterface) AgentConfigServiceClient {
+	return &agentConfigServiceClient{cc}
+}
+
+func (c *agentConfigServiceClient) GetConfig(ctx context.Context, in *model.AgentConfigRequest, opts ...grpc.CallOption) (*model.AgentRemoteConfig, error) {
+	out := new(model.AgentRemoteConfig)
+	err := c.cc.Invoke(ctx, AgentConfigService_GetConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AgentConfigServiceServer is the server API for AgentConfigService service.
+// All implementations must embed UnimplementedAgentConfigServiceServer
+// for forward compatibility
+type AgentConfigServiceServer interface {
+	GetConfig(context.Context, *model.AgentConfigRequest) (*model.AgentRemoteConfig, error)
+	mustEmbedUnimplementedAgentConfigServiceServer()
+}
+
+// UnimplementedAgentConfigServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAgentConfigServiceServer struct {
+}
+
+func (UnimplementedAgentConfigServiceServer) GetConfig(context.Context, *model.AgentConfigRequest) (*model.AgentRemoteConfig, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConfig not implemented")
+}
+func (UnimplementedAgentConfigServiceServer) mustEmbedUnimplementedAgentConfigServiceServer() {}
+
+// UnsafeAgentConfigServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AgentConfigServiceServer will
+// result in compilation errors.
+type UnsafeAgentConfigServiceServer interface {
+	mustEmbedUnimplementedAgentConfigServiceServer()
+}
+
+func RegisterAgentConfigServiceServer(s grpc.ServiceRegistrar, srv AgentConfigServiceServer) {
+	s.RegisterService(&AgentConfigService_ServiceDesc, srv)
+}
+
+func _AgentConfigService_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(model.AgentConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentConfigServiceServer).GetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentConfigService_GetConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentConfigServiceServer).GetConfig(ctx, req.(*model.AgentConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AgentConfigService_ServiceDesc is the grpc.ServiceDesc for AgentConfigService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AgentConfigService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.AgentConfigService",
+	HandlerType: (*AgentConfigServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetConfig",
+			Handler:    _AgentConfigService_GetConfig_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "agent_config_service.proto",
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"net"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/fleet"
+	"github.com/c0dered273/go-adv-metrics/internal/model"
+	"github.com/c0dered273/go-adv-metrics/internal/selfmetrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	_ AgentRegistryServiceServer = (*AgentRegistryService)(nil)
+)
+
+// AgentRegistryService принимает heartbeat агентов и сохраняет их в реестре агентов
+type AgentRegistryService struct {
+	UnimplementedAgentRegistryServiceServer
+	Config *config.ServerConfig
+}
+
+func (s *AgentRegistryService) Heartbeat(ctx context.Context, in *model.AgentHeartbeat) (*model.Status, error) {
+	heartbeat := FromPbHeartbeat(in)
+	if !heartbeat.IsValid() {
+		msg := "agent_registry_service: invalid heartbeat"
+		s.Config.Logger.Error().Msgf("%s: %v", msg, heartbeat)
+		return nil, status.Error(codes.InvalidArgument, msg)
+	}
+
+	ok, err := heartbeat.CheckHash(s.Config.GetKey())
+	if err != nil {
+		s.Config.Logger.Error().Err(err).Msg("agent_registry_service: failed to check heartbeat hash")
+		return nil, status.Error(codes.InvalidArgument, "agent_registry_service: invalid heartbeat hash")
+	}
+	if !ok {
+		s.Config.SelfMetrics.Inc(selfmetrics.HashFailures)
+		msg := "agent_registry_service: invalid heartbeat hash"
+		s.Config.Logger.Error().Str("hostname", heartbeat.Hostname).Msg(msg)
+		return nil, status.Error(codes.InvalidArgument, msg)
+	}
+
+	var address string
+	if p, ok := peer.FromContext(ctx); ok {
+		address = p.Addr.String()
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			address = host
+		}
+	}
+	s.Config.Agents.Heartbeat(heartbeat, address)
+
+	return &model.Status{Code: 0, Message: "OK"}, nil
+}
+
+// ToPbHeartbeat преобразует heartbeat агента в сообщение gRPC
+func ToPbHeartbeat(h fleet.Heartbeat) *model.AgentHeartbeat {
+	return &model.AgentHeartbeat{
+		Hostname:      h.Hostname,
+		Group:         h.Group,
+		Version:       h.Version,
+		Commit:        h.Commit,
+		Os:            h.OS,
+		UptimeSeconds: h.UptimeSeconds,
+		Hash:          h.Hash,
+	}
+}
+
+// FromPbHeartbeat преобразует сообщение gRPC в heartbeat агента
+func FromPbHeartbeat(h *model.AgentHeartbeat) fleet.Heartbeat {
+	return fleet.Heartbeat{
+		Hostname:      h.GetHostname(),
+		Group:         h.GetGroup(),
+		Version:       h.GetVersion(),
+		Commit:        h.GetCommit(),
+		OS:            h.GetOs(),
+		UptimeSeconds: h.GetUptimeSeconds(),
+		Hash:          h.GetHash(),
+	}
+}
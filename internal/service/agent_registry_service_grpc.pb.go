@@ -0,0 +1,110 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.12
+// source: agent_registry_service.proto
+
+package service
+
+import (
+	context "context"
+	model "github.com/c0dered273/go-adv-metrics/internal/model"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	AgentRegistryService_Heartbeat_FullMethodName = "/proto.AgentRegistryService/Heartbeat"
+)
+
+// AgentRegistryServiceClient is the client API for AgentRegistryService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AgentRegistryServiceClient interface {
+	Heartbeat(ctx context.Context, in *model.AgentHeartbeat, opts ...grpc.CallOption) (*model.Status, error)
+}
+
+type agentRegistryServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAgentRegistryServiceClient(cc grpc.ClientConnInterface) AgentRegistryServiceClient {
+	return &agentRegistryServiceClient{cc}
+}
+
+func (c *agentRegistryServiceClient) Heartbeat(ctx context.Context, in *model.AgentHeartbeat, opts ...grpc.CallOption) (*model.Status, error) {
+	out := new(model.Status)
+	err := c.cc.Invoke(ctx, AgentRegistryService_Heartbeat_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AgentRegistryServiceServer is the server API for AgentRegistryService service.
+// All implementations must embed UnimplementedAgentRegistryServiceServer
+// for forward compatibility
+type AgentRegistryServiceServer interface {
+	Heartbeat(context.Context, *model.AgentHeartbeat) (*model.Status, error)
+	mustEmbedUnimplementedAgentRegistryServiceServer()
+}
+
+// UnimplementedAgentRegistryServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAgentRegistryServiceServer struct {
+}
+
+func (UnimplementedAgentRegistryServiceServer) Heartbeat(context.Context, *model.AgentHeartbeat) (*model.Status, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Heartbeat not implemented")
+}
+func (UnimplementedAgentRegistryServiceServer) mustEmbedUnimplementedAgentRegistryServiceServer() {}
+
+// UnsafeAgentRegistryServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AgentRegistryServiceServer will
+// result in compilation errors.
+type UnsafeAgentRegistryServiceServer interface {
+	mustEmbedUnimplementedAgentRegistryServiceServer()
+}
+
+func RegisterAgentRegistryServiceServer(s grpc.ServiceRegistrar, srv AgentRegistryServiceServer) {
+	s.RegisterService(&AgentRegistryService_ServiceDesc, srv)
+}
+
+func _AgentRegistryService_Heartbeat_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(model.AgentHeartbeat)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentRegistryServiceServer).Heartbeat(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AgentRegistryService_Heartbeat_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentRegistryServiceServer).Heartbeat(ctx, req.(*model.AgentHeartbeat))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AgentRegistryService_ServiceDesc is the grpc.ServiceDesc for AgentRegistryService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AgentRegistryService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "proto.AgentRegistryService",
+	HandlerType: (*AgentRegistryServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Heartbeat",
+			Handler:    _AgentRegistryService_Heartbeat_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "agent_registry_service.proto",
+}
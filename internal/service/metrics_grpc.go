@@ -2,13 +2,16 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/interceptors"
 	"github.com/c0dered273/go-adv-metrics/internal/metric"
 	"github.com/c0dered273/go-adv-metrics/internal/model"
+	"github.com/c0dered273/go-adv-metrics/internal/selfmetrics"
+	"github.com/c0dered273/go-adv-metrics/internal/stream"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
@@ -38,14 +41,7 @@ func (ms *MetricsService) Get(ctx context.Context, in *model.GetMetricRequest) (
 		return nil, status.Errorf(codes.NotFound, msg)
 	}
 
-	pbMetric := &model.Metric{}
-	err = mapDtoWithSerialization(m, pbMetric)
-	if err != nil {
-		ms.Config.Logger.Error().Err(err).Send()
-		return nil, status.Errorf(codes.Internal, "Internal error")
-	}
-
-	response.Metric = pbMetric
+	response.Metric = ToPbMetric(m)
 	return &response, nil
 }
 
@@ -58,25 +54,16 @@ func (ms *MetricsService) GetAll(ctx context.Context, empty *emptypb.Empty) (*mo
 		return nil, status.Errorf(codes.Internal, "Internal error")
 	}
 
-	pbMetrics := make([]*model.Metric, len(m))
-	err = MapSliceWithSerialization(ToSliceOfPointers(m), pbMetrics)
-	if err != nil {
-		ms.Config.Logger.Error().Err(err).Send()
-		return nil, status.Errorf(codes.Internal, "Internal error")
-	}
-
-	response.Metrics = pbMetrics
+	response.Metrics = ToPbMetrics(m)
 	return &response, nil
 }
 
 func (ms *MetricsService) Save(ctx context.Context, in *model.Metric) (*model.Status, error) {
 	var response model.Status
 
-	m := metric.Metric{}
-	err := mapDtoWithSerialization(in, &m)
+	m, err := FromPbMetric(in)
 	if err != nil {
-		ms.Config.Logger.Error().Err(err).Send()
-		return nil, status.Errorf(codes.Internal, "Internal error")
+		return nil, invalidMetricType(err, ms.Config)
 	}
 
 	err = validateMetric(m, ms.Config)
@@ -84,7 +71,12 @@ func (ms *MetricsService) Save(ctx context.Context, in *model.Metric) (*model.St
 		return nil, err
 	}
 
-	err = ms.Config.Repo.Save(ctx, m)
+	err = allowMetrics(ctx, ms.Config, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	err = StoreMetrics(ctx, ms.Config, interceptors.SourceKey(ctx), []metric.Metric{m})
 	if err != nil {
 		ms.Config.Logger.Error().Err(err).Send()
 		return nil, status.Errorf(codes.Internal, "Internal error")
@@ -97,78 +89,203 @@ func (ms *MetricsService) Save(ctx context.Context, in *model.Metric) (*model.St
 
 func (ms *MetricsService) SaveAll(ctx context.Context, in *model.Metrics) (*model.Status, error) {
 	var response model.Status
-	pbMetrics := in.GetMetrics()
 
-	m := make([]*metric.Metric, len(pbMetrics))
-	err := MapSliceWithSerialization(pbMetrics, m)
+	err := ms.saveAll(ctx, in.GetMetrics())
 	if err != nil {
-		ms.Config.Logger.Error().Err(err).Send()
-		return nil, status.Errorf(codes.Internal, "Internal error")
+		return nil, err
+	}
+
+	response.Code = 0
+	response.Message = "OK"
+	return &response, nil
+}
+
+// SaveStream сохраняет корзины метрик из потока клиента. На каждую корзину отправляется подтверждение с ее номером
+// и кодом результата, ошибка в одной корзине не закрывает поток
+func (ms *MetricsService) SaveStream(srv MetricsService_SaveStreamServer) error {
+	ctx := srv.Context()
+	for {
+		batch, err := srv.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		ack := &model.SaveAck{Seq: batch.GetSeq(), Message: "OK"}
+		if err = ms.saveAll(ctx, batch.GetMetrics()); err != nil {
+			st := status.Convert(err)
+			ack.Code = int32(st.Code())
+			ack.Message = st.Message()
+		}
+		if err = srv.Send(ack); err != nil {
+			return err
+		}
+	}
+}
+
+// saveAll проверяет и сохраняет корзину метрик, возвращает ошибку со статусом gRPC
+func (ms *MetricsService) saveAll(ctx context.Context, pbMetrics []*model.Metric) error {
+	m, err := FromPbMetrics(pbMetrics)
+	if err != nil {
+		return invalidMetricType(err, ms.Config)
 	}
 
 	for _, mt := range m {
-		err = validateMetric(*mt, ms.Config)
+		err = validateMetric(mt, ms.Config)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
 
-	err = ms.Config.Repo.SaveAll(ctx, toSliceOfValues(m))
+	err = allowMetrics(ctx, ms.Config, len(m))
+	if err != nil {
+		return err
+	}
+
+	err = StoreMetrics(ctx, ms.Config, interceptors.SourceKey(ctx), m)
 	if err != nil {
 		ms.Config.Logger.Error().Err(err).Send()
-		return nil, status.Errorf(codes.Internal, "Internal error")
+		return status.Errorf(codes.Internal, "Internal error")
 	}
 
-	response.Code = 0
-	response.Message = "OK"
-	return &response, nil
+	return nil
 }
 
-func MapSliceWithSerialization[T any, E any](in []*T, out []*E) error {
-	if len(in) != len(out) {
-		return errors.New("mapping error: slices must have equal length")
+// Watch отправляет клиенту сохраняемые метрики, пока клиент не отменит вызов.
+// Если клиент не успевает принимать метрики, часть из них пропускается
+func (ms *MetricsService) Watch(in *model.WatchRequest, srv MetricsService_WatchServer) error {
+	filter, err := watchFilter(in)
+	if err != nil {
+		ms.Config.Logger.Error().Err(err).Msg("metric_service: invalid watch request")
+		return status.Error(codes.InvalidArgument, err.Error())
 	}
 
-	for i := range in {
-		pb := new(E)
-		err := mapDtoWithSerialization(in[i], pb)
+	sub := ms.Config.Stream.Subscribe(filter, stream.DefaultBuffer)
+	defer ms.Config.Stream.Unsubscribe(sub)
+
+	for {
+		select {
+		case m, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "metric_service: server is shutting down")
+			}
+			if n := sub.Dropped(); n > 0 {
+				ms.Config.SelfMetrics.Add(selfmetrics.StreamDropped, n)
+				ms.Config.Logger.Warn().Int64("dropped", n).Msg("metric_service: watch client is too slow, metrics dropped")
+			}
+
+			if err = srv.Send(ToPbMetric(m)); err != nil {
+				return err
+			}
+		case <-srv.Context().Done():
+			return nil
+		}
+	}
+}
+
+// watchFilter возвращает фильтр метрик по ID и типу из запроса
+func watchFilter(in *model.WatchRequest) (stream.Filter, error) {
+	ids := make(map[string]struct{}, len(in.GetIds()))
+	for _, id := range in.GetIds() {
+		ids[id] = struct{}{}
+	}
+
+	var mType metric.Type
+	if in.GetType() != "" {
+		var err error
+		mType, err = metric.NewType(in.GetType())
 		if err != nil {
-			return err
+			return nil, err
 		}
-		out[i] = pb
 	}
 
-	return nil
+	return func(m metric.Metric) bool {
+		if len(ids) > 0 {
+			if _, ok := ids[m.ID]; !ok {
+				return false
+			}
+		}
+		return in.GetType() == "" || m.GetType() == mType
+	}, nil
 }
 
-func mapDtoWithSerialization[T any, E any](in T, out *E) error {
-	b, err := json.Marshal(&in)
-	if err != nil {
-		return err
+// ToPbMetric преобразует метрику в сообщение gRPC. Значения не копируются, сообщение ссылается на них
+func ToPbMetric(m metric.Metric) *model.Metric {
+	return &model.Metric{
+		Id:         m.ID,
+		Type:       m.MType.String(),
+		Delta:      m.Delta,
+		Value:      m.Val,
+		Hash:       m.Hash,
+		Cumulative: m.Cumulative,
+	}
+}
+
+// ToPbMetrics преобразует метрики в сообщения gRPC. Сообщения размещаются в одном массиве,
+// чтобы большая корзина не требовала отдельного выделения памяти на каждую метрику
+func ToPbMetrics(metrics []metric.Metric) []*model.Metric {
+	buf := make([]model.Metric, len(metrics))
+	out := make([]*model.Metric, len(metrics))
+	for i, m := range metrics {
+		pb := &buf[i]
+		pb.Id = m.ID
+		pb.Type = m.MType.String()
+		pb.Delta = m.Delta
+		pb.Value = m.Val
+		pb.Hash = m.Hash
+		pb.Cumulative = m.Cumulative
+		out[i] = pb
 	}
+	return out
+}
 
-	err = json.Unmarshal(b, out)
+// FromPbMetric преобразует сообщение gRPC в метрику, возвращает ошибку при неизвестном типе метрики
+func FromPbMetric(in *model.Metric) (metric.Metric, error) {
+	mType, err := metric.NewType(in.GetType())
 	if err != nil {
-		return err
+		return metric.Metric{}, err
 	}
 
-	return nil
+	return metric.Metric{
+		ID:         in.GetId(),
+		MType:      mType,
+		Delta:      in.Delta,
+		Val:        in.Value,
+		Hash:       in.GetHash(),
+		Cumulative: in.GetCumulative(),
+	}, nil
 }
 
-func ToSliceOfPointers[T any](in []T) []*T {
-	out := make([]*T, len(in))
+// FromPbMetrics преобразует сообщения gRPC в метрики
+func FromPbMetrics(in []*model.Metric) ([]metric.Metric, error) {
+	out := make([]metric.Metric, len(in))
 	for i := range in {
-		out[i] = &in[i]
+		m, err := FromPbMetric(in[i])
+		if err != nil {
+			return nil, err
+		}
+		out[i] = m
 	}
-	return out
+	return out, nil
 }
 
-func toSliceOfValues[T any](in []*T) []T {
-	out := make([]T, len(in))
-	for i := range in {
-		out[i] = *in[i]
+func invalidMetricType(err error, cfg *config.ServerConfig) error {
+	msg := "metric_service: " + err.Error()
+	cfg.Logger.Error().Msg(msg)
+	return status.Error(codes.InvalidArgument, msg)
+}
+
+// allowMetrics проверяет квоту клиента на количество принимаемых метрик
+func allowMetrics(ctx context.Context, cfg *config.ServerConfig, n int) error {
+	key := interceptors.ClientKey(ctx, cfg)
+	if ok, retryAfter := cfg.RateLimiter.AllowMetrics(key, n); !ok {
+		cfg.SelfMetrics.Add(selfmetrics.RateLimitedMetrics, int64(n))
+		cfg.Logger.Warn().Str("client", key).Msg("metric_service: metrics quota exceeded")
+		return interceptors.ResourceExhausted("metric_service: metrics quota exceeded", retryAfter)
 	}
-	return out
+	return nil
 }
 
 func validateMetric(m metric.Metric, cfg *config.ServerConfig) error {
@@ -178,12 +295,13 @@ func validateMetric(m metric.Metric, cfg *config.ServerConfig) error {
 		return status.Errorf(codes.InvalidArgument, msg)
 	}
 
-	ok, err := m.CheckHash(cfg.Key)
+	ok, err := m.CheckHash(cfg.GetKey())
 	if err != nil {
 		cfg.Logger.Error().Err(err).Send()
 		return status.Errorf(codes.Internal, "Internal error")
 	}
 	if !ok {
+		cfg.SelfMetrics.Inc(selfmetrics.HashFailures)
 		msg := "metric_service: invalid metric hash"
 		cfg.Logger.Error().Err(err).Msg(msg)
 		return status.Errorf(codes.InvalidArgument, msg)
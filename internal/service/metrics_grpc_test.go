@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/model"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/c0dered273/go-adv-metrics/internal/stream"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestMetricsClient(t *testing.T, cfg *config.ServerConfig) MetricsServiceClient {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	RegisterMetricsServiceServer(server, &MetricsService{Config: cfg})
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return NewMetricsServiceClient(conn)
+}
+
+func TestMetricsService_Watch(t *testing.T) {
+	cfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{},
+		Logger:         zerolog.Nop(),
+		Repo:           storage.NewPersistenceRepo(storage.NewMemStorage()),
+		Stream:         stream.NewHub(),
+	}
+	client := newTestMetricsClient(t, cfg)
+
+	t.Run("should reject unknown type", func(t *testing.T) {
+		watch, err := client.Watch(context.Background(), &model.WatchRequest{Type: "histogram"})
+		assert.NoError(t, err)
+		_, err = watch.Recv()
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("should send matching metrics", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		watch, err := client.Watch(ctx, &model.WatchRequest{Ids: []string{"PollCount", "Alloc"}, Type: "counter"})
+		assert.NoError(t, err)
+
+		// Подписка создается на сервере после открытия потока, поэтому метрики сохраняются, пока не придет первая
+		go func() {
+			delta := int64(5)
+			value := 1.5
+			ticker := time.NewTicker(10 * time.Millisecond)
+			defer ticker.Stop()
+			for {
+				_, _ = client.SaveAll(ctx, &model.Metrics{Metrics: []*model.Metric{
+					{Id: "Alloc", Type: "gauge", Value: &value},
+					{Id: "RandomCounter", Type: "counter", Delta: &delta},
+					{Id: "PollCount", Type: "counter", Delta: &delta},
+				}})
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		got, err := watch.Recv()
+		if assert.NoError(t, err) {
+			assert.Equal(t, "PollCount", got.GetId())
+			assert.Equal(t, "counter", got.GetType())
+			// Счетчик приходит с сохраненным значением, которое накопилось до подписки
+			assert.Positive(t, got.GetDelta())
+			assert.Zero(t, got.GetDelta()%5)
+		}
+	})
+}
+
+func TestMetricsService_SaveStream(t *testing.T) {
+	repo := storage.NewPersistenceRepo(storage.NewMemStorage())
+	cfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{},
+		Logger:         zerolog.Nop(),
+		Repo:           repo,
+	}
+	client := newTestMetricsClient(t, cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	saveStream, err := client.SaveStream(ctx)
+	assert.NoError(t, err)
+
+	delta := int64(5)
+	value := 1.5
+	batches := []*model.MetricBatch{
+		{Seq: 1, Metrics: []*model.Metric{{Id: "PollCount", Type: "counter", Delta: &delta}}},
+		{Seq: 2, Metrics: []*model.Metric{{Id: "Alloc", Type: "counter", Value: &value}}},
+		{Seq: 3, Metrics: []*model.Metric{{Id: "Alloc", Type: "gauge", Value: &value}}},
+	}
+	wantCodes := []codes.Code{codes.OK, codes.InvalidArgument, codes.OK}
+	for i, batch := range batches {
+		assert.NoError(t, saveStream.Send(batch))
+		ack, err := saveStream.Recv()
+		if assert.NoError(t, err) {
+			assert.Equal(t, batch.GetSeq(), ack.GetSeq())
+			assert.Equal(t, wantCodes[i], codes.Code(ack.GetCode()), ack.GetMessage())
+		}
+	}
+	assert.NoError(t, saveStream.CloseSend())
+	_, err = saveStream.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+
+	saved, err := repo.FindAll(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, saved, 2)
+}
+
+// jsonToPbMetric прежнее преобразование через json, используется для сравнения в тестах и бенчмарках
+func jsonToPbMetric(m metric.Metric) (*model.Metric, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	pb := &model.Metric{}
+	return pb, json.Unmarshal(b, pb)
+}
+
+func jsonFromPbMetric(pb *model.Metric) (metric.Metric, error) {
+	b, err := json.Marshal(pb)
+	if err != nil {
+		return metric.Metric{}, err
+	}
+	var m metric.Metric
+	return m, json.Unmarshal(b, &m)
+}
+
+func TestMetricConversion(t *testing.T) {
+	hashed := metric.NewGaugeMetric("Alloc", 3.14)
+	hashed.SetHash("secret")
+	tests := []struct {
+		name   string
+		metric metric.Metric
+	}{
+		{name: "gauge", metric: metric.NewGaugeMetric("Alloc", 1.5)},
+		{name: "zero gauge", metric: metric.NewGaugeMetric("Alloc", 0)},
+		{name: "counter", metric: metric.NewCounterMetric("PollCount", 5)},
+		{name: "zero counter", metric: metric.NewCounterMetric("PollCount", 0)},
+		{name: "cumulative counter", metric: metric.NewCumulativeCounterMetric("PollCount", 100)},
+		{name: "signed metric", metric: hashed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pb := ToPbMetric(tt.metric)
+			want, err := jsonToPbMetric(tt.metric)
+			assert.NoError(t, err)
+			assert.True(t, proto.Equal(want, pb), "got %v, want %v", pb, want)
+
+			got, err := FromPbMetric(pb)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.metric, got)
+
+			wantMetric, err := jsonFromPbMetric(pb)
+			assert.NoError(t, err)
+			assert.Equal(t, wantMetric, got)
+
+			pbs := ToPbMetrics([]metric.Metric{tt.metric, tt.metric})
+			gotAll, err := FromPbMetrics(pbs)
+			assert.NoError(t, err)
+			assert.Equal(t, []metric.Metric{tt.metric, tt.metric}, gotAll)
+		})
+	}
+
+	_, err := FromPbMetrics([]*model.Metric{{Id: "Alloc", Type: "histogram"}})
+	assert.Error(t, err)
+}
+
+func benchmarkMetrics(n int) []metric.Metric {
+	metrics := make([]metric.Metric, n)
+	for i := range metrics {
+		if i%2 == 0 {
+			metrics[i] = metric.NewGaugeMetric("Gauge"+strconv.Itoa(i), float64(i))
+		} else {
+			metrics[i] = metric.NewCumulativeCounterMetric("Counter"+strconv.Itoa(i), int64(i))
+		}
+		metrics[i].SetHash("secret")
+	}
+	return metrics
+}
+
+func BenchmarkToPbMetrics(b *testing.B) {
+	metrics := benchmarkMetrics(10000)
+
+	b.Run("typed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = ToPbMetrics(metrics)
+		}
+	})
+	b.Run("json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			out := make([]*model.Metric, len(metrics))
+			for j := range metrics {
+				out[j], _ = jsonToPbMetric(metrics[j])
+			}
+		}
+	})
+}
+
+func BenchmarkFromPbMetrics(b *testing.B) {
+	pbMetrics := ToPbMetrics(benchmarkMetrics(10000))
+
+	b.Run("typed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = FromPbMetrics(pbMetrics)
+		}
+	})
+	b.Run("json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			out := make([]metric.Metric, len(pbMetrics))
+			for j := range pbMetrics {
+				out[j], _ = jsonFromPbMetric(pbMetrics[j])
+			}
+		}
+	})
+}
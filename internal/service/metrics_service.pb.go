@@ -28,7 +28,7 @@ var file_metrics_service_proto_rawDesc = []byte{
 	0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x05, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x1b,
 	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f,
 	0x65, 0x6d, 0x70, 0x74, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x1a, 0x0c, 0x6d, 0x65, 0x74,
-	0x72, 0x69, 0x63, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x32, 0xda, 0x01, 0x0a, 0x0e, 0x4d, 0x65,
+	0x72, 0x69, 0x63, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x32, 0xbf, 0x02, 0x0a, 0x0e, 0x4d, 0x65,
 	0x74, 0x72, 0x69, 0x63, 0x73, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x38, 0x0a, 0x03,
 	0x47, 0x65, 0x74, 0x12, 0x17, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x47, 0x65, 0x74, 0x4d,
 	0x65, 0x74, 0x72, 0x69, 0x63, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x18, 0x2e, 0x70,
@@ -42,11 +42,17 @@ var file_metrics_service_proto_rawDesc = []byte{
 	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x28, 0x0a, 0x07,
 	0x53, 0x61, 0x76, 0x65, 0x41, 0x6c, 0x6c, 0x12, 0x0e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e,
 	0x4d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x1a, 0x0d, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e,
-	0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x42, 0x37, 0x5a, 0x35, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62,
-	0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x30, 0x64, 0x65, 0x72, 0x65, 0x64, 0x32, 0x37, 0x33, 0x2f,
-	0x67, 0x6f, 0x2d, 0x61, 0x64, 0x76, 0x2d, 0x6d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x73, 0x2f, 0x69,
-	0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x62,
-	0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x34, 0x0a, 0x0a, 0x53, 0x61, 0x76, 0x65, 0x53, 0x74,
+	0x72, 0x65, 0x61, 0x6d, 0x12, 0x12, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4d, 0x65, 0x74,
+	0x72, 0x69, 0x63, 0x42, 0x61, 0x74, 0x63, 0x68, 0x1a, 0x0e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x2e, 0x53, 0x61, 0x76, 0x65, 0x41, 0x63, 0x6b, 0x28, 0x01, 0x30, 0x01, 0x12, 0x2d, 0x0a, 0x05,
+	0x57, 0x61, 0x74, 0x63, 0x68, 0x12, 0x13, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x57, 0x61,
+	0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x0d, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x2e, 0x4d, 0x65, 0x74, 0x72, 0x69, 0x63, 0x30, 0x01, 0x42, 0x37, 0x5a, 0x35, 0x67,
+	0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x63, 0x30, 0x64, 0x65, 0x72, 0x65,
+	0x64, 0x32, 0x37, 0x33, 0x2f, 0x67, 0x6f, 0x2d, 0x61, 0x64, 0x76, 0x2d, 0x6d, 0x65, 0x74, 0x72,
+	0x69, 0x63, 0x73, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x73, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var file_metrics_service_proto_goTypes = []interface{}{
@@ -54,21 +60,28 @@ var file_metrics_service_proto_goTypes = []interface{}{
 	(*emptypb.Empty)(nil),               // 1: google.protobuf.Empty
 	(*model.Metric)(nil),                // 2: proto.Metric
 	(*model.Metrics)(nil),               // 3: proto.Metrics
-	(*model.GetMetricResponse)(nil),     // 4: proto.GetMetricResponse
-	(*model.GetAllMetricsResponse)(nil), // 5: proto.GetAllMetricsResponse
-	(*model.Status)(nil),                // 6: proto.Status
+	(*model.MetricBatch)(nil),           // 4: proto.MetricBatch
+	(*model.WatchRequest)(nil),          // 5: proto.WatchRequest
+	(*model.GetMetricResponse)(nil),     // 6: proto.GetMetricResponse
+	(*model.GetAllMetricsResponse)(nil), // 7: proto.GetAllMetricsResponse
+	(*model.Status)(nil),                // 8: proto.Status
+	(*model.SaveAck)(nil),               // 9: proto.SaveAck
 }
 var file_metrics_service_proto_depIdxs = []int32{
 	0, // 0: proto.MetricsService.Get:input_type -> proto.GetMetricRequest
 	1, // 1: proto.MetricsService.GetAll:input_type -> google.protobuf.Empty
 	2, // 2: proto.MetricsService.Save:input_type -> proto.Metric
 	3, // 3: proto.MetricsService.SaveAll:input_type -> proto.Metrics
-	4, // 4: proto.MetricsService.Get:output_type -> proto.GetMetricResponse
-	5, // 5: proto.MetricsService.GetAll:output_type -> proto.GetAllMetricsResponse
-	6, // 6: proto.MetricsService.Save:output_type -> proto.Status
-	6, // 7: proto.MetricsService.SaveAll:output_type -> proto.Status
-	4, // [4:8] is the sub-list for method output_type
-	0, // [0:4] is the sub-list for method input_type
+	4, // 4: proto.MetricsService.SaveStream:input_type -> proto.MetricBatch
+	5, // 5: proto.MetricsService.Watch:input_type -> proto.WatchRequest
+	6, // 6: proto.MetricsService.Get:output_type -> proto.GetMetricResponse
+	7, // 7: proto.MetricsService.GetAll:output_type -> proto.GetAllMetricsResponse
+	8, // 8: proto.MetricsService.Save:output_type -> proto.Status
+	8, // 9: proto.MetricsService.SaveAll:output_type -> proto.Status
+	9, // 10: proto.MetricsService.SaveStream:output_type -> proto.SaveAck
+	2, // 11: proto.MetricsService.Watch:output_type -> proto.Metric
+	6, // [6:12] is the sub-list for method output_type
+	0, // [0:6] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -21,10 +21,12 @@ import (
 const _ = grpc.SupportPackageIsVersion7
 
 const (
-	MetricsService_Get_FullMethodName     = "/proto.MetricsService/Get"
-	MetricsService_GetAll_FullMethodName  = "/proto.MetricsService/GetAll"
-	MetricsService_Save_FullMethodName    = "/proto.MetricsService/Save"
-	MetricsService_SaveAll_FullMethodName = "/proto.MetricsService/SaveAll"
+	MetricsService_Get_FullMethodName        = "/proto.MetricsService/Get"
+	MetricsService_GetAll_FullMethodName     = "/proto.MetricsService/GetAll"
+	MetricsService_Save_FullMethodName       = "/proto.MetricsService/Save"
+	MetricsService_SaveAll_FullMethodName    = "/proto.MetricsService/SaveAll"
+	MetricsService_SaveStream_FullMethodName = "/proto.MetricsService/SaveStream"
+	MetricsService_Watch_FullMethodName      = "/proto.MetricsService/Watch"
 )
 
 // MetricsServiceClient is the client API for MetricsService service.
@@ -35,6 +37,11 @@ type MetricsServiceClient interface {
 	GetAll(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*model.GetAllMetricsResponse, error)
 	Save(ctx context.Context, in *model.Metric, opts ...grpc.CallOption) (*model.Status, error)
 	SaveAll(ctx context.Context, in *model.Metrics, opts ...grpc.CallOption) (*model.Status, error)
+	// SaveStream сохраняет корзины метрик из долгоживущего потока и подтверждает каждую корзину по ее номеру
+	SaveStream(ctx context.Context, opts ...grpc.CallOption) (MetricsService_SaveStreamClient, error)
+	// Watch отправляет каждую сохраненную сервером метрику, подходящую под фильтры.
+	// Счетчики приходят с сохраненным значением, а не с принятым приращением
+	Watch(ctx context.Context, in *model.WatchRequest, opts ...grpc.CallOption) (MetricsService_WatchClient, error)
 }
 
 type metricsServiceClient struct {
@@ -81,6 +88,69 @@ func (c *metricsServiceClient) SaveAll(ctx context.Context, in *model.Metrics, o
 	return out, nil
 }
 
+func (c *metricsServiceClient) SaveStream(ctx context.Context, opts ...grpc.CallOption) (MetricsService_SaveStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MetricsService_ServiceDesc.Streams[0], MetricsService_SaveStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &metricsServiceSaveStreamClient{stream}
+	return x, nil
+}
+
+type MetricsService_SaveStreamClient interface {
+	Send(*model.MetricBatch) error
+	Recv() (*model.SaveAck, error)
+	grpc.ClientStream
+}
+
+type metricsServiceSaveStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *metricsServiceSaveStreamClient) Send(m *model.MetricBatch) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *metricsServiceSaveStreamClient) Recv() (*model.SaveAck, error) {
+	m := new(model.SaveAck)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *metricsServiceClient) Watch(ctx context.Context, in *model.WatchRequest, opts ...grpc.CallOption) (MetricsService_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MetricsService_ServiceDesc.Streams[1], MetricsService_Watch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &metricsServiceWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MetricsService_WatchClient interface {
+	Recv() (*model.Metric, error)
+	grpc.ClientStream
+}
+
+type metricsServiceWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *metricsServiceWatchClient) Recv() (*model.Metric, error) {
+	m := new(model.Metric)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // MetricsServiceServer is the server API for MetricsService service.
 // All implementations must embed UnimplementedMetricsServiceServer
 // for forward compatibility
@@ -89,6 +159,11 @@ type MetricsServiceServer interface {
 	GetAll(context.Context, *emptypb.Empty) (*model.GetAllMetricsResponse, error)
 	Save(context.Context, *model.Metric) (*model.Status, error)
 	SaveAll(context.Context, *model.Metrics) (*model.Status, error)
+	// SaveStream сохраняет корзины метрик из долгоживущего потока и подтверждает каждую корзину по ее номеру
+	SaveStream(MetricsService_SaveStreamServer) error
+	// Watch отправляет каждую сохраненную сервером метрику, подходящую под фильтры.
+	// Счетчики приходят с сохраненным значением, а не с принятым приращением
+	Watch(*model.WatchRequest, MetricsService_WatchServer) error
 	mustEmbedUnimplementedMetricsServiceServer()
 }
 
@@ -108,6 +183,12 @@ func (UnimplementedMetricsServiceServer) Save(context.Context, *model.Metric) (*
 func (UnimplementedMetricsServiceServer) SaveAll(context.Context, *model.Metrics) (*model.Status, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SaveAll not implemented")
 }
+func (UnimplementedMetricsServiceServer) SaveStream(MetricsService_SaveStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method SaveStream not implemented")
+}
+func (UnimplementedMetricsServiceServer) Watch(*model.WatchRequest, MetricsService_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
 func (UnimplementedMetricsServiceServer) mustEmbedUnimplementedMetricsServiceServer() {}
 
 // UnsafeMetricsServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -193,6 +274,53 @@ func _MetricsService_SaveAll_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MetricsService_SaveStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MetricsServiceServer).SaveStream(&metricsServiceSaveStreamServer{stream})
+}
+
+type MetricsService_SaveStreamServer interface {
+	Send(*model.SaveAck) error
+	Recv() (*model.MetricBatch, error)
+	grpc.ServerStream
+}
+
+type metricsServiceSaveStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *metricsServiceSaveStreamServer) Send(m *model.SaveAck) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *metricsServiceSaveStreamServer) Recv() (*model.MetricBatch, error) {
+	m := new(model.MetricBatch)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _MetricsService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(model.WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MetricsServiceServer).Watch(m, &metricsServiceWatchServer{stream})
+}
+
+type MetricsService_WatchServer interface {
+	Send(*model.Metric) error
+	grpc.ServerStream
+}
+
+type metricsServiceWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *metricsServiceWatchServer) Send(m *model.Metric) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // MetricsService_ServiceDesc is the grpc.ServiceDesc for MetricsService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -217,6 +345,18 @@ var MetricsService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _MetricsService_SaveAll_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SaveStream",
+			Handler:       _MetricsService_SaveStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _MetricsService_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "metrics_service.proto",
 }
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/recording"
+	"github.com/c0dered273/go-adv-metrics/internal/selfmetrics"
+)
+
+// StoreMetrics общий путь приема метрик для HTTP и gRPC. Накопительные счетчики от источника sourceKey
+// заменяются приращениями, к метрикам применяются правила приема, принятые метрики сохраняются
+// и рассылаются подписчикам, счетчики - с сохраненным значением. Хранилище прибавляет приращения
+// счетчиков к сохраненным значениям и при сохранении корзины. Метрики, отброшенные правилами, не считаются ошибкой
+func StoreMetrics(ctx context.Context, c *config.ServerConfig, sourceKey string, metrics []metric.Metric) error {
+	accepted := applyIngestRules(c, applyCumulative(c, sourceKey, metrics))
+	if len(accepted) == 0 {
+		return nil
+	}
+
+	if err := c.Repo.SaveAll(ctx, accepted); err != nil {
+		return err
+	}
+	c.Alerts.Observe(accepted)
+	c.Stream.Publish(storedTotals(ctx, c, accepted))
+	return nil
+}
+
+// storedTotals заменяет приращения счетчиков сохраненными значениями, чтобы подписчики получали
+// то же значение, что отдает сервер по запросу, и подписывает их ключом сервера. Если значение не найдено, остается приращение
+func storedTotals(ctx context.Context, c *config.ServerConfig, metrics []metric.Metric) []metric.Metric {
+	if !c.Stream.HasSubscribers() {
+		return nil
+	}
+
+	result := make([]metric.Metric, len(metrics))
+	copy(result, metrics)
+	for i, m := range result {
+		if m.GetType() != metric.Counter {
+			continue
+		}
+		if stored, err := c.Repo.FindByID(ctx, m); err == nil {
+			stored.SetHash(c.GetKey())
+			result[i] = stored
+		}
+	}
+	return result
+}
+
+// recordingSourceKey ключ источника производных метрик правил записи
+const recordingSourceKey = "recording"
+
+// StoreRecorded возвращает функцию сохранения производных метрик правил записи. Метрики подписываются
+// актуальным ключом сервера и проходят тот же путь приема, что и метрики от клиентов
+func StoreRecorded(c *config.ServerConfig) recording.StoreFunc {
+	return func(ctx context.Context, metrics []metric.Metric) error {
+		key := c.GetKey()
+		for i := range metrics {
+			metrics[i].SetHash(key)
+		}
+		return StoreMetrics(ctx, c, recordingSourceKey, metrics)
+	}
+}
+
+// applyCumulative заменяет накопительные счетчики от источника приращениями. Сбросы счетчиков учитываются в метриках сервера
+func applyCumulative(c *config.ServerConfig, sourceKey string, metrics []metric.Metric) []metric.Metric {
+	result := c.Cumulative.Apply(sourceKey, metrics, c.GetKey())
+	if result.Resets > 0 {
+		c.SelfMetrics.Add(selfmetrics.CounterResets, int64(result.Resets))
+		c.Logger.Info().Str("source", sourceKey).Int("resets", result.Resets).Msg("service: cumulative counters reset")
+	}
+	return result.Metrics
+}
+
+// applyIngestRules применяет актуальные правила приема к принятым метрикам и возвращает метрики для сохранения.
+// Отброшенные метрики учитываются в метриках сервера
+func applyIngestRules(c *config.ServerConfig, metrics []metric.Metric) []metric.Metric {
+	result := c.GetIngestRules().Apply(metrics, c.Series, c.GetKey())
+	if result.Filtered > 0 {
+		c.SelfMetrics.Add(selfmetrics.FilteredMetrics, int64(result.Filtered))
+	}
+	if result.OverLimit > 0 {
+		c.SelfMetrics.Add(selfmetrics.OverLimitMetrics, int64(result.OverLimit))
+		c.Logger.Warn().Int("dropped", result.OverLimit).Msg("service: metric limit reached, new metrics dropped")
+	}
+	return result.Accepted
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/ingest"
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/c0dered273/go-adv-metrics/internal/stream"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreRecorded(t *testing.T) {
+	ctx := context.Background()
+	rules, err := ingest.New(ingest.Rules{MaxSeries: 2})
+	require.NoError(t, err)
+	cfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{Key: "secret"},
+		Logger:         zerolog.Nop(),
+		Repo:           storage.NewPersistenceRepo(storage.NewMemStorage()),
+		Stream:         stream.NewHub(),
+		IngestRules:    rules,
+		Series:         ingest.NewSeries([]metric.Metric{metric.NewGaugeMetric("Alloc", 1)}),
+	}
+	sub := cfg.Stream.Subscribe(nil, stream.DefaultBuffer)
+
+	err = StoreRecorded(cfg)(ctx, []metric.Metric{
+		metric.NewGaugeMetric("HeapUsage", 0.25),
+		metric.NewGaugeMetric("CPUutilizationTotal", 40),
+	})
+	require.NoError(t, err)
+
+	m, err := cfg.Repo.FindByID(ctx, metric.NewGaugeMetric("HeapUsage", 0))
+	require.NoError(t, err)
+	ok, err := m.CheckHash("secret")
+	require.NoError(t, err)
+	assert.True(t, ok, "recorded metric should be signed with server key")
+
+	_, err = cfg.Repo.FindByID(ctx, metric.NewGaugeMetric("CPUutilizationTotal", 0))
+	assert.Error(t, err, "recorded metric over series limit should be dropped")
+
+	published := <-sub.C
+	assert.Equal(t, "HeapUsage", published.ID)
+	assert.Len(t, sub.C, 0)
+}
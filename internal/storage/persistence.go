@@ -45,6 +45,30 @@ func (p *PersistenceRepo) Save(ctx context.Context, newMetric metric.Metric) err
 	return nil
 }
 
+// SaveAll сохраняет метрики по тем же правилам, что и Save: значения счетчиков прибавляются к сохраненным,
+// в том числе значения одного счетчика, встречающегося в корзине несколько раз. Корзина сохраняется одним вызовом SaveAll
+func (p *PersistenceRepo) SaveAll(ctx context.Context, metrics []metric.Metric) error {
+	result := make([]metric.Metric, 0, len(metrics))
+	counters := make(map[string]int)
+	for _, m := range metrics {
+		if m.GetType() != metric.Counter {
+			result = append(result, m)
+			continue
+		}
+
+		if i, ok := counters[m.ID]; ok {
+			result[i] = metric.NewCounterMetric(m.ID, result[i].GetCounterValue()+m.GetCounterValue())
+			continue
+		}
+		if existMetric, fndErr := p.FindByID(ctx, m); fndErr == nil {
+			m = metric.NewCounterMetric(existMetric.GetName(), existMetric.GetCounterValue()+m.GetCounterValue())
+		}
+		counters[m.ID] = len(result)
+		result = append(result, m)
+	}
+	return p.Repository.SaveAll(ctx, result)
+}
+
 func NewPersistenceRepo(r Repository) Repository {
 	return &PersistenceRepo{Repository: r}
 }
@@ -113,3 +113,44 @@ func TestPersistenceRepo_SaveCounter(t *testing.T) {
 		})
 	}
 }
+
+func TestPersistenceRepo_SaveAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		batches [][]metric.Metric
+		want    []metric.Metric
+	}{
+		{
+			name: "should sum counters across batches",
+			batches: [][]metric.Metric{
+				{metric.NewCounterMetric("PollCount", 10), metric.NewGaugeMetric("Alloc", 1.5)},
+				{metric.NewCounterMetric("PollCount", 5), metric.NewGaugeMetric("Alloc", 2.5)},
+			},
+			want: []metric.Metric{metric.NewCounterMetric("PollCount", 15), metric.NewGaugeMetric("Alloc", 2.5)},
+		},
+		{
+			name: "should sum repeated counter within batch",
+			batches: [][]metric.Metric{
+				{metric.NewCounterMetric("PollCount", 3)},
+				{metric.NewCounterMetric("PollCount", 4), metric.NewCounterMetric("PollCount", 5)},
+			},
+			want: []metric.Metric{metric.NewCounterMetric("PollCount", 12)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			mem := NewMemStorage()
+			p := NewPersistenceRepo(mem)
+			for _, batch := range tt.batches {
+				assert.NoError(t, p.SaveAll(ctx, batch))
+			}
+
+			for _, want := range tt.want {
+				got, err := mem.FindByID(ctx, want)
+				assert.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+// Package stream рассылает принятые сервером метрики подписчикам, например клиентам потока событий.
+// У каждого подписчика свой буфер. Если подписчик не успевает забирать метрики и буфер заполнен,
+// новые метрики для него отбрасываются, чтобы медленный подписчик не задерживал прием метрик
+package stream
+
+import (
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+)
+
+// DefaultBuffer размер буфера подписчика по умолчанию
+const DefaultBuffer = 256
+
+// Filter отбирает метрики для подписчика
+type Filter func(m metric.Metric) bool
+
+// MatchID возвращает фильтр метрик, ID которых соответствует регулярному выражению, nil - все метрики
+func MatchID(re *regexp.Regexp) Filter {
+	if re == nil {
+		return nil
+	}
+	return func(m metric.Metric) bool {
+		return re.MatchString(m.ID)
+	}
+}
+
+// Subscription подписка на метрики. Канал C закрывается при отписке или закрытии Hub
+type Subscription struct {
+	C       <-chan metric.Metric
+	ch      chan metric.Metric
+	filter  Filter
+	dropped *atomic.Int64
+}
+
+// Dropped возвращает количество метрик, отброшенных с прошлого вызова из-за заполненного буфера
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+// Hub рассылает метрики подписчикам. Методы безопасно вызывать у nil, тогда метрики никуда не рассылаются
+type Hub struct {
+	mu     *sync.RWMutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// NewHub возвращает Hub без подписчиков
+func NewHub() *Hub {
+	return &Hub{
+		mu:   new(sync.RWMutex),
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe подписывает на метрики, которые отбирает filter, nil - на все метрики.
+// Если Hub закрыт, канал подписки сразу закрыт
+func (h *Hub) Subscribe(filter Filter, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	ch := make(chan metric.Metric, buffer)
+	s := &Subscription{C: ch, ch: ch, filter: filter, dropped: new(atomic.Int64)}
+	if h == nil {
+		close(ch)
+		return s
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return s
+	}
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// Unsubscribe отписывает и закрывает канал подписки
+func (h *Hub) Unsubscribe(s *Subscription) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+// HasSubscribers сообщает, есть ли подписчики, чтобы не готовить метрики для рассылки впустую
+func (h *Hub) HasSubscribers() bool {
+	if h == nil {
+		return false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.subs) > 0
+}
+
+// Publish рассылает метрики подписчикам без ожидания
+func (h *Hub) Publish(metrics []metric.Metric) {
+	if h == nil || len(metrics) == 0 {
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for s := range h.subs {
+		for _, m := range metrics {
+			if s.filter != nil && !s.filter(m) {
+				continue
+			}
+			select {
+			case s.ch <- m:
+			default:
+				s.dropped.Add(1)
+			}
+		}
+	}
+}
+
+// Close закрывает все подписки, например при остановке сервера
+func (h *Hub) Close() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subs {
+		close(s.ch)
+	}
+	h.subs = make(map[*Subscription]struct{})
+	h.closed = true
+}
@@ -0,0 +1,93 @@
+package stream
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/stretchr/testify/assert"
+)
+
+func received(s *Subscription) []string {
+	var got []string
+	for {
+		select {
+		case m, ok := <-s.C:
+			if !ok {
+				return got
+			}
+			got = append(got, m.String())
+		default:
+			return got
+		}
+	}
+}
+
+func TestHub_Publish(t *testing.T) {
+	metrics := []metric.Metric{
+		metric.NewGaugeMetric("Alloc", 1.5),
+		metric.NewCounterMetric("PollCount", 2),
+		metric.NewGaugeMetric("HeapAlloc", 3),
+	}
+	tests := []struct {
+		name        string
+		match       *regexp.Regexp
+		buffer      int
+		want        []string
+		wantDropped int64
+	}{
+		{
+			name:   "should send all metrics without filter",
+			buffer: 10,
+			want:   []string{"/gauge/Alloc/1.5", "/counter/PollCount/2", "/gauge/HeapAlloc/3"},
+		},
+		{
+			name:   "should send matching metrics",
+			match:  regexp.MustCompile("Alloc$"),
+			buffer: 10,
+			want:   []string{"/gauge/Alloc/1.5", "/gauge/HeapAlloc/3"},
+		},
+		{
+			name:        "should drop metrics when buffer is full",
+			buffer:      1,
+			want:        []string{"/gauge/Alloc/1.5"},
+			wantDropped: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := NewHub()
+			sub := hub.Subscribe(MatchID(tt.match), tt.buffer)
+			hub.Publish(metrics)
+
+			assert.Equal(t, tt.want, received(sub))
+			assert.Equal(t, tt.wantDropped, sub.Dropped())
+			assert.Equal(t, int64(0), sub.Dropped(), "dropped should be reset after read")
+		})
+	}
+}
+
+func TestHub_Unsubscribe(t *testing.T) {
+	hub := NewHub()
+	first := hub.Subscribe(nil, 10)
+	second := hub.Subscribe(nil, 10)
+
+	hub.Unsubscribe(first)
+	hub.Unsubscribe(first)
+	hub.Publish([]metric.Metric{metric.NewGaugeMetric("Alloc", 1)})
+
+	_, ok := <-first.C
+	assert.False(t, ok, "unsubscribed channel should be closed")
+	assert.Equal(t, []string{"/gauge/Alloc/1"}, received(second))
+
+	hub.Close()
+	_, ok = <-second.C
+	assert.False(t, ok, "channel should be closed with hub")
+	_, ok = <-hub.Subscribe(nil, 10).C
+	assert.False(t, ok, "subscription to closed hub should be closed")
+
+	var disabled *Hub
+	disabled.Publish([]metric.Metric{metric.NewGaugeMetric("Alloc", 1)})
+	_, ok = <-disabled.Subscribe(nil, 10).C
+	assert.False(t, ok)
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/c0dered273/go-adv-metrics/internal/handler"
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+	"github.com/c0dered273/go-adv-metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Concurrent(t *testing.T) {
+	registry := NewRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				registry.Counter("Hits").Inc()
+				registry.Gauge("Level").Add(0.5)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1000), registry.Counter("Hits").Value())
+	assert.Equal(t, float64(500), registry.Gauge("Level").Value())
+}
+
+func TestPusher_Push(t *testing.T) {
+	const key = "secret"
+
+	var mu sync.Mutex
+	var received []metric.Metric
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/updates/" {
+			http.NotFound(w, r)
+			return
+		}
+		var batch []metric.Metric
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		received = append(received, batch...)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	registry := NewRegistry()
+	registry.Gauge("Temperature").Set(21.5)
+	registry.Gauge("Load").Set(0.7)
+	registry.Counter("Requests").Add(3)
+	registry.Counter("Errors").Inc()
+
+	pusher, err := NewPusher(registry, Config{Address: srv.URL, Key: key})
+	if err != nil {
+		panic(err)
+	}
+
+	sent := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		var result []string
+		for _, m := range received {
+			ok, err := m.CheckHash(key)
+			assert.NoError(t, err)
+			assert.True(t, ok, "metric %s should be signed", m.GetName())
+			result = append(result, m.String())
+		}
+		received = nil
+		return result
+	}
+
+	assert.NoError(t, pusher.Push())
+	assert.Equal(t, []string{
+		"/gauge/Load/0.7",
+		"/gauge/Temperature/21.5",
+		"/counter/Errors/1",
+		"/counter/Requests/3",
+	}, sent())
+
+	registry.Counter("Requests").Inc()
+	assert.NoError(t, pusher.Stop())
+	assert.Equal(t, []string{
+		"/gauge/Load/0.7",
+		"/gauge/Temperature/21.5",
+		"/counter/Errors/0",
+		"/counter/Requests/1",
+	}, sent(), "counters should send only the increase since the previous push")
+
+	pusher.Start()
+	assert.NoError(t, pusher.Stop(), "stopped pusher should not restart")
+	assert.Empty(t, sent())
+}
+
+func TestPusher_PushToServer(t *testing.T) {
+	const key = "secret"
+	cfg := &config.ServerConfig{
+		ServerInParams: &config.ServerInParams{Key: key},
+		Repo:           storage.NewPersistenceRepo(storage.NewMemStorage()),
+	}
+	srv := httptest.NewServer(handler.Service(cfg))
+	defer srv.Close()
+
+	registry := NewRegistry()
+	pusher, err := NewPusher(registry, Config{Address: srv.URL, Key: key})
+	assert.NoError(t, err)
+
+	// Приросты из разных отправок должны складываться на сервере
+	registry.Counter("Requests").Add(3)
+	registry.Counter("Errors").Inc()
+	registry.Gauge("Load").Set(0.7)
+	assert.NoError(t, pusher.Push())
+	registry.Counter("Requests").Add(4)
+	registry.Gauge("Load").Set(0.9)
+	assert.NoError(t, pusher.Push())
+
+	want := []metric.Metric{
+		metric.NewCounterMetric("Requests", 7),
+		metric.NewCounterMetric("Errors", 1),
+		metric.NewGaugeMetric("Load", 0.9),
+	}
+	for _, w := range want {
+		got, err := cfg.Repo.FindByID(context.Background(), w)
+		if assert.NoError(t, err, w.ID) {
+			assert.Equal(t, w.String(), got.String())
+		}
+	}
+}
+
+func TestNewPusher(t *testing.T) {
+	_, err := NewPusher(NewRegistry(), Config{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c0dered273/go-adv-metrics/internal/agent"
+	"github.com/c0dered273/go-adv-metrics/internal/config"
+	"github.com/rs/zerolog"
+)
+
+// DefaultInterval интервал отправки метрик по умолчанию
+const DefaultInterval = 10 * time.Second
+
+// Config настройки отправки метрик на сервер
+type Config struct {
+	// Address адрес сервера, для HTTP допускается указание схемы
+	Address string
+	// Interval интервал отправки метрик, по умолчанию DefaultInterval
+	Interval time.Duration
+	// Key ключ подписи метрик, должен совпадать с ключом сервера
+	Key string
+	// PublicKey публичный RSA ключ для шифрования запросов, должен соответствовать приватному ключу сервера
+	PublicKey *rsa.PublicKey
+	// GRPC использовать gRPC вместо HTTP
+	GRPC bool
+	// CACertFile файл с корневым сертификатом, обязателен для gRPC
+	CACertFile string
+	// Logger логгер, по умолчанию логирование отключено
+	Logger *zerolog.Logger
+}
+
+// Pusher периодически отправляет метрики из Registry на сервер.
+// После Stop Pusher нельзя запустить снова, для возобновления отправки нужно создать новый
+type Pusher struct {
+	registry *Registry
+	interval time.Duration
+	key      string
+	client   agent.Client
+	cancel   context.CancelFunc
+
+	mu      *sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+	started bool
+	stopped bool
+}
+
+// NewPusher возвращает настроенный Pusher, отправка начинается после вызова Start
+func NewPusher(registry *Registry, cfg Config) (*Pusher, error) {
+	if cfg.Address == "" {
+		return nil, errors.New("client: server address is required")
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+
+	logger := zerolog.Nop()
+	if cfg.Logger != nil {
+		logger = *cfg.Logger
+	}
+
+	address := cfg.Address
+	if !strings.HasPrefix(address, "http://") && !strings.HasPrefix(address, "https://") {
+		address = "http://" + address
+	}
+
+	agentCfg := &config.AgentConfig{
+		AgentInParams: &config.AgentInParams{
+			Address:        address,
+			ReportInterval: cfg.Interval,
+			Key:            cfg.Key,
+			GRPCClient:     cfg.GRPC,
+			CACertFile:     cfg.CACertFile,
+		},
+		PublicKey: cfg.PublicKey,
+		Logger:    logger,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := agent.NewClient(ctx, agentCfg)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	return &Pusher{
+		registry: registry,
+		interval: cfg.Interval,
+		key:      cfg.Key,
+		client:   client,
+		cancel:   cancel,
+		mu:       new(sync.Mutex),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Push отправляет текущие значения метрик на сервер корзинами, как это делает агент.
+// Если отправка корзины не удалась, приросты ее счетчиков сохраняются до следующей отправки
+func (p *Pusher) Push() error {
+	metrics := p.registry.snapshot()
+
+	var pushErr error
+	for start := 0; start < len(metrics); start += agent.BufferLen {
+		end := start + agent.BufferLen
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		batch := metrics[start:end]
+		for i := range batch {
+			batch[i].SetHash(p.key)
+		}
+
+		if err := p.client.PostMetric(batch); err != nil {
+			p.registry.restore(batch)
+			pushErr = err
+		}
+	}
+	return pushErr
+}
+
+// Start запускает периодическую отправку метрик в фоне
+func (p *Pusher) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.started || p.stopped {
+		return
+	}
+	p.started = true
+
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = p.Push()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop останавливает периодическую отправку, отправляет накопленные значения в последний раз
+// и закрывает соединение с сервером. Повторный вызов ничего не делает
+func (p *Pusher) Stop() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return nil
+	}
+	p.stopped = true
+	if p.started {
+		close(p.stop)
+		<-p.done
+	}
+
+	defer p.cancel()
+	err := p.Push()
+	if closeErr := p.client.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
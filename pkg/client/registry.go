@@ -0,0 +1,174 @@
+// Package client позволяет приложениям отправлять собственные метрики на сервер метрик.
+//
+// Метрики регистрируются в Registry по имени, значения меняются потокобезопасно,
+// а Pusher периодически отправляет их на сервер тем же протоколом, что и агент:
+// HTTP запросом на /updates/ или gRPC методом SaveAll, с подписью HMAC и, при необходимости, шифрованием.
+//
+//	registry := client.NewRegistry()
+//	requests := registry.Counter("AppRequests")
+//	queue := registry.Gauge("AppQueueLength")
+//
+//	pusher, err := client.NewPusher(registry, client.Config{Address: "localhost:8080", Key: "secret"})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	pusher.Start()
+//	defer pusher.Stop()
+//
+//	requests.Inc()
+//	queue.Set(12)
+package client
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/c0dered273/go-adv-metrics/internal/metric"
+)
+
+// Gauge метрика, значение которой может произвольно меняться.
+// На сервер отправляется последнее установленное значение
+type Gauge struct {
+	bits uint64
+}
+
+// Set устанавливает значение метрики
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Add прибавляет к значению метрики delta, delta может быть отрицательным
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		next := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&g.bits, old, next) {
+			return
+		}
+	}
+}
+
+// Inc увеличивает значение метрики на единицу
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Value возвращает текущее значение метрики
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// Counter монотонно возрастающий счетчик.
+// На сервер отправляется прирост с момента предыдущей успешной отправки, сервер суммирует приросты
+type Counter struct {
+	delta int64
+}
+
+// Add увеличивает счетчик на n
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.delta, n)
+}
+
+// Inc увеличивает счетчик на единицу
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Set устанавливает неотправленный прирост счетчика
+func (c *Counter) Set(n int64) {
+	atomic.StoreInt64(&c.delta, n)
+}
+
+// Value возвращает прирост счетчика, еще не отправленный на сервер
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.delta)
+}
+
+// Registry набор именованных метрик приложения
+type Registry struct {
+	mu       *sync.RWMutex
+	gauges   map[string]*Gauge
+	counters map[string]*Counter
+}
+
+// NewRegistry возвращает пустой набор метрик
+func NewRegistry() *Registry {
+	return &Registry{
+		mu:       new(sync.RWMutex),
+		gauges:   make(map[string]*Gauge),
+		counters: make(map[string]*Counter),
+	}
+}
+
+// Gauge возвращает метрику gauge с указанным именем, при необходимости регистрируя ее
+func (r *Registry) Gauge(name string) *Gauge {
+	r.mu.RLock()
+	g, ok := r.gauges[name]
+	r.mu.RUnlock()
+	if ok {
+		return g
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if g, ok = r.gauges[name]; !ok {
+		g = &Gauge{}
+		r.gauges[name] = g
+	}
+	return g
+}
+
+// Counter возвращает счетчик с указанным именем, при необходимости регистрируя его
+func (r *Registry) Counter(name string) *Counter {
+	r.mu.RLock()
+	c, ok := r.counters[name]
+	r.mu.RUnlock()
+	if ok {
+		return c
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok = r.counters[name]; !ok {
+		c = &Counter{}
+		r.counters[name] = c
+	}
+	return c
+}
+
+// snapshot забирает текущие значения метрик для отправки.
+// Приросты счетчиков обнуляются, в случае ошибки отправки их нужно вернуть через restore
+func (r *Registry) snapshot() []metric.UpdatableMetric {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]metric.UpdatableMetric, 0, len(r.gauges)+len(r.counters))
+	for _, name := range sortedNames(r.gauges) {
+		result = append(result, metric.UpdatableMetric{Metric: metric.NewGaugeMetric(name, r.gauges[name].Value())})
+	}
+	for _, name := range sortedNames(r.counters) {
+		delta := atomic.SwapInt64(&r.counters[name].delta, 0)
+		result = append(result, metric.UpdatableMetric{Metric: metric.NewCounterMetric(name, delta)})
+	}
+	return result
+}
+
+// restore возвращает неотправленные приросты счетчиков
+func (r *Registry) restore(metrics []metric.UpdatableMetric) {
+	for _, m := range metrics {
+		if m.GetType() == metric.Counter {
+			r.Counter(m.GetName()).Add(m.GetCounterValue())
+		}
+	}
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}